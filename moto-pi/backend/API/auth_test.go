@@ -0,0 +1,108 @@
+package API
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestLoadToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "api-token")
+	token, err := LoadToken(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 2*tokenSize {
+		t.Errorf("generated token %q, want %d hex digits", token, 2*tokenSize)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("token file = %v, %v, want mode 0600", info, err)
+	}
+	if again, err := LoadToken(path); err != nil || again != token {
+		t.Errorf("LoadToken() again = %q, %v, want the stored token", again, err)
+	}
+
+	if _, err := LoadToken(""); err == nil {
+		t.Error("LoadToken() accepted an empty path")
+	}
+	empty := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadToken(empty); err == nil {
+		t.Error("LoadToken() accepted an empty token file")
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) { w.WriteHeader(http.StatusNoContent) }
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"valid token", "s3cret", "Bearer s3cret", http.StatusNoContent},
+		{"no header", "s3cret", "", http.StatusUnauthorized},
+		{"wrong token", "s3cret", "Bearer guess", http.StatusUnauthorized},
+		{"token prefix", "s3cret", "Bearer s3c", http.StatusUnauthorized},
+		{"other scheme", "s3cret", "Basic s3cret", http.StatusUnauthorized},
+		{"no token configured", "", "Bearer ", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodDelete, "/v1/api/hal/rfid/credentials/1", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			requireToken(tt.token, ok)(w, r, nil)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	handler := CORS([]string{"http://10.10.10.1"}, next)
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantAllowed string
+	}{
+		{"web ui", http.MethodGet, "http://10.10.10.1", false, http.StatusOK, "http://10.10.10.1"},
+		{"web ui preflight", http.MethodOptions, "http://10.10.10.1", true, http.StatusNoContent, "http://10.10.10.1"},
+		{"other origin", http.MethodGet, "http://evil.example", false, http.StatusOK, ""},
+		{"other origin preflight", http.MethodOptions, "http://evil.example", true, http.StatusForbidden, ""},
+		{"same origin", http.MethodGet, "", false, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/v1/api/hal/rfid/credentials", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowed {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllowed)
+			}
+		})
+	}
+}
@@ -1,6 +1,25 @@
 package API
 
+import (
+	"encoding/json"
+	"net/http"
+)
+
 // HandlerInterface defines common methods all handlers should implement
 type HandlerInterface interface {
 	RegisterRoutes()
 }
+
+// writeJSON encodes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError responds with a JSON error message
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,207 @@
+package API
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Services/geofence"
+	"github.com/julienschmidt/httprouter"
+)
+
+// errGeofenceDisabled is returned when no geofence engine is configured
+var errGeofenceDisabled = errors.New("geofencing is disabled")
+
+// GeofenceInterfaceHandler struct to hold interfaces for Geofence handling
+type GeofenceInterfaceHandler struct {
+	*httprouter.Router
+	// Embed a GeofenceService to separate stub/live logic
+	service GeofenceServiceInterface
+}
+
+// GeofenceServiceInterface defines methods the Geofence service must implement
+type GeofenceServiceInterface interface {
+	ListFences() ([]geofence.Fence, error)
+	GetFence(id string) (geofence.Fence, error)
+	CreateFence(f geofence.Fence) (geofence.Fence, error)
+	UpdateFence(id string, f geofence.Fence) (geofence.Fence, error)
+	DeleteFence(id string) error
+	GetEvents() ([]geofence.Event, error)
+}
+
+// StubGeofenceService is a stub implementation
+type StubGeofenceService struct{}
+
+func (s *StubGeofenceService) ListFences() ([]geofence.Fence, error) {
+	return []geofence.Fence{}, nil
+}
+
+func (s *StubGeofenceService) GetFence(id string) (geofence.Fence, error) {
+	return geofence.Fence{}, geofence.ErrNotFound
+}
+
+func (s *StubGeofenceService) CreateFence(f geofence.Fence) (geofence.Fence, error) {
+	return geofence.Fence{}, errGeofenceDisabled
+}
+
+func (s *StubGeofenceService) UpdateFence(id string, f geofence.Fence) (geofence.Fence, error) {
+	return geofence.Fence{}, geofence.ErrNotFound
+}
+
+func (s *StubGeofenceService) DeleteFence(id string) error {
+	return geofence.ErrNotFound
+}
+
+func (s *StubGeofenceService) GetEvents() ([]geofence.Event, error) {
+	return []geofence.Event{}, nil
+}
+
+// LiveGeofenceService is backed by the geofence engine
+type LiveGeofenceService struct {
+	Engine *geofence.Engine
+}
+
+func (s *LiveGeofenceService) ListFences() ([]geofence.Fence, error) {
+	if s.Engine == nil {
+		return nil, errGeofenceDisabled
+	}
+	return s.Engine.List(), nil
+}
+
+func (s *LiveGeofenceService) GetFence(id string) (geofence.Fence, error) {
+	if s.Engine == nil {
+		return geofence.Fence{}, errGeofenceDisabled
+	}
+	return s.Engine.Get(id)
+}
+
+func (s *LiveGeofenceService) CreateFence(f geofence.Fence) (geofence.Fence, error) {
+	if s.Engine == nil {
+		return geofence.Fence{}, errGeofenceDisabled
+	}
+	return s.Engine.Create(f)
+}
+
+func (s *LiveGeofenceService) UpdateFence(id string, f geofence.Fence) (geofence.Fence, error) {
+	if s.Engine == nil {
+		return geofence.Fence{}, errGeofenceDisabled
+	}
+	return s.Engine.Update(id, f)
+}
+
+func (s *LiveGeofenceService) DeleteFence(id string) error {
+	if s.Engine == nil {
+		return errGeofenceDisabled
+	}
+	return s.Engine.Delete(id)
+}
+
+func (s *LiveGeofenceService) GetEvents() ([]geofence.Event, error) {
+	if s.Engine == nil {
+		return nil, errGeofenceDisabled
+	}
+	return s.Engine.Events(), nil
+}
+
+// NewGeofenceInterfaceHandler creates a new Geofence handler
+func NewGeofenceInterfaceHandler(service GeofenceServiceInterface, router *httprouter.Router) *GeofenceInterfaceHandler {
+	h := &GeofenceInterfaceHandler{
+		Router:  router,
+		service: service,
+	}
+
+	h.Router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	h.Router.GET("/v1/api/motorcycle/geofences", h.ListFences)
+	h.Router.POST("/v1/api/motorcycle/geofences", h.CreateFence)
+	h.Router.GET("/v1/api/motorcycle/geofences/:id", h.GetFence)
+	h.Router.PUT("/v1/api/motorcycle/geofences/:id", h.UpdateFence)
+	h.Router.DELETE("/v1/api/motorcycle/geofences/:id", h.DeleteFence)
+	h.Router.GET("/v1/api/motorcycle/geofence-events", h.GetEvents)
+
+	return h
+}
+
+// ListFences endpoint
+func (h *GeofenceInterfaceHandler) ListFences(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	fences, err := h.service.ListFences()
+	if err != nil {
+		writeError(w, geofenceErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, fences)
+}
+
+// GetFence endpoint
+func (h *GeofenceInterfaceHandler) GetFence(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	f, err := h.service.GetFence(ps.ByName("id"))
+	if err != nil {
+		writeError(w, geofenceErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, f)
+}
+
+// CreateFence endpoint
+func (h *GeofenceInterfaceHandler) CreateFence(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var f geofence.Fence
+	if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	f, err := h.service.CreateFence(f)
+	if err != nil {
+		writeError(w, geofenceErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, f)
+}
+
+// UpdateFence endpoint
+func (h *GeofenceInterfaceHandler) UpdateFence(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var f geofence.Fence
+	if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	f, err := h.service.UpdateFence(ps.ByName("id"), f)
+	if err != nil {
+		writeError(w, geofenceErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, f)
+}
+
+// DeleteFence endpoint
+func (h *GeofenceInterfaceHandler) DeleteFence(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := h.service.DeleteFence(ps.ByName("id")); err != nil {
+		writeError(w, geofenceErrorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetEvents endpoint
+func (h *GeofenceInterfaceHandler) GetEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	events, err := h.service.GetEvents()
+	if err != nil {
+		writeError(w, geofenceErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// geofenceErrorStatus maps geofence errors to HTTP status codes
+func geofenceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, geofence.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, geofence.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, errGeofenceDisabled):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -1,54 +1,284 @@
 package API
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gps"
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gpio"
 	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/rfid"
 	"github.com/julienschmidt/httprouter"
 )
 
+var (
+	// errRFIDAuthDisabled is returned when no RFID credential store is configured
+	errRFIDAuthDisabled = errors.New("rfid authorization is disabled")
+	// errRFIDEventsDisabled is returned when no RFID access log is configured
+	errRFIDEventsDisabled = errors.New("rfid access log is disabled")
+	// errGPIODisabled is returned when no GPIO pin map is configured
+	errGPIODisabled = errors.New("gpio is disabled")
+)
+
+const (
+	// streamKeepAlive is how often an idle event stream sends a comment line
+	streamKeepAlive = 15 * time.Second
+	// defaultEnrollTimeout and maxEnrollTimeout bound how long enrollment waits for a tag
+	defaultEnrollTimeout = 30 * time.Second
+	maxEnrollTimeout     = 2 * time.Minute
+)
+
+// EnrollRequest is the body of an enrollment call
+type EnrollRequest struct {
+	Name    string `json:"name"`    // rider the new tag belongs to
+	Timeout string `json:"timeout"` // how long to wait for a tag, e.g. "30s"
+}
+
+// DriveRequest is the body of a simulated pin drive
+type DriveRequest struct {
+	Level string `json:"level"` // "high" or "low"
+}
+
 // HALInterfaceHandler struct to hold interfaces for HAL handling
 type HALInterfaceHandler struct {
 	*httprouter.Router
 	// Embed a HALService to separate stub/live logic
 	service HALServiceInterface
+	token   string // API token required by the credential and enrollment routes
 }
 
 // HALServiceInterface defines methods the HAL service must implement
 type HALServiceInterface interface {
-	GetStatus() map[string]interface{}
+	GetStatus() map[string]hal.DeviceStatus
+	ListCredentials() ([]rfid.Credential, error)
+	GetCredential(id string) (rfid.Credential, error)
+	AddCredential(c rfid.Credential) (rfid.Credential, error)
+	UpdateCredential(id string, c rfid.Credential) (rfid.Credential, error)
+	RevokeCredential(id string) (rfid.Credential, error)
+	DeleteCredential(id string) error
+	GetRFIDEvents(q rfid.EventQuery) ([]rfid.Event, error)
+	SubscribeRFIDEvents(ctx context.Context) (<-chan rfid.Event, error)
+	EnrollTag(ctx context.Context, name string) (rfid.Credential, error)
+	GetPins() ([]gpio.PinState, error)
+	GetPin(name string) (gpio.PinState, error)
+	DrivePin(name string, high bool) error
+	SubscribeInputEvents(ctx context.Context) (<-chan gpio.InputEvent, error)
 }
 
 // StubHALService is a stub implementation
 type StubHALService struct{}
 
-func (s *StubHALService) GetStatus() map[string]interface{} {
-	return map[string]interface{}{
-		"status": "online",
-		"temp":   42,
+func (s *StubHALService) GetStatus() map[string]hal.DeviceStatus {
+	return map[string]hal.DeviceStatus{
+		"stub": {
+			Name:   "stub",
+			Type:   hal.TypeDevice,
+			State:  hal.StateHealthy,
+			Health: hal.Health{State: hal.HealthOK, Metrics: map[string]any{"temp": 42}},
+		},
 	}
 }
 
+func (s *StubHALService) ListCredentials() ([]rfid.Credential, error) {
+	return []rfid.Credential{}, nil
+}
+
+func (s *StubHALService) GetCredential(id string) (rfid.Credential, error) {
+	return rfid.Credential{}, rfid.ErrCredentialNotFound
+}
+
+func (s *StubHALService) AddCredential(c rfid.Credential) (rfid.Credential, error) {
+	return rfid.Credential{}, errRFIDAuthDisabled
+}
+
+func (s *StubHALService) UpdateCredential(id string, c rfid.Credential) (rfid.Credential, error) {
+	return rfid.Credential{}, rfid.ErrCredentialNotFound
+}
+
+func (s *StubHALService) RevokeCredential(id string) (rfid.Credential, error) {
+	return rfid.Credential{}, rfid.ErrCredentialNotFound
+}
+
+func (s *StubHALService) DeleteCredential(id string) error {
+	return rfid.ErrCredentialNotFound
+}
+
+func (s *StubHALService) GetRFIDEvents(q rfid.EventQuery) ([]rfid.Event, error) {
+	return []rfid.Event{}, nil
+}
+
+func (s *StubHALService) SubscribeRFIDEvents(ctx context.Context) (<-chan rfid.Event, error) {
+	return nil, errRFIDEventsDisabled
+}
+
+func (s *StubHALService) EnrollTag(ctx context.Context, name string) (rfid.Credential, error) {
+	return rfid.Credential{}, errRFIDAuthDisabled
+}
+
+func (s *StubHALService) GetPins() ([]gpio.PinState, error) {
+	return []gpio.PinState{}, nil
+}
+
+func (s *StubHALService) GetPin(name string) (gpio.PinState, error) {
+	return gpio.PinState{}, gpio.ErrUnknownPin
+}
+
+func (s *StubHALService) DrivePin(name string, high bool) error {
+	return gpio.ErrNotSimulated
+}
+
+func (s *StubHALService) SubscribeInputEvents(ctx context.Context) (<-chan gpio.InputEvent, error) {
+	return nil, errGPIODisabled
+}
+
 // LiveHALService will hit the real PI firmware
 type LiveHALService struct {
-	RFIDScanner *rfid.RFIDScanner
-	GPS         *gps.GPS
+	Registry *hal.Registry
 }
 
-func (s *LiveHALService) GetStatus() map[string]interface{} {
-	return map[string]interface{}{
-		"Proxmark3 Reader": s.RFIDScanner.Info(),
-		"GPS Module":       s.GPS.Info(),
+func (s *LiveHALService) GetStatus() map[string]hal.DeviceStatus {
+	status := map[string]hal.DeviceStatus{}
+	for _, dev := range s.Registry.Status() {
+		status[dev.Name] = dev
 	}
+	return status
+}
+
+// scanner returns the registered RFID scanner, nil when there is none
+func (s *LiveHALService) scanner() *rfid.RFIDScanner {
+	scanner, _ := hal.Find[*rfid.RFIDScanner](s.Registry)
+	return scanner
 }
 
-// NewHALInterfaceHandler creates a new HAL handler
-func NewHALInterfaceHandler(service HALServiceInterface, router *httprouter.Router) *HALInterfaceHandler {
+// pins returns the registered GPIO controller, nil when there is none
+func (s *LiveHALService) pins() *gpio.Controller {
+	pins, _ := hal.Find[*gpio.Controller](s.Registry)
+	return pins
+}
+
+// auth returns the scanner's credential store
+func (s *LiveHALService) auth() (*rfid.AuthStore, error) {
+	scanner := s.scanner()
+	if scanner == nil || scanner.Auth == nil {
+		return nil, errRFIDAuthDisabled
+	}
+	return scanner.Auth, nil
+}
+
+func (s *LiveHALService) ListCredentials() ([]rfid.Credential, error) {
+	auth, err := s.auth()
+	if err != nil {
+		return nil, err
+	}
+	return auth.List(), nil
+}
+
+func (s *LiveHALService) GetCredential(id string) (rfid.Credential, error) {
+	auth, err := s.auth()
+	if err != nil {
+		return rfid.Credential{}, err
+	}
+	return auth.Get(id)
+}
+
+func (s *LiveHALService) AddCredential(c rfid.Credential) (rfid.Credential, error) {
+	auth, err := s.auth()
+	if err != nil {
+		return rfid.Credential{}, err
+	}
+	return auth.Add(c)
+}
+
+func (s *LiveHALService) UpdateCredential(id string, c rfid.Credential) (rfid.Credential, error) {
+	auth, err := s.auth()
+	if err != nil {
+		return rfid.Credential{}, err
+	}
+	return auth.Update(id, c)
+}
+
+func (s *LiveHALService) RevokeCredential(id string) (rfid.Credential, error) {
+	auth, err := s.auth()
+	if err != nil {
+		return rfid.Credential{}, err
+	}
+	return auth.Revoke(id)
+}
+
+func (s *LiveHALService) DeleteCredential(id string) error {
+	auth, err := s.auth()
+	if err != nil {
+		return err
+	}
+	return auth.Delete(id)
+}
+
+func (s *LiveHALService) GetRFIDEvents(q rfid.EventQuery) ([]rfid.Event, error) {
+	scanner := s.scanner()
+	if scanner == nil || scanner.Events == nil {
+		return nil, errRFIDEventsDisabled
+	}
+	return scanner.Events.Query(q), nil
+}
+
+func (s *LiveHALService) SubscribeRFIDEvents(ctx context.Context) (<-chan rfid.Event, error) {
+	scanner := s.scanner()
+	if scanner == nil || scanner.Events == nil {
+		return nil, errRFIDEventsDisabled
+	}
+	return scanner.Events.Subscribe(ctx), nil
+}
+
+func (s *LiveHALService) EnrollTag(ctx context.Context, name string) (rfid.Credential, error) {
+	if _, err := s.auth(); err != nil {
+		return rfid.Credential{}, err
+	}
+	return s.scanner().Enroll(ctx, name)
+}
+
+func (s *LiveHALService) GetPins() ([]gpio.PinState, error) {
+	pins := s.pins()
+	if pins == nil {
+		return nil, errGPIODisabled
+	}
+	return pins.States(), nil
+}
+
+func (s *LiveHALService) GetPin(name string) (gpio.PinState, error) {
+	pins := s.pins()
+	if pins == nil {
+		return gpio.PinState{}, errGPIODisabled
+	}
+	return pins.State(name)
+}
+
+func (s *LiveHALService) DrivePin(name string, high bool) error {
+	pins := s.pins()
+	if pins == nil {
+		return errGPIODisabled
+	}
+	return pins.Drive(name, high)
+}
+
+func (s *LiveHALService) SubscribeInputEvents(ctx context.Context) (<-chan gpio.InputEvent, error) {
+	pins := s.pins()
+	if pins == nil {
+		return nil, errGPIODisabled
+	}
+	return pins.Subscribe(ctx), nil
+}
+
+// NewHALInterfaceHandler creates a new HAL handler. The RFID credential and
+// enrollment routes require token, see LoadToken.
+func NewHALInterfaceHandler(service HALServiceInterface, router *httprouter.Router, token string) *HALInterfaceHandler {
 	h := &HALInterfaceHandler{
 		Router:  router,
 		service: service,
+		token:   token,
 	}
 
 	h.Router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -56,13 +286,302 @@ func NewHALInterfaceHandler(service HALServiceInterface, router *httprouter.Rout
 	})
 
 	h.Router.GET("/v1/api/hal/status", h.GetHalStatus)
+	h.Router.GET("/v1/api/hal/gpio", h.GetPins)
+	h.Router.GET("/v1/api/hal/gpio/:name", h.GetPin)
+	h.Router.PUT("/v1/api/hal/gpio/:name", h.DrivePin)
+	h.Router.GET("/v1/api/hal/inputs/stream", h.StreamInputEvents)
+	h.Router.GET("/v1/api/hal/rfid/credentials", requireToken(token, h.ListCredentials))
+	h.Router.POST("/v1/api/hal/rfid/credentials", requireToken(token, h.AddCredential))
+	h.Router.GET("/v1/api/hal/rfid/credentials/:id", requireToken(token, h.GetCredential))
+	h.Router.PUT("/v1/api/hal/rfid/credentials/:id", requireToken(token, h.UpdateCredential))
+	h.Router.DELETE("/v1/api/hal/rfid/credentials/:id", requireToken(token, h.DeleteCredential))
+	h.Router.POST("/v1/api/hal/rfid/credentials/:id/revoke", requireToken(token, h.RevokeCredential))
+	h.Router.POST("/v1/api/hal/rfid/enroll", requireToken(token, h.EnrollTag))
+	h.Router.GET("/v1/api/hal/rfid/events", h.GetRFIDEvents)
+	h.Router.GET("/v1/api/hal/rfid/events/stream", h.StreamRFIDEvents)
 
 	return h
 }
 
-// GetHalStatus endpoint
+// GetHalStatus endpoint, reports every HAL device by name, see hal.DeviceStatus
 func (h *HALInterfaceHandler) GetHalStatus(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	status := h.service.GetStatus()
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(status)
+	writeJSON(w, http.StatusOK, h.service.GetStatus())
+}
+
+// GetPins endpoint
+func (h *HALInterfaceHandler) GetPins(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	pins, err := h.service.GetPins()
+	if err != nil {
+		writeError(w, gpioErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pins)
+}
+
+// GetPin endpoint
+func (h *HALInterfaceHandler) GetPin(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	pin, err := h.service.GetPin(ps.ByName("name"))
+	if err != nil {
+		writeError(w, gpioErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pin)
+}
+
+// DrivePin endpoint, sets the level of a pin on the simulated GPIO backend
+func (h *HALInterfaceHandler) DrivePin(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var req DriveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var high bool
+	switch req.Level {
+	case "high":
+		high = true
+	case "low":
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid level %q", req.Level))
+		return
+	}
+	if err := h.service.DrivePin(ps.ByName("name"), high); err != nil {
+		writeError(w, gpioErrorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListCredentials endpoint
+func (h *HALInterfaceHandler) ListCredentials(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	creds, err := h.service.ListCredentials()
+	if err != nil {
+		writeError(w, rfidErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, creds)
+}
+
+// GetCredential endpoint
+func (h *HALInterfaceHandler) GetCredential(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	c, err := h.service.GetCredential(ps.ByName("id"))
+	if err != nil {
+		writeError(w, rfidErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// AddCredential endpoint
+func (h *HALInterfaceHandler) AddCredential(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var c rfid.Credential
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	c, err := h.service.AddCredential(c)
+	if err != nil {
+		writeError(w, rfidErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// UpdateCredential endpoint
+func (h *HALInterfaceHandler) UpdateCredential(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var c rfid.Credential
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	c, err := h.service.UpdateCredential(ps.ByName("id"), c)
+	if err != nil {
+		writeError(w, rfidErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// RevokeCredential endpoint
+func (h *HALInterfaceHandler) RevokeCredential(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	c, err := h.service.RevokeCredential(ps.ByName("id"))
+	if err != nil {
+		writeError(w, rfidErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// DeleteCredential endpoint
+func (h *HALInterfaceHandler) DeleteCredential(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := h.service.DeleteCredential(ps.ByName("id")); err != nil {
+		writeError(w, rfidErrorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EnrollTag endpoint, waits for the next presented tag and returns its new credential
+func (h *HALInterfaceHandler) EnrollTag(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req EnrollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	timeout := defaultEnrollTimeout
+	if req.Timeout != "" {
+		d, err := time.ParseDuration(req.Timeout)
+		if err != nil || d <= 0 || d > maxEnrollTimeout {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("timeout must be a duration up to %s", maxEnrollTimeout))
+			return
+		}
+		timeout = d
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	c, err := h.service.EnrollTag(ctx, req.Name)
+	if err != nil {
+		writeError(w, rfidErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// GetRFIDEvents endpoint, filtered by the optional from/to (RFC 3339),
+// result (authorized or denied) and limit query parameters
+func (h *HALInterfaceHandler) GetRFIDEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	q, err := parseEventQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	events, err := h.service.GetRFIDEvents(q)
+	if err != nil {
+		writeError(w, rfidErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// StreamRFIDEvents endpoint, sends new events as server-sent events
+func (h *HALInterfaceHandler) StreamRFIDEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+	events, err := h.service.SubscribeRFIDEvents(r.Context())
+	if err != nil {
+		writeError(w, rfidErrorStatus(err), err)
+		return
+	}
+	streamEvents(w, r, flusher, events, func(ev rfid.Event) string { return ev.Result })
+}
+
+// StreamInputEvents endpoint, sends GPIO input changes as server-sent events
+func (h *HALInterfaceHandler) StreamInputEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+	events, err := h.service.SubscribeInputEvents(r.Context())
+	if err != nil {
+		writeError(w, gpioErrorStatus(err), err)
+		return
+	}
+	streamEvents(w, r, flusher, events, func(ev gpio.InputEvent) string { return ev.Pin })
+}
+
+// streamEvents writes events as server-sent events named by name until the
+// client goes away, with a keep-alive comment while idle
+func streamEvents[T any](w http.ResponseWriter, r *http.Request, flusher http.Flusher, events <-chan T, name func(T) string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name(ev), data)
+			flusher.Flush()
+		}
+	}
+}
+
+// parseEventQuery reads the access log filters from the query string
+func parseEventQuery(r *http.Request) (rfid.EventQuery, error) {
+	var q rfid.EventQuery
+	values := r.URL.Query()
+	var err error
+	if v := values.Get("from"); v != "" {
+		if q.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if v := values.Get("to"); v != "" {
+		if q.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	switch q.Result = values.Get("result"); q.Result {
+	case "", rfid.ResultAuthorized, rfid.ResultDenied:
+	default:
+		return q, fmt.Errorf("invalid result %q", q.Result)
+	}
+	if v := values.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("invalid limit %q", v)
+		}
+	}
+	return q, nil
+}
+
+// rfidErrorStatus maps RFID errors to HTTP status codes
+func rfidErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, rfid.ErrCredentialNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, rfid.ErrInvalidCredential):
+		return http.StatusBadRequest
+	case errors.Is(err, rfid.ErrEnrollmentBusy):
+		return http.StatusConflict
+	case errors.Is(err, rfid.ErrEnrollmentTimeout):
+		return http.StatusRequestTimeout
+	case errors.Is(err, rfid.ErrUnsupportedTag):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, errRFIDAuthDisabled), errors.Is(err, errRFIDEventsDisabled):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// gpioErrorStatus maps GPIO errors to HTTP status codes
+func gpioErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gpio.ErrUnknownPin):
+		return http.StatusNotFound
+	case errors.Is(err, gpio.ErrNotOutput), errors.Is(err, gpio.ErrNotInput):
+		return http.StatusBadRequest
+	case errors.Is(err, gpio.ErrNotSimulated):
+		return http.StatusConflict
+	case errors.Is(err, errGPIODisabled):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
 }
@@ -2,8 +2,22 @@ package API
 
 import (
 	"encoding/json"
-	"github.com/julienschmidt/httprouter"
+	"errors"
 	"net/http"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/can"
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gps"
+	"github.com/B64-Cryptzo/MotoPi/backend/Services/alarm"
+	"github.com/B64-Cryptzo/MotoPi/backend/Services/trip"
+	"github.com/julienschmidt/httprouter"
+)
+
+var (
+	// errTripsDisabled is returned when no trip recorder is configured
+	errTripsDisabled = errors.New("trip recording is disabled")
+	// errAlarmDisabled is returned when no alarm is configured
+	errAlarmDisabled = errors.New("alarm is disabled")
 )
 
 // MotorcycleInterfaceHandler struct to hold interfaces for Motorcycle handling
@@ -26,6 +40,14 @@ func NewMotorcycleInterfaceHandler(service MotorcycleServiceInterface, router *h
 
 	h.Router.GET("/v1/api/motorcycle/status", h.GetMotorcycleStatus)
 	h.Router.GET("/v1/api/motorcycle/gps", h.GetMotorcycleGPSData)
+	h.Router.GET("/v1/api/motorcycle/trips", h.ListTrips)
+	h.Router.GET("/v1/api/motorcycle/trips/:id", h.GetTrip)
+	h.Router.GET("/v1/api/motorcycle/trips/:id/gpx", h.ExportTrip(trip.FormatGPX))
+	h.Router.GET("/v1/api/motorcycle/trips/:id/geojson", h.ExportTrip(trip.FormatGeoJSON))
+	h.Router.GET("/v1/api/motorcycle/alarm", h.GetAlarmStatus)
+	h.Router.GET("/v1/api/motorcycle/alarm/history", h.GetAlarmHistory)
+	h.Router.POST("/v1/api/motorcycle/alarm/arm", h.ArmAlarm)
+	h.Router.POST("/v1/api/motorcycle/alarm/disarm", h.DisarmAlarm)
 
 	return h
 }
@@ -34,6 +56,13 @@ func NewMotorcycleInterfaceHandler(service MotorcycleServiceInterface, router *h
 type MotorcycleServiceInterface interface {
 	GetStatus() map[string]interface{}
 	GetGPSData() map[string]interface{}
+	ListTrips() ([]trip.Trip, error)
+	GetTrip(id string) (trip.Trip, []trip.Point, error)
+	ExportTrip(id, format string) ([]byte, error)
+	ArmAlarm() error
+	DisarmAlarm() error
+	GetAlarmStatus() (alarm.Status, error)
+	GetAlarmHistory() ([]alarm.Trigger, error)
 }
 
 // StubMotorcycleService is a stub implementation
@@ -47,28 +76,231 @@ func (s *StubMotorcycleService) GetStatus() map[string]interface{} {
 
 func (s *StubMotorcycleService) GetGPSData() map[string]interface{} {
 	return map[string]interface{}{
-		"lat": "1.111",
-		"lng": "2.222",
+		"lat":      1.111,
+		"lng":      2.222,
+		"validFix": true,
 	}
 }
 
+func (s *StubMotorcycleService) ListTrips() ([]trip.Trip, error) {
+	return []trip.Trip{}, nil
+}
+
+func (s *StubMotorcycleService) GetTrip(id string) (trip.Trip, []trip.Point, error) {
+	return trip.Trip{}, nil, trip.ErrNotFound
+}
+
+func (s *StubMotorcycleService) ExportTrip(id, format string) ([]byte, error) {
+	return nil, trip.ErrNotFound
+}
+
+func (s *StubMotorcycleService) ArmAlarm() error {
+	return nil
+}
+
+func (s *StubMotorcycleService) DisarmAlarm() error {
+	return nil
+}
+
+func (s *StubMotorcycleService) GetAlarmStatus() (alarm.Status, error) {
+	return alarm.Status{}, nil
+}
+
+func (s *StubMotorcycleService) GetAlarmHistory() ([]alarm.Trigger, error) {
+	return []alarm.Trigger{}, nil
+}
+
+// IgnitionSense reports the ignition state of the bike
+type IgnitionSense interface {
+	IgnitionOn() (bool, error)
+}
+
+// SwitchSense reports the state of a switch on the bike
+type SwitchSense interface {
+	Active() (bool, error)
+}
+
 // LiveMotorcycleService will hit the real PI firmware
-type LiveMotorcycleService struct{}
+type LiveMotorcycleService struct {
+	GPS        *gps.GPS
+	Trips      *trip.Recorder
+	Alarm      *alarm.Alarm
+	Ignition   IgnitionSense // optional, reported as null when unset
+	KillSwitch SwitchSense   // optional, true while the engine is cut
+	SideStand  SwitchSense   // optional, true while the stand is down
+	Engine     hal.Sensor    // optional CAN engine data, reported as null when unset
+}
 
 func (s *LiveMotorcycleService) GetStatus() map[string]interface{} {
-	// TODO: implement calls to the actual Motorcycle/firmware
-	return map[string]interface{}{
-		"status": "offline",
+	status := map[string]interface{}{
+		"status":      "offline",
+		"position":    nil,
+		"speedKph":    0.0,
+		"headingDeg":  0.0,
+		"validFix":    false,
+		"odometerKm":  0.0,
+		"currentTrip": nil,
+		"ignition":    nil,
+		"killSwitch":  nil,
+		"sideStand":   nil,
+		"engine":      nil,
+		"armed":       false,
+	}
+
+	// A degraded receiver without a fix still delivers sentences
+	gpsOnline := false
+	if s.GPS != nil {
+		state := s.GPS.Health().State
+		gpsOnline = state == hal.HealthOK || state == hal.HealthDegraded
+	}
+	if gpsOnline {
+		status["status"] = "online"
+		if data, err := s.GPS.Read(); err == nil && data.ValidFix {
+			status["position"] = map[string]interface{}{
+				"lat":      data.Latitude,
+				"lng":      data.Longitude,
+				"altitude": data.Altitude,
+			}
+			status["speedKph"] = data.SpeedKph
+			status["headingDeg"] = data.TrackAngle
+			status["validFix"] = true
+			status["estimated"] = data.Estimated
+		}
+	}
+
+	if s.Trips != nil {
+		status["odometerKm"] = s.Trips.OdometerMeters() / 1000
+		if cur, ok := s.Trips.Current(); ok {
+			status["currentTrip"] = cur
+		}
+	}
+
+	if s.Ignition != nil {
+		if on, err := s.Ignition.IgnitionOn(); err == nil {
+			status["ignition"] = on
+		}
+	}
+	if s.KillSwitch != nil {
+		if on, err := s.KillSwitch.Active(); err == nil {
+			status["killSwitch"] = on
+		}
+	}
+	if s.SideStand != nil {
+		if on, err := s.SideStand.Active(); err == nil {
+			status["sideStand"] = on
+		}
+	}
+
+	if s.Engine != nil {
+		if values, err := s.Engine.Read(); err == nil {
+			// Readings the bike does not provide stay null
+			engine := map[string]interface{}{
+				can.ReadingRPM:         nil,
+				can.ReadingCoolantTemp: nil,
+				can.ReadingGear:        nil,
+				can.ReadingThrottle:    nil,
+				can.ReadingBattery:     nil,
+				"dtcs":                 []string{},
+				"mil":                  nil,
+			}
+			for k, v := range values {
+				engine[k] = v
+			}
+			status["engine"] = engine
+		}
 	}
+
+	if s.Alarm != nil {
+		status["armed"] = s.Alarm.Armed()
+	}
+
+	return status
 }
 
 func (s *LiveMotorcycleService) GetGPSData() map[string]interface{} {
+	if s.GPS == nil {
+		return map[string]interface{}{
+			"lat":      0.0,
+			"lng":      0.0,
+			"validFix": false,
+		}
+	}
+
+	data, _ := s.GPS.Read()
+	var timestamp interface{}
+	if !data.Timestamp.IsZero() {
+		timestamp = data.Timestamp
+	}
 	return map[string]interface{}{
-		"lat": "0.0",
-		"lng": "0.0",
+		"lat":              data.Latitude,
+		"lng":              data.Longitude,
+		"altitude":         data.Altitude,
+		"speedKph":         data.SpeedKph,
+		"groundSpeedKph":   data.GroundSpeedKph,
+		"headingDeg":       data.TrackAngle,
+		"timestamp":        timestamp,
+		"validFix":         data.ValidFix,
+		"fixType":          data.FixType,
+		"satellites":       data.Satellites,
+		"hdop":             data.HDOP,
+		"pdop":             data.PDOP,
+		"vdop":             data.VDOP,
+		"satellitesInView": data.SatellitesInView,
+		"estimated":        data.Estimated,
+		"confidence":       data.Confidence,
+		"positionSigma":    data.PositionSigma,
 	}
 }
 
+func (s *LiveMotorcycleService) ListTrips() ([]trip.Trip, error) {
+	if s.Trips == nil {
+		return nil, errTripsDisabled
+	}
+	return s.Trips.List()
+}
+
+func (s *LiveMotorcycleService) GetTrip(id string) (trip.Trip, []trip.Point, error) {
+	if s.Trips == nil {
+		return trip.Trip{}, nil, errTripsDisabled
+	}
+	return s.Trips.Get(id)
+}
+
+func (s *LiveMotorcycleService) ExportTrip(id, format string) ([]byte, error) {
+	if s.Trips == nil {
+		return nil, errTripsDisabled
+	}
+	return s.Trips.Export(id, format)
+}
+
+func (s *LiveMotorcycleService) ArmAlarm() error {
+	if s.Alarm == nil {
+		return errAlarmDisabled
+	}
+	return s.Alarm.Arm()
+}
+
+func (s *LiveMotorcycleService) DisarmAlarm() error {
+	if s.Alarm == nil {
+		return errAlarmDisabled
+	}
+	return s.Alarm.Disarm()
+}
+
+func (s *LiveMotorcycleService) GetAlarmStatus() (alarm.Status, error) {
+	if s.Alarm == nil {
+		return alarm.Status{}, errAlarmDisabled
+	}
+	return s.Alarm.Status(), nil
+}
+
+func (s *LiveMotorcycleService) GetAlarmHistory() ([]alarm.Trigger, error) {
+	if s.Alarm == nil {
+		return nil, errAlarmDisabled
+	}
+	return s.Alarm.History(), nil
+}
+
 // GetMotorcycleStatus endpoint
 func (h *MotorcycleInterfaceHandler) GetMotorcycleStatus(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	status := h.service.GetStatus()
@@ -82,3 +314,102 @@ func (h *MotorcycleInterfaceHandler) GetMotorcycleGPSData(w http.ResponseWriter,
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
 }
+
+// ListTrips endpoint
+func (h *MotorcycleInterfaceHandler) ListTrips(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	trips, err := h.service.ListTrips()
+	if err != nil {
+		writeError(w, tripErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, trips)
+}
+
+// GetTrip endpoint
+func (h *MotorcycleInterfaceHandler) GetTrip(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	t, points, err := h.service.GetTrip(ps.ByName("id"))
+	if err != nil {
+		writeError(w, tripErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"trip":   t,
+		"points": points,
+	})
+}
+
+// ExportTrip returns an endpoint downloading a trip in the given format
+func (h *MotorcycleInterfaceHandler) ExportTrip(format string) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		id := ps.ByName("id")
+		data, err := h.service.ExportTrip(id, format)
+		if err != nil {
+			writeError(w, tripErrorStatus(err), err)
+			return
+		}
+		w.Header().Set("Content-Type", trip.ContentType(format))
+		w.Header().Set("Content-Disposition", `attachment; filename="`+id+"."+format+`"`)
+		w.Write(data)
+	}
+}
+
+// tripErrorStatus maps trip errors to HTTP status codes
+func tripErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, trip.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errTripsDisabled):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// GetAlarmStatus endpoint
+func (h *MotorcycleInterfaceHandler) GetAlarmStatus(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	status, err := h.service.GetAlarmStatus()
+	if err != nil {
+		writeError(w, alarmErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// GetAlarmHistory endpoint
+func (h *MotorcycleInterfaceHandler) GetAlarmHistory(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	history, err := h.service.GetAlarmHistory()
+	if err != nil {
+		writeError(w, alarmErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+// ArmAlarm endpoint
+func (h *MotorcycleInterfaceHandler) ArmAlarm(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if err := h.service.ArmAlarm(); err != nil {
+		writeError(w, alarmErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Alarm armed",
+	})
+}
+
+// DisarmAlarm endpoint
+func (h *MotorcycleInterfaceHandler) DisarmAlarm(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if err := h.service.DisarmAlarm(); err != nil {
+		writeError(w, alarmErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Alarm disarmed",
+	})
+}
+
+// alarmErrorStatus maps alarm errors to HTTP status codes
+func alarmErrorStatus(err error) int {
+	if errors.Is(err, errAlarmDisabled) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
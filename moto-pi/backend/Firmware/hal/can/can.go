@@ -0,0 +1,84 @@
+package can
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Bus types selectable from Config
+const (
+	BusSocketCAN = "socketcan"
+	BusReplay    = "replay"
+)
+
+// MaxDataLength is the payload limit of a classic CAN frame
+const MaxDataLength = 8
+
+// Frame is one classic CAN frame
+type Frame struct {
+	ID       uint32 // 11 bit identifier, 29 bit when Extended
+	Extended bool
+	Data     []byte
+}
+
+func (f Frame) String() string {
+	if f.Extended {
+		return fmt.Sprintf("%08X#%X", f.ID, f.Data)
+	}
+	return fmt.Sprintf("%03X#%X", f.ID, f.Data)
+}
+
+// Conn is an open connection to a bus
+type Conn interface {
+	ReadFrame() (Frame, error) // blocks until a frame arrives or Close
+	WriteFrame(f Frame) error
+	Close() error
+}
+
+// Bus opens connections to a CAN bus for the engine sensor
+type Bus interface {
+	Open() (Conn, error)
+	String() string
+}
+
+// Config selects the bus and what is read from it.
+//
+// SocketCAN interfaces are brought up outside the backend, e.g.
+// "ip link set can0 up type can bitrate 500000". For testing without a bike
+// a virtual interface works the same way: "ip link add dev vcan0 type vcan"
+// and "ip link set vcan0 up", then feed it with canplayer or cansend.
+type Config struct {
+	Type         string         `json:"type"`         // "socketcan" (default) or "replay"
+	Interface    string         `json:"interface"`    // SocketCAN interface, e.g. can0 or vcan0; no engine sensor when empty
+	Path         string         `json:"path"`         // candump -L log for replay
+	Speed        float64        `json:"speed"`        // replay speed multiplier, 0 replays as fast as possible
+	Loop         bool           `json:"loop"`         // restart the replay when the log ends
+	PIDs         []string       `json:"pids"`         // OBD-II readings polled, DefaultPIDs when empty, none with ["none"]
+	PollInterval string         `json:"pollInterval"` // how often each PID is requested, e.g. "500ms"
+	DTCInterval  string         `json:"dtcInterval"`  // how often trouble codes are requested, e.g. "30s"
+	StaleAfter   string         `json:"staleAfter"`   // readings older than this are dropped, e.g. "3s"
+	Signals      []SignalConfig `json:"signals"`      // manufacturer frames broadcast by the ECU
+}
+
+// Enabled reports whether an engine sensor is configured
+func (c Config) Enabled() bool {
+	return c.Interface != "" || (c.Type == BusReplay && c.Path != "")
+}
+
+// NewBus builds the Bus described by cfg
+func NewBus(cfg Config) (Bus, error) {
+	switch cfg.Type {
+	case "", BusSocketCAN:
+		if cfg.Interface == "" {
+			return nil, errors.New("can: socketcan bus requires an interface")
+		}
+		return &SocketCAN{Interface: cfg.Interface}, nil
+	case BusReplay:
+		if cfg.Path == "" {
+			return nil, errors.New("can: replay bus requires a path")
+		}
+		return &ReplayBus{Path: cfg.Path, Speed: cfg.Speed, Loop: cfg.Loop}, nil
+	default:
+		return nil, fmt.Errorf("can: unknown bus type %q", cfg.Type)
+	}
+}
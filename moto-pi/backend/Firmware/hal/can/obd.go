@@ -0,0 +1,206 @@
+package can
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Reading names shared by OBD-II PIDs and manufacturer signals
+const (
+	ReadingRPM         = "rpm"
+	ReadingCoolantTemp = "coolantTempC"
+	ReadingGear        = "gear"
+	ReadingThrottle    = "throttlePct"
+	ReadingBattery     = "batteryV"
+	ReadingSpeed       = "speedKph"
+	ReadingEngineLoad  = "engineLoadPct"
+	ReadingIntakeTemp  = "intakeTempC"
+)
+
+// DefaultPIDs are polled when Config.PIDs is empty
+var DefaultPIDs = []string{ReadingRPM, ReadingCoolantTemp, ReadingThrottle, ReadingBattery, ReadingSpeed}
+
+// noPIDs in Config.PIDs turns polling off, for bikes that only broadcast
+const noPIDs = "none"
+
+// OBD-II on CAN (ISO 15765-4) with 11 bit identifiers
+const (
+	obdRequestID   = 0x7DF // functional request, answered by every ECU
+	obdResponseMin = 0x7E8 // ECU n answers on 0x7E8+n and listens on 0x7E0+n
+	obdResponseMax = 0x7EF
+	obdFlowOffset  = 8
+
+	modeCurrentData  = 0x01
+	modeTroubleCodes = 0x03
+	positiveResponse = 0x40
+
+	pidMonitorStatus = 0x01 // MIL and number of stored trouble codes
+)
+
+var (
+	// ErrUnknownPID is returned for PID names missing from the PID table
+	ErrUnknownPID = errors.New("unknown OBD-II PID")
+	// errSequence is returned when a multi-frame response lost a frame
+	errSequence = errors.New("can: multi-frame response out of sequence")
+)
+
+// pid decodes one mode 01 parameter from its data bytes A, B, ...
+type pid struct {
+	code   byte
+	bytes  int
+	decode func(b []byte) (float64, bool)
+}
+
+// pids maps reading names to the mode 01 PIDs providing them
+var pids = map[string]pid{
+	ReadingEngineLoad: {0x04, 1, func(b []byte) (float64, bool) {
+		return float64(b[0]) * 100 / 255, true
+	}},
+	ReadingCoolantTemp: {0x05, 1, func(b []byte) (float64, bool) {
+		return float64(b[0]) - 40, true
+	}},
+	ReadingRPM: {0x0C, 2, func(b []byte) (float64, bool) {
+		return float64(uint16(b[0])<<8|uint16(b[1])) / 4, true
+	}},
+	ReadingSpeed: {0x0D, 1, func(b []byte) (float64, bool) {
+		return float64(b[0]), true
+	}},
+	ReadingIntakeTemp: {0x0F, 1, func(b []byte) (float64, bool) {
+		return float64(b[0]) - 40, true
+	}},
+	ReadingThrottle: {0x11, 1, func(b []byte) (float64, bool) {
+		return float64(b[0]) * 100 / 255, true
+	}},
+	ReadingBattery: {0x42, 2, func(b []byte) (float64, bool) {
+		return float64(uint16(b[0])<<8|uint16(b[1])) / 1000, true
+	}},
+	// Transmission actual gear, bit 1 of A flags support, the gear is in the
+	// high nibble of B and C-D hold the gear ratio
+	ReadingGear: {0xA4, 4, func(b []byte) (float64, bool) {
+		return float64(b[1] >> 4), b[0]&0x02 != 0
+	}},
+}
+
+// resolvePIDs looks up the configured PID names
+func resolvePIDs(names []string) ([]byte, error) {
+	if len(names) == 0 {
+		names = DefaultPIDs
+	}
+	var codes []byte
+	for _, name := range names {
+		if name == noPIDs {
+			return nil, nil
+		}
+		p, ok := pids[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPID, name)
+		}
+		codes = append(codes, p.code)
+	}
+	return codes, nil
+}
+
+// decodePID converts the data bytes of a mode 01 response
+func decodePID(code byte, data []byte) (string, float64, bool) {
+	for name, p := range pids {
+		if p.code != code || len(data) < p.bytes {
+			continue
+		}
+		v, ok := p.decode(data)
+		return name, v, ok
+	}
+	return "", 0, false
+}
+
+// obdRequest builds a single frame request, padded to a full frame
+func obdRequest(payload ...byte) Frame {
+	data := make([]byte, MaxDataLength)
+	data[0] = byte(len(payload))
+	copy(data[1:], payload)
+	return Frame{ID: obdRequestID, Data: data}
+}
+
+// flowControl tells the ECU answering on id to send the rest of a response
+// without waiting
+func flowControl(id uint32) Frame {
+	data := make([]byte, MaxDataLength)
+	data[0] = 0x30
+	return Frame{ID: id - obdFlowOffset, Data: data}
+}
+
+// isOBDResponse reports whether f comes from an ECU answering a request
+func isOBDResponse(f Frame) bool {
+	return !f.Extended && f.ID >= obdResponseMin && f.ID <= obdResponseMax
+}
+
+// transfer reassembles a response split over several frames (ISO 15765-2)
+type transfer struct {
+	size    int
+	payload []byte
+	next    byte
+}
+
+// feed adds a frame of one ECU's responses. It returns the complete payload
+// once known, and whether a flow control frame must be sent.
+func (t *transfer) feed(data []byte) ([]byte, bool, error) {
+	if len(data) == 0 {
+		return nil, false, nil
+	}
+	switch data[0] >> 4 {
+	case 0: // single frame
+		n := int(data[0] & 0x0F)
+		if n == 0 || n > len(data)-1 {
+			return nil, false, fmt.Errorf("can: invalid single frame length %d", n)
+		}
+		t.payload = nil
+		return data[1 : 1+n], false, nil
+	case 1: // first frame
+		if len(data) < 2 {
+			return nil, false, errors.New("can: truncated first frame")
+		}
+		t.size = int(data[0]&0x0F)<<8 | int(data[1])
+		t.payload = append([]byte(nil), data[2:]...)
+		t.next = 1
+		return nil, true, nil
+	case 2: // consecutive frame
+		if t.payload == nil {
+			return nil, false, nil
+		}
+		if data[0]&0x0F != t.next {
+			t.payload = nil
+			return nil, false, errSequence
+		}
+		t.next = (t.next + 1) & 0x0F
+		t.payload = append(t.payload, data[1:]...)
+		if len(t.payload) < t.size {
+			return nil, false, nil
+		}
+		payload := t.payload[:t.size]
+		t.payload = nil
+		return payload, false, nil
+	}
+	// Flow control frames are for the other direction
+	return nil, false, nil
+}
+
+// decodeDTCs reads the trouble codes of a mode 03 response after the mode
+// byte: a count followed by two bytes per code
+func decodeDTCs(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	count, codes := int(data[0]), data[1:]
+	dtcs := []string{}
+	for i := 0; i+1 < len(codes) && len(dtcs) < count; i += 2 {
+		if codes[i] == 0 && codes[i+1] == 0 {
+			continue
+		}
+		dtcs = append(dtcs, formatDTC(codes[i], codes[i+1]))
+	}
+	return dtcs
+}
+
+// formatDTC spells a trouble code, e.g. 0x03 0x01 as P0301
+func formatDTC(a, b byte) string {
+	return fmt.Sprintf("%c%d%X%X%X", "PCBU"[a>>6], (a>>4)&0x03, a&0x0F, b>>4, b&0x0F)
+}
@@ -0,0 +1,210 @@
+package can
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestDecodePID(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     byte
+		data     []byte
+		wantName string
+		want     float64
+		wantOK   bool
+	}{
+		{"rpm", 0x0C, []byte{0x1A, 0xF8}, ReadingRPM, 1726, true},
+		{"rpm idle", 0x0C, []byte{0x0B, 0xB8}, ReadingRPM, 750, true},
+		{"coolant", 0x05, []byte{0x7B}, ReadingCoolantTemp, 83, true},
+		{"coolant below zero", 0x05, []byte{0x1E}, ReadingCoolantTemp, -10, true},
+		{"throttle closed", 0x11, []byte{0x00}, ReadingThrottle, 0, true},
+		{"throttle wide open", 0x11, []byte{0xFF}, ReadingThrottle, 100, true},
+		{"battery", 0x42, []byte{0x3A, 0x98}, ReadingBattery, 15, true},
+		{"gear", 0xA4, []byte{0x02, 0x30, 0x01, 0x00}, ReadingGear, 3, true},
+		{"gear neutral", 0xA4, []byte{0x02, 0x00, 0x00, 0x00}, ReadingGear, 0, true},
+		{"gear not supported", 0xA4, []byte{0x00, 0x30, 0x01, 0x00}, ReadingGear, 3, false},
+		{"gear without ratio bytes", 0xA4, []byte{0x02, 0x30}, "", 0, false},
+		{"rpm too short", 0x0C, []byte{0x1A}, "", 0, false},
+		{"unknown pid", 0x99, []byte{0x01, 0x02}, "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, got, ok := decodePID(tt.code, tt.data)
+			if name != tt.wantName || ok != tt.wantOK || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("decodePID(%#x, % X) = %q, %v, %v, want %q, %v, %v",
+					tt.code, tt.data, name, got, ok, tt.wantName, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestResolvePIDs(t *testing.T) {
+	codes, err := resolvePIDs(nil)
+	if err != nil || !bytes.Equal(codes, []byte{0x0C, 0x05, 0x11, 0x42, 0x0D}) {
+		t.Errorf("resolvePIDs(nil) = % X, %v, want the defaults", codes, err)
+	}
+	if codes, err := resolvePIDs([]string{noPIDs}); err != nil || codes != nil {
+		t.Errorf("resolvePIDs(none) = % X, %v, want no PIDs", codes, err)
+	}
+	if _, err := resolvePIDs([]string{ReadingRPM, "boost"}); !errors.Is(err, ErrUnknownPID) {
+		t.Errorf("resolvePIDs(boost) error = %v, want %v", err, ErrUnknownPID)
+	}
+}
+
+func TestTransferFeed(t *testing.T) {
+	// A mode 03 response with three trouble codes, too long for one frame
+	dtcResponse := []byte{0x43, 0x03, 0x01, 0x03, 0xC1, 0x23, 0x00, 0x45, 0x01, 0x71}
+
+	tests := []struct {
+		name     string
+		frames   [][]byte
+		want     []byte
+		wantFlow []bool
+		wantErr  error
+	}{
+		{
+			name:     "single frame",
+			frames:   [][]byte{{0x04, 0x41, 0x0C, 0x1A, 0xF8, 0xAA, 0xAA, 0xAA}},
+			want:     []byte{0x41, 0x0C, 0x1A, 0xF8},
+			wantFlow: []bool{false},
+		},
+		{
+			name: "multi-frame",
+			frames: [][]byte{
+				{0x10, 0x0A, 0x43, 0x03, 0x01, 0x03, 0xC1, 0x23},
+				{0x21, 0x00, 0x45, 0x01, 0x71, 0xAA, 0xAA, 0xAA},
+			},
+			want:     dtcResponse,
+			wantFlow: []bool{true, false},
+		},
+		{
+			name: "multi-frame over three frames",
+			frames: [][]byte{
+				{0x10, 0x10, 0, 1, 2, 3, 4, 5},
+				{0x21, 6, 7, 8, 9, 10, 11, 12},
+				{0x22, 13, 14, 15, 0xAA, 0xAA, 0xAA, 0xAA},
+			},
+			want:     []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+			wantFlow: []bool{true, false, false},
+		},
+		{
+			name: "lost consecutive frame",
+			frames: [][]byte{
+				{0x10, 0x10, 0, 1, 2, 3, 4, 5},
+				{0x22, 13, 14, 15, 0xAA, 0xAA, 0xAA, 0xAA},
+			},
+			wantFlow: []bool{true, false},
+			wantErr:  errSequence,
+		},
+		{
+			name:     "consecutive frame without a first frame",
+			frames:   [][]byte{{0x21, 0x00, 0x45}},
+			wantFlow: []bool{false},
+		},
+		{
+			name:     "flow control frame",
+			frames:   [][]byte{{0x30, 0x00, 0x00}},
+			wantFlow: []bool{false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tr transfer
+			var got []byte
+			var err error
+			for i, f := range tt.frames {
+				var payload []byte
+				var flow bool
+				payload, flow, err = tr.feed(f)
+				if flow != tt.wantFlow[i] {
+					t.Errorf("frame %d: flow = %v, want %v", i, flow, tt.wantFlow[i])
+				}
+				if payload != nil {
+					got = payload
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("feed() error = %v, want %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("payload = % X, want % X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransferFeedInvalid(t *testing.T) {
+	for _, data := range [][]byte{
+		{0x00, 0x41},       // single frame without data
+		{0x07, 0x41, 0x0C}, // single frame longer than the frame
+		{0x10},             // truncated first frame
+	} {
+		var tr transfer
+		if _, _, err := tr.feed(data); err == nil {
+			t.Errorf("feed(% X) accepted an invalid frame", data)
+		}
+	}
+
+	// A transfer recovers with the next first frame after a lost frame
+	var tr transfer
+	tr.feed([]byte{0x10, 0x08, 0x43, 0x03, 0x01, 0x03, 0xC1, 0x23})
+	if _, _, err := tr.feed([]byte{0x22, 0x00, 0x45}); !errors.Is(err, errSequence) {
+		t.Fatalf("feed() error = %v, want %v", err, errSequence)
+	}
+	tr.feed([]byte{0x10, 0x08, 0x43, 0x03, 0x01, 0x03, 0xC1, 0x23})
+	payload, _, err := tr.feed([]byte{0x21, 0x00, 0x45})
+	if err != nil || len(payload) != 8 {
+		t.Errorf("feed() after a new first frame = % X, %v", payload, err)
+	}
+}
+
+func TestDecodeDTCs(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []string
+	}{
+		{"none stored", []byte{0x00}, []string{}},
+		{"one code", []byte{0x01, 0x03, 0x01}, []string{"P0301"}},
+		{"every system", []byte{0x04, 0x01, 0x71, 0x41, 0x00, 0x81, 0x23, 0xC1, 0x23}, []string{"P0171", "C0100", "B0123", "U0123"}},
+		{"padding pairs skipped", []byte{0x02, 0x03, 0x01, 0x00, 0x00, 0x01, 0x71}, []string{"P0301", "P0171"}},
+		{"count limits the codes", []byte{0x01, 0x03, 0x01, 0x01, 0x71}, []string{"P0301"}},
+		{"odd trailing byte", []byte{0x02, 0x03, 0x01, 0x01}, []string{"P0301"}},
+		{"empty", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeDTCs(tt.data)
+			if !slices.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+				t.Errorf("decodeDTCs(% X) = %q, want %q", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDTC(t *testing.T) {
+	tests := []struct {
+		a, b byte
+		want string
+	}{
+		{0x03, 0x01, "P0301"},
+		{0x01, 0x71, "P0171"},
+		{0x1A, 0xBC, "P1ABC"},
+		{0x41, 0x00, "C0100"},
+		{0x81, 0x23, "B0123"},
+		{0xC1, 0x23, "U0123"},
+		{0xFF, 0xFF, "U3FFF"},
+	}
+	for _, tt := range tests {
+		if got := formatDTC(tt.a, tt.b); got != tt.want {
+			t.Errorf("formatDTC(%#02x, %#02x) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package can
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplayBus replays a log recorded with "candump -L", pacing frames by their
+// timestamps divided by Speed. A Speed of 0 replays without delays. Frames
+// written to the bus are discarded, the log already holds the answers.
+type ReplayBus struct {
+	Path  string
+	Speed float64
+	Loop  bool
+}
+
+// Open opens the log and starts the replay
+func (b *ReplayBus) Open() (Conn, error) {
+	f, err := os.Open(b.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &replayConn{bus: b, file: f, lines: bufio.NewScanner(f), done: make(chan struct{})}, nil
+}
+
+func (b *ReplayBus) String() string {
+	return fmt.Sprintf("replay %s (x%g)", b.Path, b.Speed)
+}
+
+// replayConn hands out the logged frames one by one
+type replayConn struct {
+	bus     *ReplayBus
+	file    *os.File
+	lines   *bufio.Scanner
+	last    float64
+	hasLast bool
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func (c *replayConn) ReadFrame() (Frame, error) {
+	for {
+		select {
+		case <-c.done:
+			return Frame{}, net.ErrClosed
+		default:
+		}
+
+		if !c.lines.Scan() {
+			if err := c.lines.Err(); err != nil {
+				return Frame{}, err
+			}
+			if !c.bus.Loop {
+				return Frame{}, io.EOF
+			}
+			if _, err := c.file.Seek(0, io.SeekStart); err != nil {
+				return Frame{}, err
+			}
+			c.lines = bufio.NewScanner(c.file)
+			c.hasLast = false
+			continue
+		}
+
+		ts, f, ok := parseLogLine(c.lines.Text())
+		if !ok {
+			continue
+		}
+		if err := c.pace(ts); err != nil {
+			return Frame{}, err
+		}
+		return f, nil
+	}
+}
+
+// pace sleeps for the gap between the previous and current frame
+func (c *replayConn) pace(ts float64) error {
+	if c.bus.Speed > 0 && c.hasLast && ts > c.last {
+		delay := time.Duration((ts - c.last) / c.bus.Speed * float64(time.Second))
+		select {
+		case <-time.After(delay):
+		case <-c.done:
+			return net.ErrClosed
+		}
+	}
+	c.last, c.hasLast = ts, true
+	return nil
+}
+
+// WriteFrame discards f
+func (c *replayConn) WriteFrame(f Frame) error {
+	return nil
+}
+
+func (c *replayConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.file.Close()
+}
+
+// parseLogLine reads a candump -L line, e.g. "(1697040000.123456) can0 7E8#04410C1AF8".
+// Remote and CAN FD frames are skipped.
+func parseLogLine(line string) (float64, Frame, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[0], "(") {
+		return 0, Frame{}, false
+	}
+	ts, err := strconv.ParseFloat(strings.Trim(fields[0], "()"), 64)
+	if err != nil {
+		return 0, Frame{}, false
+	}
+	id, data, ok := strings.Cut(fields[2], "#")
+	if !ok || strings.HasPrefix(data, "#") || strings.HasPrefix(data, "R") {
+		return 0, Frame{}, false
+	}
+
+	n, err := strconv.ParseUint(id, 16, 32)
+	if err != nil {
+		return 0, Frame{}, false
+	}
+	payload, err := hex.DecodeString(data)
+	if err != nil || len(payload) > MaxDataLength {
+		return 0, Frame{}, false
+	}
+	return ts, Frame{ID: uint32(n), Extended: len(id) > 3, Data: payload}, true
+}
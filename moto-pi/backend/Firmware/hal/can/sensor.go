@@ -0,0 +1,430 @@
+package can
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
+)
+
+// Defaults for Config durations
+const (
+	DefaultPollInterval = 500 * time.Millisecond
+	DefaultDTCInterval  = 30 * time.Second
+	DefaultStaleAfter   = 3 * time.Second
+)
+
+// minRequestGap keeps polling from flooding the bus when many PIDs are set
+const minRequestGap = 10 * time.Millisecond
+
+// Sensor reads engine data from a CAN bus: it polls OBD-II PIDs and trouble
+// codes and decodes manufacturer frames the ECU broadcasts
+type Sensor struct {
+	bus      Bus
+	pids     []byte
+	signals  []signal
+	poll     time.Duration
+	dtcEvery time.Duration
+	stale    time.Duration
+
+	// life serialises Init and Close
+	life    sync.Mutex
+	conn    Conn
+	cancel  context.CancelFunc
+	stopped chan struct{} // closed when the reader and poller exit
+
+	// owned by the reader
+	transfers map[uint32]*transfer
+
+	mu        sync.RWMutex
+	open      bool
+	err       error // read error that stopped the reader
+	lastFrame time.Time
+	readings  map[string]reading
+	dtcs      map[uint32][]string // stored trouble codes by answering ECU
+	mil       *bool               // malfunction indicator lamp, nil until reported
+	counts    map[string]uint64
+}
+
+// reading is one decoded value and when it arrived
+type reading struct {
+	value float64
+	time  time.Time
+}
+
+// Ensure Sensor implements hal.ContextSensor and can be supervised
+var (
+	_ hal.ContextSensor = (*Sensor)(nil)
+	_ hal.ContextDevice = (*Sensor)(nil)
+	_ hal.Monitored     = (*Sensor)(nil)
+)
+
+// NewSensor validates cfg, the bus is not opened until Init
+func NewSensor(cfg Config) (*Sensor, error) {
+	bus, err := NewBus(cfg)
+	if err != nil {
+		return nil, err
+	}
+	codes, err := resolvePIDs(cfg.PIDs)
+	if err != nil {
+		return nil, fmt.Errorf("can: %w", err)
+	}
+	signals, err := parseSignals(cfg.Signals)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sensor{
+		bus:      bus,
+		pids:     codes,
+		signals:  signals,
+		poll:     DefaultPollInterval,
+		dtcEvery: DefaultDTCInterval,
+		stale:    DefaultStaleAfter,
+	}
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"pollInterval", cfg.PollInterval, &s.poll},
+		{"dtcInterval", cfg.DTCInterval, &s.dtcEvery},
+		{"staleAfter", cfg.StaleAfter, &s.stale},
+	} {
+		if f.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f.value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("can: invalid %s %q", f.name, f.value)
+		}
+		*f.dst = d
+	}
+	return s, nil
+}
+
+// Init opens the bus and starts reading and polling
+func (s *Sensor) Init() error {
+	return s.InitContext(context.Background())
+}
+
+// InitContext opens the bus unless ctx is already done and starts reading
+// and polling until Close
+func (s *Sensor) InitContext(ctx context.Context) error {
+	s.life.Lock()
+	defer s.life.Unlock()
+	if s.cancel != nil {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	conn, err := s.bus.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open CAN bus %s: %w", s.bus, err)
+	}
+	s.conn = conn
+	s.transfers = make(map[uint32]*transfer)
+	s.mu.Lock()
+	s.open, s.err = true, nil
+	s.readings = make(map[string]reading)
+	s.dtcs = make(map[uint32][]string)
+	s.mil = nil
+	s.mu.Unlock()
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.stopped = make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.readLoop(runCtx, conn)
+	}()
+	go func() {
+		defer wg.Done()
+		s.pollLoop(runCtx, conn)
+	}()
+	go func(stopped chan struct{}) {
+		wg.Wait()
+		close(stopped)
+	}(s.stopped)
+
+	return nil
+}
+
+// Close stops reading and closes the bus
+func (s *Sensor) Close() error {
+	return s.CloseContext(context.Background())
+}
+
+// CloseContext stops reading and closes the bus, waiting for the reader and
+// poller to exit until ctx is done
+func (s *Sensor) CloseContext(ctx context.Context) error {
+	s.life.Lock()
+	defer s.life.Unlock()
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	// Closing the connection unblocks a pending read
+	err := s.conn.Close()
+	stopped := s.stopped
+	s.cancel, s.conn, s.stopped = nil, nil, nil
+	s.mu.Lock()
+	s.open = false
+	s.mu.Unlock()
+
+	select {
+	case <-stopped:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("can: reader did not stop: %w", ctx.Err())
+	}
+}
+
+// Info returns online/offline status
+func (s *Sensor) Info() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	switch {
+	case !s.open:
+		return "offline"
+	case s.err != nil:
+		return "failed: " + s.err.Error()
+	case time.Since(s.lastFrame) > s.stale:
+		return "online (no frames)"
+	}
+	return fmt.Sprintf("online (%d readings)", len(s.fresh()))
+}
+
+// Health reports the bus, degraded while no frames arrive
+func (s *Sensor) Health() hal.Health {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h := hal.Health{
+		State:    hal.HealthOK,
+		LastSeen: hal.LastSeen(s.lastFrame),
+		Counters: make(map[string]uint64, len(s.counts)),
+		Metrics: map[string]any{
+			"bus":      s.bus.String(),
+			"readings": len(s.fresh()),
+			"dtcs":     len(s.troubleCodes()),
+		},
+	}
+	for k, v := range s.counts {
+		h.Counters[k] = v
+	}
+	if s.mil != nil {
+		h.Metrics["mil"] = *s.mil
+	}
+	switch {
+	case s.err != nil:
+		h.State, h.Error = hal.HealthFailed, s.err.Error()
+	case !s.open:
+		h.State = hal.HealthOffline
+	case time.Since(s.lastFrame) > s.stale:
+		h.State, h.Error = hal.HealthDegraded, "no frames"
+	}
+	return h
+}
+
+// Err returns the read error that stopped the reader, nil while it runs
+func (s *Sensor) Err() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.err
+}
+
+// Read returns the readings that are not stale by name, e.g. "rpm", plus
+// "dtcs" with the stored trouble codes and "mil" once an ECU reported them
+func (s *Sensor) Read() (map[string]any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.open {
+		return nil, errors.New("can: not initialised")
+	}
+
+	values := make(map[string]any)
+	for name, v := range s.fresh() {
+		values[name] = v
+	}
+	if len(s.dtcs) > 0 {
+		values["dtcs"] = s.troubleCodes()
+	}
+	if s.mil != nil {
+		values["mil"] = *s.mil
+	}
+	return values, nil
+}
+
+// ReadContext returns the readings unless ctx is done, they are kept up to
+// date in the background so reading never blocks
+func (s *Sensor) ReadContext(ctx context.Context) (map[string]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Read()
+}
+
+// fresh returns the values younger than the stale limit, s.mu must be held
+func (s *Sensor) fresh() map[string]float64 {
+	values := make(map[string]float64, len(s.readings))
+	for name, r := range s.readings {
+		if time.Since(r.time) <= s.stale {
+			values[name] = r.value
+		}
+	}
+	return values
+}
+
+// troubleCodes merges the codes of every ECU, s.mu must be held
+func (s *Sensor) troubleCodes() []string {
+	dtcs := []string{}
+	for _, codes := range s.dtcs {
+		dtcs = append(dtcs, codes...)
+	}
+	slices.Sort(dtcs)
+	return slices.Compact(dtcs)
+}
+
+// readLoop decodes frames until ctx is done, the bus fails or a replay ends
+func (s *Sensor) readLoop(ctx context.Context, conn Conn) {
+	for {
+		f, err := conn.ReadFrame()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return
+			}
+			// A failed interface stays down, leave reopening it to the supervisor
+			fmt.Println("CAN read error:", err)
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+			return
+		}
+		s.handle(conn, f)
+	}
+}
+
+// handle decodes one frame
+func (s *Sensor) handle(conn Conn, f Frame) {
+	now := time.Now()
+	s.mu.Lock()
+	s.lastFrame = now
+	s.countLocked("frames")
+	for _, sig := range s.signals {
+		if v, ok := sig.decode(f); ok {
+			s.readings[sig.name] = reading{v, now}
+		}
+	}
+	s.mu.Unlock()
+
+	if !isOBDResponse(f) {
+		return
+	}
+	t, ok := s.transfers[f.ID]
+	if !ok {
+		t = &transfer{}
+		s.transfers[f.ID] = t
+	}
+	payload, flow, err := t.feed(f.Data)
+	if err != nil {
+		s.count("decodeErrors")
+		return
+	}
+	if flow {
+		if err := conn.WriteFrame(flowControl(f.ID)); err != nil {
+			s.count("writeErrors")
+		}
+	}
+	if payload != nil {
+		s.response(f.ID, payload, now)
+	}
+}
+
+// response records a complete OBD-II response from the ECU answering on id
+func (s *Sensor) response(id uint32, payload []byte, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.countLocked("responses")
+	switch payload[0] {
+	case modeCurrentData | positiveResponse:
+		if len(payload) < 3 {
+			s.countLocked("decodeErrors")
+			return
+		}
+		if payload[1] == pidMonitorStatus {
+			mil := payload[2]&0x80 != 0
+			s.mil = &mil
+			return
+		}
+		if name, v, ok := decodePID(payload[1], payload[2:]); ok {
+			s.readings[name] = reading{v, now}
+		}
+	case modeTroubleCodes | positiveResponse:
+		s.dtcs[id] = decodeDTCs(payload[1:])
+	}
+}
+
+// pollLoop requests the PIDs in turn, spread over the poll interval, and the
+// trouble codes every dtcEvery
+func (s *Sensor) pollLoop(ctx context.Context, conn Conn) {
+	var next <-chan time.Time
+	if len(s.pids) > 0 && s.poll > 0 {
+		ticker := time.NewTicker(max(s.poll/time.Duration(len(s.pids)), minRequestGap))
+		defer ticker.Stop()
+		next = ticker.C
+	}
+	var dtc <-chan time.Time
+	if s.dtcEvery > 0 {
+		ticker := time.NewTicker(s.dtcEvery)
+		defer ticker.Stop()
+		dtc = ticker.C
+		s.request(conn, obdRequest(modeCurrentData, pidMonitorStatus), obdRequest(modeTroubleCodes))
+	}
+
+	for i := 0; ; {
+		select {
+		case <-ctx.Done():
+			return
+		case <-next:
+			s.request(conn, obdRequest(modeCurrentData, s.pids[i]))
+			i = (i + 1) % len(s.pids)
+		case <-dtc:
+			s.request(conn, obdRequest(modeCurrentData, pidMonitorStatus), obdRequest(modeTroubleCodes))
+		}
+	}
+}
+
+// request sends frames to the ECUs. Write errors only count, a bus without
+// listeners (ignition off) refuses frames until the bike is switched on.
+func (s *Sensor) request(conn Conn, frames ...Frame) {
+	for _, f := range frames {
+		if err := conn.WriteFrame(f); err != nil {
+			s.count("writeErrors")
+			continue
+		}
+		s.count("requests")
+	}
+}
+
+// count increments a health counter
+func (s *Sensor) count(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.countLocked(name)
+}
+
+func (s *Sensor) countLocked(name string) {
+	if s.counts == nil {
+		s.counts = make(map[string]uint64)
+	}
+	s.counts[name]++
+}
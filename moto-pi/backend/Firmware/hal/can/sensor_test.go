@@ -0,0 +1,281 @@
+package can
+
+import (
+	"bytes"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
+)
+
+// fakeConn feeds frames to a sensor and records the frames it sends
+type fakeConn struct {
+	frames chan Frame
+
+	mu      sync.Mutex
+	written []Frame
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{frames: make(chan Frame), done: make(chan struct{})}
+}
+
+func (c *fakeConn) ReadFrame() (Frame, error) {
+	select {
+	case f := <-c.frames:
+		return f, nil
+	case <-c.done:
+		return Frame{}, net.ErrClosed
+	}
+}
+
+func (c *fakeConn) WriteFrame(f Frame) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written = append(c.written, f)
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return nil
+}
+
+// sent returns the frames written to the connection
+func (c *fakeConn) sent() []Frame {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Frame(nil), c.written...)
+}
+
+// fakeBus opens its one connection
+type fakeBus struct {
+	conn *fakeConn
+}
+
+func (b *fakeBus) Open() (Conn, error) { return b.conn, nil }
+func (b *fakeBus) String() string      { return "fake" }
+
+// newTestSensor builds a sensor from cfg and initialises it
+func newTestSensor(t *testing.T, cfg Config) *Sensor {
+	t.Helper()
+	s, err := NewSensor(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// waitRead polls the sensor until done accepts its readings
+func waitRead(t *testing.T, s *Sensor, done func(map[string]any) bool) map[string]any {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		values, err := s.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if done(values) {
+			return values
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Read() = %v", values)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// writeLog writes a candump -L log to a temporary file
+func writeLog(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "candump.log")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSensorReplay(t *testing.T) {
+	path := writeLog(t,
+		"(1697040000.000000) can0 7E8#04410C1AF8AAAAAA", // rpm 1726
+		"(1697040000.010000) can0 7E8#0341057BAAAAAAAA", // coolant 83 °C
+		"(1697040000.020000) can0 7E8#03411180AAAAAAAA", // throttle 50 %
+		"(1697040000.030000) can0 7E8#0441423A98AAAAAA", // battery 15 V
+		"(1697040000.040000) can0 7E8#0641A402300100AA", // 3rd gear
+		"(1697040000.050000) can0 7E8#0641018307E500AA", // MIL on, three codes
+		"(1697040000.060000) can0 7E8#100A430301034123", // first frame of mode 03
+		"(1697040000.070000) can0 7E8#2100450171AAAAAA", // consecutive frame
+		"(1697040000.080000) can0 540#0000A50000000000", // broadcast oil temperature
+		"(1697040000.090000) can0 7E9#03410570AAAAAAAA", // a second ECU
+		"garbage line",
+	)
+	s := newTestSensor(t, Config{
+		Type:        BusReplay,
+		Path:        path,
+		PIDs:        []string{noPIDs},
+		DTCInterval: "0",
+		Signals:     []SignalConfig{{Name: "oilTempC", ID: "0x540", Start: 2, Offset: -40}},
+	})
+
+	values := waitRead(t, s, func(v map[string]any) bool { return v["oilTempC"] != nil && v[ReadingCoolantTemp] == 72.0 })
+	for name, want := range map[string]float64{
+		ReadingRPM:         1726,
+		ReadingCoolantTemp: 72, // the later answer wins
+		ReadingThrottle:    128 * 100.0 / 255,
+		ReadingBattery:     15,
+		ReadingGear:        3,
+		"oilTempC":         125,
+	} {
+		if got, ok := values[name].(float64); !ok || math.Abs(got-want) > 1e-9 {
+			t.Errorf("Read()[%s] = %v, want %v", name, values[name], want)
+		}
+	}
+	if dtcs, _ := values["dtcs"].([]string); !slices.Equal(dtcs, []string{"C0123", "P0045", "P0103"}) {
+		t.Errorf("Read()[dtcs] = %v, want [C0123 P0045 P0103]", values["dtcs"])
+	}
+	if mil, _ := values["mil"].(bool); !mil {
+		t.Errorf("Read()[mil] = %v, want true", values["mil"])
+	}
+
+	h := s.Health()
+	if h.State != hal.HealthOK || h.Counters["frames"] != 10 || h.Counters["responses"] != 8 || h.Counters["decodeErrors"] != 0 {
+		t.Errorf("Health() = %+v, want ok with 10 frames and 8 responses", h)
+	}
+}
+
+func TestSensorFlowControl(t *testing.T) {
+	conn := newFakeConn()
+	s, err := NewSensor(Config{Type: BusReplay, Path: "unused", PIDs: []string{noPIDs}, DTCInterval: "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.bus = &fakeBus{conn}
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// A lost consecutive frame drops the response, the next one goes through
+	for _, f := range []Frame{
+		{ID: 0x7E9, Data: []byte{0x10, 0x0A, 0x43, 0x03, 0x01, 0x03, 0x41, 0x23}},
+		{ID: 0x7E9, Data: []byte{0x22, 0x00, 0x45, 0x01, 0x71, 0xAA, 0xAA, 0xAA}},
+		{ID: 0x7E9, Data: []byte{0x10, 0x08, 0x43, 0x03, 0x01, 0x03, 0x01, 0x71}},
+		{ID: 0x7E9, Data: []byte{0x21, 0x00, 0x45, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA}},
+	} {
+		conn.frames <- f
+	}
+
+	values := waitRead(t, s, func(v map[string]any) bool { return v["dtcs"] != nil })
+	if dtcs := values["dtcs"].([]string); !slices.Equal(dtcs, []string{"P0045", "P0103", "P0171"}) {
+		t.Errorf("Read()[dtcs] = %v, want [P0045 P0103 P0171]", dtcs)
+	}
+	if h := s.Health(); h.Counters["decodeErrors"] != 1 {
+		t.Errorf("decodeErrors = %d, want 1", h.Counters["decodeErrors"])
+	}
+
+	// Each first frame is answered with a flow control frame to that ECU
+	var flows int
+	for _, f := range conn.sent() {
+		if f.ID == 0x7E1 && len(f.Data) == MaxDataLength && f.Data[0] == 0x30 {
+			flows++
+		} else {
+			t.Errorf("unexpected frame %s", f)
+		}
+	}
+	if flows != 2 {
+		t.Errorf("sent %d flow control frames, want 2", flows)
+	}
+}
+
+func TestSensorPolls(t *testing.T) {
+	conn := newFakeConn()
+	s, err := NewSensor(Config{
+		Type:         BusReplay,
+		Path:         "unused",
+		PIDs:         []string{ReadingRPM, ReadingGear},
+		PollInterval: "20ms",
+		DTCInterval:  "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.bus = &fakeBus{conn}
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	want := [][]byte{
+		{0x02, 0x01, pidMonitorStatus},
+		{0x01, 0x03},
+		{0x02, 0x01, 0x0C},
+		{0x02, 0x01, 0xA4},
+		{0x02, 0x01, 0x0C},
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(conn.sent()) < len(want) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	sent := conn.sent()
+	if len(sent) < len(want) {
+		t.Fatalf("sent %d requests, want at least %d", len(sent), len(want))
+	}
+	for i, w := range want {
+		f := sent[i]
+		if f.ID != obdRequestID || len(f.Data) != MaxDataLength || !bytes.HasPrefix(f.Data, w) {
+			t.Errorf("request %d = %s, want 7DF#% X padded", i, f, w)
+		}
+	}
+}
+
+func TestSensorStale(t *testing.T) {
+	path := writeLog(t, "(0.000000) can0 7E8#04410C1AF8AAAAAA")
+	s := newTestSensor(t, Config{
+		Type:        BusReplay,
+		Path:        path,
+		PIDs:        []string{noPIDs},
+		DTCInterval: "0",
+		StaleAfter:  "200ms",
+	})
+
+	waitRead(t, s, func(v map[string]any) bool { return v[ReadingRPM] != nil })
+	if info := s.Info(); info != "online (1 readings)" {
+		t.Errorf("Info() = %q, want online (1 readings)", info)
+	}
+
+	// The replay has ended, nothing refreshes the reading
+	time.Sleep(300 * time.Millisecond)
+	if values, err := s.Read(); err != nil || len(values) != 0 {
+		t.Errorf("Read() after staleAfter = %v, %v, want no readings", values, err)
+	}
+	if info := s.Info(); info != "online (no frames)" {
+		t.Errorf("Info() = %q, want online (no frames)", info)
+	}
+	if h := s.Health(); h.State != hal.HealthDegraded || h.Metrics["readings"] != 0 {
+		t.Errorf("Health() = %+v, want degraded without readings", h)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Read(); err == nil {
+		t.Error("Read() after Close returned readings")
+	}
+	if h := s.Health(); h.State != hal.HealthOffline {
+		t.Errorf("Health() after Close = %+v, want offline", h)
+	}
+}
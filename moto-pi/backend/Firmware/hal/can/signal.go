@@ -0,0 +1,106 @@
+package can
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+)
+
+// SignalConfig decodes a value broadcast in a manufacturer frame, e.g. the
+// gear in the low nibble of byte 2 of frame 0x540:
+//
+//	{"name": "gear", "id": "0x540", "start": 2, "mask": "0x0F"}
+type SignalConfig struct {
+	Name         string  `json:"name"`         // reading name, a standard one like "gear" replaces the PID value
+	ID           string  `json:"id"`           // frame identifier, e.g. "0x540", 29 bit when above 0x7FF
+	Start        int     `json:"start"`        // first data byte of the value
+	Length       int     `json:"length"`       // bytes, 1 to 4, 1 when 0
+	LittleEndian bool    `json:"littleEndian"` // byte order, big endian by default
+	Signed       bool    `json:"signed"`       // two's complement value
+	Mask         string  `json:"mask"`         // optional bit mask applied before scaling, e.g. "0xF0"
+	Scale        float64 `json:"scale"`        // multiplier, 1 when 0
+	Offset       float64 `json:"offset"`       // added after scaling
+}
+
+// signal is a parsed SignalConfig
+type signal struct {
+	name     string
+	id       uint32
+	extended bool
+	start    int
+	length   int
+	little   bool
+	signed   bool
+	mask     uint32
+	shift    int
+	scale    float64
+	offset   float64
+}
+
+// parseSignals validates the manufacturer frame definitions
+func parseSignals(cfgs []SignalConfig) ([]signal, error) {
+	var signals []signal
+	for _, c := range cfgs {
+		if c.Name == "" {
+			return nil, fmt.Errorf("can: signal of frame %s has no name", c.ID)
+		}
+		id, err := strconv.ParseUint(c.ID, 0, 32)
+		if err != nil || id > 0x1FFFFFFF {
+			return nil, fmt.Errorf("can: signal %s: invalid id %q", c.Name, c.ID)
+		}
+		s := signal{
+			name:     c.Name,
+			id:       uint32(id),
+			extended: id > 0x7FF,
+			start:    c.Start,
+			length:   max(c.Length, 1),
+			little:   c.LittleEndian,
+			signed:   c.Signed,
+			scale:    c.Scale,
+			offset:   c.Offset,
+		}
+		if s.start < 0 || s.length > 4 || s.start+s.length > MaxDataLength {
+			return nil, fmt.Errorf("can: signal %s: bytes %d+%d outside the frame", c.Name, c.Start, s.length)
+		}
+		if s.scale == 0 {
+			s.scale = 1
+		}
+		s.mask = ^uint32(0) >> (32 - 8*s.length)
+		if c.Mask != "" {
+			m, err := strconv.ParseUint(c.Mask, 0, 32)
+			if err != nil || m == 0 {
+				return nil, fmt.Errorf("can: signal %s: invalid mask %q", c.Name, c.Mask)
+			}
+			s.mask = uint32(m)
+			s.shift = bits.TrailingZeros32(s.mask)
+		}
+		signals = append(signals, s)
+	}
+	return signals, nil
+}
+
+// decode extracts the value from f, false when f carries another frame or is too short
+func (s signal) decode(f Frame) (float64, bool) {
+	if f.ID != s.id || f.Extended != s.extended || len(f.Data) < s.start+s.length {
+		return 0, false
+	}
+	var raw uint32
+	for i := 0; i < s.length; i++ {
+		b := f.Data[s.start+i]
+		if s.little {
+			raw |= uint32(b) << (8 * i)
+		} else {
+			raw = raw<<8 | uint32(b)
+		}
+	}
+	raw = (raw & s.mask) >> s.shift
+
+	value := float64(raw)
+	if s.signed {
+		width := bits.Len32(s.mask >> s.shift)
+		if width > 0 && raw&(1<<(width-1)) != 0 {
+			value -= float64(uint64(1) << width)
+		}
+	}
+	return value*s.scale + s.offset, true
+}
@@ -0,0 +1,162 @@
+package can
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseSignalsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  SignalConfig
+	}{
+		{"no name", SignalConfig{ID: "0x540"}},
+		{"bad id", SignalConfig{Name: "gear", ID: "gear"}},
+		{"id above 29 bits", SignalConfig{Name: "gear", ID: "0x20000000"}},
+		{"start past the frame", SignalConfig{Name: "gear", ID: "0x540", Start: 8}},
+		{"negative start", SignalConfig{Name: "gear", ID: "0x540", Start: -1}},
+		{"value past the frame", SignalConfig{Name: "gear", ID: "0x540", Start: 6, Length: 3}},
+		{"longer than 4 bytes", SignalConfig{Name: "gear", ID: "0x540", Length: 5}},
+		{"bad mask", SignalConfig{Name: "gear", ID: "0x540", Mask: "low"}},
+		{"zero mask", SignalConfig{Name: "gear", ID: "0x540", Mask: "0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseSignals([]SignalConfig{tt.cfg}); err == nil {
+				t.Errorf("parseSignals(%+v) accepted an invalid signal", tt.cfg)
+			}
+		})
+	}
+}
+
+func TestSignalDecode(t *testing.T) {
+	data := []byte{0x12, 0x34, 0xA5, 0xFF, 0x80, 0x00, 0x01, 0x02}
+
+	tests := []struct {
+		name   string
+		cfg    SignalConfig
+		frame  Frame
+		want   float64
+		wantOK bool
+	}{
+		{
+			name:   "single byte",
+			cfg:    SignalConfig{Name: "x", ID: "0x540", Start: 2},
+			frame:  Frame{ID: 0x540, Data: data},
+			want:   0xA5,
+			wantOK: true,
+		},
+		{
+			name:   "big endian by default",
+			cfg:    SignalConfig{Name: "x", ID: "0x540", Length: 2},
+			frame:  Frame{ID: 0x540, Data: data},
+			want:   0x1234,
+			wantOK: true,
+		},
+		{
+			name:   "little endian",
+			cfg:    SignalConfig{Name: "x", ID: "0x540", Length: 2, LittleEndian: true},
+			frame:  Frame{ID: 0x540, Data: data},
+			want:   0x3412,
+			wantOK: true,
+		},
+		{
+			name:   "four bytes little endian",
+			cfg:    SignalConfig{Name: "x", ID: "0x540", Start: 4, Length: 4, LittleEndian: true},
+			frame:  Frame{ID: 0x540, Data: data},
+			want:   0x02010080,
+			wantOK: true,
+		},
+		{
+			name:   "low nibble",
+			cfg:    SignalConfig{Name: "gear", ID: "0x540", Start: 2, Mask: "0x0F"},
+			frame:  Frame{ID: 0x540, Data: data},
+			want:   5,
+			wantOK: true,
+		},
+		{
+			name:   "high nibble is shifted down",
+			cfg:    SignalConfig{Name: "gear", ID: "0x540", Start: 2, Mask: "0xF0"},
+			frame:  Frame{ID: 0x540, Data: data},
+			want:   0xA,
+			wantOK: true,
+		},
+		{
+			name:   "mask across bytes",
+			cfg:    SignalConfig{Name: "x", ID: "0x540", Length: 2, Mask: "0x0FF0"},
+			frame:  Frame{ID: 0x540, Data: data},
+			want:   0x23,
+			wantOK: true,
+		},
+		{
+			name:   "signed byte",
+			cfg:    SignalConfig{Name: "x", ID: "0x540", Start: 3, Signed: true},
+			frame:  Frame{ID: 0x540, Data: data},
+			want:   -1,
+			wantOK: true,
+		},
+		{
+			name:   "signed positive",
+			cfg:    SignalConfig{Name: "x", ID: "0x540", Start: 6, Signed: true},
+			frame:  Frame{ID: 0x540, Data: data},
+			want:   1,
+			wantOK: true,
+		},
+		{
+			name:   "signed little endian word",
+			cfg:    SignalConfig{Name: "x", ID: "0x540", Start: 4, Length: 2, LittleEndian: true, Signed: true},
+			frame:  Frame{ID: 0x540, Data: data},
+			want:   0x80,
+			wantOK: true,
+		},
+		{
+			name:   "signed masked nibble",
+			cfg:    SignalConfig{Name: "x", ID: "0x540", Start: 2, Mask: "0xF0", Signed: true},
+			frame:  Frame{ID: 0x540, Data: data},
+			want:   -6,
+			wantOK: true,
+		},
+		{
+			name:   "scale and offset",
+			cfg:    SignalConfig{Name: "coolantTempC", ID: "0x540", Start: 2, Scale: 0.5, Offset: -40},
+			frame:  Frame{ID: 0x540, Data: data},
+			want:   42.5,
+			wantOK: true,
+		},
+		{
+			name:   "extended id",
+			cfg:    SignalConfig{Name: "x", ID: "0x18FEF100", Start: 1},
+			frame:  Frame{ID: 0x18FEF100, Extended: true, Data: data},
+			want:   0x34,
+			wantOK: true,
+		},
+		{
+			name:  "other frame",
+			cfg:   SignalConfig{Name: "x", ID: "0x540"},
+			frame: Frame{ID: 0x541, Data: data},
+		},
+		{
+			name:  "standard frame with an extended id",
+			cfg:   SignalConfig{Name: "x", ID: "0x540"},
+			frame: Frame{ID: 0x540, Extended: true, Data: data},
+		},
+		{
+			name:  "short frame",
+			cfg:   SignalConfig{Name: "x", ID: "0x540", Start: 2, Length: 2},
+			frame: Frame{ID: 0x540, Data: data[:3]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signals, err := parseSignals([]SignalConfig{tt.cfg})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := signals[0].decode(tt.frame)
+			if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("decode(%s) = %v, %v, want %v, %v", tt.frame, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package can
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// frameSize is the size of struct can_frame
+const frameSize = 16
+
+// SocketCAN reads a kernel CAN interface through a raw socket
+type SocketCAN struct {
+	Interface string
+}
+
+// Open binds a raw socket to the interface
+func (s *SocketCAN) Open() (Conn, error) {
+	iface, err := net.InterfaceByName(s.Interface)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := unix.Socket(unix.AF_CAN, unix.SOCK_RAW|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, unix.CAN_RAW)
+	if err != nil {
+		return nil, fmt.Errorf("can: open socket: %w", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrCAN{Ifindex: iface.Index}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("can: bind %s: %w", s.Interface, err)
+	}
+	// Through the runtime poller, so Close unblocks a pending read
+	return &socketConn{file: os.NewFile(uintptr(fd), s.Interface)}, nil
+}
+
+func (s *SocketCAN) String() string {
+	return "socketcan " + s.Interface
+}
+
+// socketConn exchanges struct can_frame with the kernel
+type socketConn struct {
+	file *os.File
+}
+
+// ReadFrame returns the next data frame, skipping remote and error frames
+func (c *socketConn) ReadFrame() (Frame, error) {
+	var buf [frameSize]byte
+	for {
+		n, err := c.file.Read(buf[:])
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return Frame{}, net.ErrClosed
+			}
+			return Frame{}, err
+		}
+		if n != frameSize {
+			return Frame{}, fmt.Errorf("can: short frame of %d bytes", n)
+		}
+
+		id := binary.NativeEndian.Uint32(buf[0:4])
+		if id&(unix.CAN_RTR_FLAG|unix.CAN_ERR_FLAG) != 0 {
+			continue
+		}
+		f := Frame{ID: id & unix.CAN_SFF_MASK}
+		if id&unix.CAN_EFF_FLAG != 0 {
+			f.ID, f.Extended = id&unix.CAN_EFF_MASK, true
+		}
+		length := min(int(buf[4]), MaxDataLength)
+		f.Data = append([]byte(nil), buf[8:8+length]...)
+		return f, nil
+	}
+}
+
+// WriteFrame sends f
+func (c *socketConn) WriteFrame(f Frame) error {
+	if len(f.Data) > MaxDataLength {
+		return fmt.Errorf("can: %d data bytes do not fit a frame", len(f.Data))
+	}
+	var buf [frameSize]byte
+	id := f.ID & unix.CAN_SFF_MASK
+	if f.Extended {
+		id = f.ID&unix.CAN_EFF_MASK | unix.CAN_EFF_FLAG
+	}
+	binary.NativeEndian.PutUint32(buf[0:4], id)
+	buf[4] = byte(len(f.Data))
+	copy(buf[8:], f.Data)
+	_, err := c.file.Write(buf[:])
+	return err
+}
+
+func (c *socketConn) Close() error {
+	return c.file.Close()
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package can
+
+import "errors"
+
+// SocketCAN reads a kernel CAN interface, which only exists on Linux
+type SocketCAN struct {
+	Interface string
+}
+
+// Open fails, SocketCAN needs Linux
+func (s *SocketCAN) Open() (Conn, error) {
+	return nil, errors.New("can: socketcan is only supported on linux")
+}
+
+func (s *SocketCAN) String() string {
+	return "socketcan " + s.Interface
+}
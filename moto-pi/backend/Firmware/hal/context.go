@@ -0,0 +1,60 @@
+package hal
+
+import "context"
+
+// InitContext initialises dev within ctx. Devices without InitContext run
+// Init in the background; when ctx ends first its error is returned and
+// Init is left to finish on its own.
+func InitContext(ctx context.Context, dev Device) error {
+	if d, ok := dev.(ContextDevice); ok {
+		return d.InitContext(ctx)
+	}
+	return await(ctx, dev.Init)
+}
+
+// CloseContext closes dev within ctx, like InitContext
+func CloseContext(ctx context.Context, dev Device) error {
+	if d, ok := dev.(ContextDevice); ok {
+		return d.CloseContext(ctx)
+	}
+	return await(ctx, dev.Close)
+}
+
+// ReadContext reads s within ctx, like InitContext
+func ReadContext(ctx context.Context, s Sensor) (map[string]any, error) {
+	if cs, ok := s.(ContextSensor); ok {
+		return cs.ReadContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type reading struct {
+		values map[string]any
+		err    error
+	}
+	done := make(chan reading, 1)
+	go func() {
+		values, err := s.Read()
+		done <- reading{values, err}
+	}()
+	select {
+	case r := <-done:
+		return r.values, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// await runs fn and waits for it until ctx is done. fn is started even when
+// ctx is already done, so a device is still closed after a shutdown deadline.
+func await(ctx context.Context, fn func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,58 @@
+package hal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeDevice is a device without context support that reports each Close
+type fakeDevice struct {
+	closed chan struct{}
+}
+
+func (d *fakeDevice) Init() error    { return nil }
+func (d *fakeDevice) Info() string   { return "fake" }
+func (d *fakeDevice) Health() Health { return Health{State: HealthOK} }
+
+func (d *fakeDevice) Close() error {
+	d.closed <- struct{}{}
+	return nil
+}
+
+func TestCloseAfterDeadline(t *testing.T) {
+	tests := []struct {
+		name  string
+		close func(ctx context.Context, dev *fakeDevice) error
+	}{
+		{"device", func(ctx context.Context, dev *fakeDevice) error {
+			return CloseContext(ctx, dev)
+		}},
+		{"supervised device", func(ctx context.Context, dev *fakeDevice) error {
+			s, err := NewSupervisor("fake", dev, BackoffConfig{})
+			if err != nil {
+				return err
+			}
+			if err := s.Init(); err != nil {
+				return err
+			}
+			return s.CloseContext(ctx)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev := &fakeDevice{closed: make(chan struct{}, 1)}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := tt.close(ctx, dev); err != nil && !errors.Is(err, context.Canceled) {
+				t.Fatal(err)
+			}
+			select {
+			case <-dev.closed:
+			case <-time.After(time.Second):
+				t.Fatal("device not closed after the deadline")
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpioreg"
+	"periph.io/x/host/v3"
+)
+
+// Backend types selectable from Config
+const (
+	BackendPeriph    = "periph"
+	BackendSimulated = "sim"
+)
+
+// ErrNotSimulated is returned when driving a pin of real hardware
+var ErrNotSimulated = errors.New("gpio backend is not simulated")
+
+// Pin is the part of a GPIO pin used by relays and inputs, satisfied by gpio.PinIO
+type Pin interface {
+	In(pull gpio.Pull, edge gpio.Edge) error
+	Read() gpio.Level
+	WaitForEdge(timeout time.Duration) bool
+	Out(l gpio.Level) error
+	Halt() error
+}
+
+// Backend provides the GPIO pins of a board by BCM number
+type Backend interface {
+	Init() error
+	Pin(bcm int) (Pin, error)
+	String() string
+}
+
+// NewBackend builds the Backend named by kind
+func NewBackend(kind string) (Backend, error) {
+	switch kind {
+	case "", BackendPeriph:
+		return PeriphBackend{}, nil
+	case BackendSimulated:
+		return NewSimBackend(), nil
+	}
+	return nil, fmt.Errorf("gpio: unknown backend %q", kind)
+}
+
+// initHost initialises periph.io once per process
+var initHost = sync.OnceValue(func() error {
+	_, err := host.Init()
+	return err
+})
+
+// PeriphBackend drives the pins of the board through periph.io
+type PeriphBackend struct{}
+
+// Init loads the periph.io host drivers
+func (PeriphBackend) Init() error {
+	return initHost()
+}
+
+// Pin resolves a BCM pin, e.g. 21 for GPIO21
+func (PeriphBackend) Pin(bcm int) (Pin, error) {
+	name := fmt.Sprintf("GPIO%d", bcm)
+	pin := gpioreg.ByName(name)
+	if pin == nil {
+		return nil, fmt.Errorf("unknown pin %s", name)
+	}
+	return pin, nil
+}
+
+func (PeriphBackend) String() string {
+	return BackendPeriph
+}
+
+// SimBackend keeps pin levels in memory so relay sequences and input
+// handling can run on hosts without GPIO
+type SimBackend struct {
+	mu   sync.Mutex
+	pins map[int]*SimPin
+}
+
+// NewSimBackend creates a simulated board with every pin low
+func NewSimBackend() *SimBackend {
+	return &SimBackend{pins: make(map[int]*SimPin)}
+}
+
+// Init is a no-op
+func (b *SimBackend) Init() error {
+	return nil
+}
+
+// Pin returns the simulated pin, creating it on first use
+func (b *SimBackend) Pin(bcm int) (Pin, error) {
+	return b.pin(bcm), nil
+}
+
+// Drive sets the level of a pin as if an external circuit pulled it
+func (b *SimBackend) Drive(bcm int, level gpio.Level) {
+	b.pin(bcm).Drive(level)
+}
+
+func (b *SimBackend) String() string {
+	return BackendSimulated
+}
+
+func (b *SimBackend) pin(bcm int) *SimPin {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.pins[bcm]
+	if !ok {
+		p = &SimPin{edges: make(chan struct{}, 1)}
+		b.pins[bcm] = p
+	}
+	return p
+}
+
+// SimPin is one simulated pin. Inputs report an edge whenever Drive changes
+// the level in a direction their edge setting watches.
+type SimPin struct {
+	mu     sync.Mutex
+	level  gpio.Level
+	edge   gpio.Edge
+	output bool
+	edges  chan struct{}
+}
+
+// In configures the pin as input, a pull resistor sets the idle level
+func (p *SimPin) In(pull gpio.Pull, edge gpio.Edge) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.output, p.edge = false, edge
+	switch pull {
+	case gpio.PullUp:
+		p.level = gpio.High
+	case gpio.PullDown:
+		p.level = gpio.Low
+	}
+	return nil
+}
+
+// Read returns the current level
+func (p *SimPin) Read() gpio.Level {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.level
+}
+
+// WaitForEdge waits for Drive to change the level, or timeout when it is not -1
+func (p *SimPin) WaitForEdge(timeout time.Duration) bool {
+	if timeout < 0 {
+		<-p.edges
+		return true
+	}
+	select {
+	case <-p.edges:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Out configures the pin as output at level
+func (p *SimPin) Out(level gpio.Level) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.output, p.level = true, level
+	return nil
+}
+
+// Halt wakes a pending WaitForEdge
+func (p *SimPin) Halt() error {
+	p.notify()
+	return nil
+}
+
+// Drive sets the level from outside, reporting an edge to a watching input
+func (p *SimPin) Drive(level gpio.Level) {
+	p.mu.Lock()
+	changed := p.level != level
+	p.level = level
+	watched := !p.output && (p.edge == gpio.BothEdges ||
+		p.edge == gpio.RisingEdge && level == gpio.High ||
+		p.edge == gpio.FallingEdge && level == gpio.Low)
+	p.mu.Unlock()
+	if changed && watched {
+		p.notify()
+	}
+}
+
+// notify records a pending edge, edges not yet waited for coalesce
+func (p *SimPin) notify() {
+	select {
+	case p.edges <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,270 @@
+package gpio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
+	"periph.io/x/conn/v3/gpio"
+)
+
+// DefaultDebounce is how long an input must hold a new level before it counts
+const DefaultDebounce = 50 * time.Millisecond
+
+// Pull resistor settings
+const (
+	PullNone = ""
+	PullUp   = "up"
+	PullDown = "down"
+)
+
+// edgeWait bounds each wait for an edge, so a missed edge is still noticed
+// and Close does not hang on a quiet pin
+const edgeWait = time.Second
+
+// InputEvent reports a debounced change of an input
+type InputEvent struct {
+	Time   time.Time `json:"time"`
+	Pin    string    `json:"pin"`
+	Role   string    `json:"role,omitempty"`
+	Active bool      `json:"active"`
+	Level  string    `json:"level"`
+}
+
+// Input watches a GPIO input for edges and debounces its level
+type Input struct {
+	cfg      PinConfig
+	backend  Backend
+	role     string
+	pull     gpio.Pull
+	debounce time.Duration
+	onChange func(InputEvent)
+
+	mu      sync.Mutex
+	pin     Pin
+	active  bool
+	changed time.Time
+	changes uint64
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// Ensure Input implements hal.Sensor
+var _ hal.Sensor = (*Input)(nil)
+
+// NewInput constructs an input on a pin of backend
+func NewInput(cfg PinConfig, backend Backend) (*Input, error) {
+	in := &Input{cfg: cfg, backend: backend, debounce: DefaultDebounce}
+	switch cfg.Pull {
+	case PullNone:
+		in.pull = gpio.PullNoChange
+	case PullUp:
+		in.pull = gpio.PullUp
+	case PullDown:
+		in.pull = gpio.PullDown
+	default:
+		return nil, fmt.Errorf("input %s: unknown pull %q", cfg.Name, cfg.Pull)
+	}
+	if cfg.Debounce != "" {
+		d, err := time.ParseDuration(cfg.Debounce)
+		if err != nil {
+			return nil, fmt.Errorf("input %s: invalid debounce: %w", cfg.Name, err)
+		}
+		in.debounce = d
+	}
+	return in, nil
+}
+
+// Init resolves the pin and starts watching it for edges
+func (in *Input) Init() error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	// Reconfiguring the pin would steal the edges from the running watcher
+	if in.pin != nil {
+		return nil
+	}
+
+	if err := in.backend.Init(); err != nil {
+		return fmt.Errorf("input %s: init GPIO: %w", in.cfg.Name, err)
+	}
+	pin, err := in.backend.Pin(in.cfg.BCM)
+	if err != nil {
+		return fmt.Errorf("input %s: %w", in.cfg.Name, err)
+	}
+	// Fall back to polling where the driver has no edge detection
+	edges := true
+	if err := pin.In(in.pull, gpio.BothEdges); err != nil {
+		if err := pin.In(in.pull, gpio.NoEdge); err != nil {
+			return fmt.Errorf("input %s: %w", in.cfg.Name, err)
+		}
+		edges = false
+	}
+
+	in.pin = pin
+	in.active = in.isActive(pin.Read())
+	in.changed = time.Now()
+	in.stop = make(chan struct{})
+	in.done = make(chan struct{})
+	go in.watch(pin, edges, in.stop, in.done)
+	return nil
+}
+
+// Close stops watching the pin
+func (in *Input) Close() error {
+	in.mu.Lock()
+	pin, stop, done := in.pin, in.stop, in.done
+	in.pin = nil
+	in.mu.Unlock()
+	if pin == nil {
+		return nil
+	}
+	close(stop)
+	// Wake a pending WaitForEdge, drivers without Halt time out within edgeWait
+	pin.Halt()
+	<-done
+	return nil
+}
+
+// Info returns the input state
+func (in *Input) Info() string {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	switch {
+	case in.pin == nil:
+		return "offline"
+	case in.active:
+		return "online (active)"
+	}
+	return "online (inactive)"
+}
+
+// Read returns the debounced state of the input
+func (in *Input) Read() (map[string]any, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.pin == nil {
+		return nil, fmt.Errorf("input %s: not initialised", in.cfg.Name)
+	}
+	return map[string]any{
+		"name":    in.cfg.Name,
+		"role":    in.role,
+		"active":  in.active,
+		"level":   levelString(in.active, in.cfg.ActiveLow),
+		"changed": in.changed,
+	}, nil
+}
+
+// Health reports the debounced state and how often it changed
+func (in *Input) Health() hal.Health {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.pin == nil {
+		return hal.Health{State: hal.HealthOffline}
+	}
+	return hal.Health{
+		State:    hal.HealthOK,
+		LastSeen: hal.LastSeen(in.changed),
+		Counters: map[string]uint64{"changes": in.changes},
+		Metrics: map[string]any{
+			"bcm":    in.cfg.BCM,
+			"role":   in.role,
+			"active": in.active,
+			"level":  levelString(in.active, in.cfg.ActiveLow),
+		},
+	}
+}
+
+// Active reports the debounced state of the input
+func (in *Input) Active() (bool, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.pin == nil {
+		return false, fmt.Errorf("input %s: not initialised", in.cfg.Name)
+	}
+	return in.active, nil
+}
+
+// watch waits for edges and publishes each settled change
+func (in *Input) watch(pin Pin, edges bool, stop, done chan struct{}) {
+	defer close(done)
+	for {
+		if edges {
+			pin.WaitForEdge(edgeWait)
+		} else {
+			select {
+			case <-stop:
+				return
+			case <-time.After(in.debounce):
+			}
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		level, ok := in.settle(pin, stop)
+		if !ok {
+			return
+		}
+		in.update(in.isActive(level))
+	}
+}
+
+// settle reads the pin until it has held the same level for the debounce time
+func (in *Input) settle(pin Pin, stop chan struct{}) (gpio.Level, bool) {
+	level := pin.Read()
+	for {
+		select {
+		case <-stop:
+			return level, false
+		case <-time.After(in.debounce):
+		}
+		next := pin.Read()
+		if next == level {
+			return level, true
+		}
+		level = next
+	}
+}
+
+// update records the settled state and reports a change
+func (in *Input) update(active bool) {
+	in.mu.Lock()
+	if active == in.active {
+		in.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	in.active, in.changed = active, now
+	in.changes++
+	ev := InputEvent{
+		Time:   now.UTC(),
+		Pin:    in.cfg.Name,
+		Role:   in.role,
+		Active: active,
+		Level:  levelString(active, in.cfg.ActiveLow),
+	}
+	onChange := in.onChange
+	in.mu.Unlock()
+
+	if onChange != nil {
+		onChange(ev)
+	}
+}
+
+// isActive converts an electrical level to the logical state
+func (in *Input) isActive(level gpio.Level) bool {
+	return bool(level) != in.cfg.ActiveLow
+}
+
+// Ignition adapts an ignition sense input to the alarm and motorcycle services
+type Ignition struct {
+	Input *Input
+}
+
+// IgnitionOn reports whether the ignition is switched on
+func (i Ignition) IgnitionOn() (bool, error) {
+	return i.Input.Active()
+}
@@ -0,0 +1,409 @@
+package gpio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
+	"periph.io/x/conn/v3/gpio"
+)
+
+// Pin directions
+const (
+	DirectionOut = "out"
+	DirectionIn  = "in"
+)
+
+var (
+	// ErrUnknownPin is returned for names missing from the pin map
+	ErrUnknownPin = errors.New("unknown pin")
+	// ErrNotOutput is returned when an input pin is used as a relay
+	ErrNotOutput = errors.New("pin is not an output")
+	// ErrNotInput is returned when an output pin is used as an input
+	ErrNotInput = errors.New("pin is not an input")
+)
+
+// DefaultShutdownHold is how long the shutdown button must be held
+const DefaultShutdownHold = 2 * time.Second
+
+// Input roles wired to the bike's switches
+const (
+	RoleIgnition   = "ignition"
+	RoleKillSwitch = "killSwitch"
+	RoleSideStand  = "sideStand"
+	RoleShutdown   = "shutdown"
+)
+
+// PinConfig declares one GPIO pin of the pin map
+type PinConfig struct {
+	Name      string `json:"name"`
+	BCM       int    `json:"bcm"`       // Broadcom GPIO number, e.g. 21 for GPIO21
+	Direction string `json:"direction"` // "out" (default) or "in"
+	ActiveLow bool   `json:"activeLow"` // pin is low while active
+	DefaultOn bool   `json:"defaultOn"` // output state after Init and Close
+	Pull      string `json:"pull"`      // input pull resistor: "up", "down" or "" to leave as is
+	Debounce  string `json:"debounce"`  // input settle time, e.g. "50ms", DefaultDebounce when empty
+}
+
+// Roles names the input pins wired to the bike's switches, each optional
+type Roles struct {
+	Ignition     string `json:"ignition"`     // active while the ignition is on
+	KillSwitch   string `json:"killSwitch"`   // active while the kill switch cuts the engine
+	SideStand    string `json:"sideStand"`    // active while the side stand is down
+	Shutdown     string `json:"shutdown"`     // active while the shutdown button is pressed
+	ShutdownHold string `json:"shutdownHold"` // press length that shuts down, DefaultShutdownHold when empty
+}
+
+// Config is the GPIO pin map
+type Config struct {
+	Backend string      `json:"backend"` // "periph" (default) or "sim" for hosts without GPIO
+	Pins    []PinConfig `json:"pins"`
+	Roles   Roles       `json:"roles"`
+}
+
+// MomentaryConfig describes a momentary switch: Hold is switched off while
+// Pulse is switched on for Duration, then Pulse switches off and Hold returns
+// to the state it had before
+type MomentaryConfig struct {
+	Pulse    string `json:"pulse"`    // output switched on for Duration
+	Hold     string `json:"hold"`     // optional output switched off meanwhile
+	Duration string `json:"duration"` // e.g. "3s", DefaultPulse when empty
+}
+
+// PinState is a snapshot of one pin
+type PinState struct {
+	Name      string `json:"name"`
+	BCM       int    `json:"bcm"`
+	Direction string `json:"direction"`
+	ActiveLow bool   `json:"activeLow"`
+	Role      string `json:"role,omitempty"`
+	Active    bool   `json:"active"`
+	Level     string `json:"level"` // "high" or "low"
+}
+
+// levelString describes an electrical level
+func levelString(active, activeLow bool) string {
+	if active != activeLow {
+		return "high"
+	}
+	return "low"
+}
+
+// Controller owns the pins declared in the pin map
+type Controller struct {
+	backend Backend
+	pins    []PinConfig
+	relays  map[string]*Relay
+	inputs  map[string]*Input
+	hold    time.Duration
+
+	mu    sync.Mutex
+	ready bool
+	subs  map[chan InputEvent]struct{}
+}
+
+// Ensure Controller implements hal.Device
+var _ hal.Device = (*Controller)(nil)
+
+// NewController validates the pin map
+func NewController(cfg Config) (*Controller, error) {
+	backend, err := NewBackend(cfg.Backend)
+	if err != nil {
+		return nil, err
+	}
+	c := &Controller{
+		backend: backend,
+		relays:  make(map[string]*Relay),
+		inputs:  make(map[string]*Input),
+		subs:    make(map[chan InputEvent]struct{}),
+		hold:    DefaultShutdownHold,
+	}
+	if cfg.Roles.ShutdownHold != "" {
+		d, err := time.ParseDuration(cfg.Roles.ShutdownHold)
+		if err != nil {
+			return nil, fmt.Errorf("gpio: invalid shutdown hold: %w", err)
+		}
+		c.hold = d
+	}
+	seen := make(map[string]bool)
+	for _, p := range cfg.Pins {
+		if p.Name == "" {
+			return nil, fmt.Errorf("gpio: pin %d has no name", p.BCM)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("gpio: duplicate pin name %q", p.Name)
+		}
+		seen[p.Name] = true
+		if p.BCM < 0 {
+			return nil, fmt.Errorf("gpio: pin %s: invalid BCM number %d", p.Name, p.BCM)
+		}
+
+		switch p.Direction {
+		case "", DirectionOut:
+			p.Direction = DirectionOut
+			c.relays[p.Name] = NewRelay(p, backend)
+		case DirectionIn:
+			in, err := NewInput(p, backend)
+			if err != nil {
+				return nil, fmt.Errorf("gpio: %w", err)
+			}
+			in.onChange = c.publish
+			c.inputs[p.Name] = in
+		default:
+			return nil, fmt.Errorf("gpio: pin %s: unknown direction %q", p.Name, p.Direction)
+		}
+		c.pins = append(c.pins, p)
+	}
+
+	roles := map[string]string{
+		RoleIgnition:   cfg.Roles.Ignition,
+		RoleKillSwitch: cfg.Roles.KillSwitch,
+		RoleSideStand:  cfg.Roles.SideStand,
+		RoleShutdown:   cfg.Roles.Shutdown,
+	}
+	for role, name := range roles {
+		if name == "" {
+			continue
+		}
+		in, err := c.Input(name)
+		if err != nil {
+			return nil, fmt.Errorf("gpio: role %s: %w", role, err)
+		}
+		in.role = role
+	}
+	return c, nil
+}
+
+// Init resolves every pin and puts outputs in their default state
+func (c *Controller) Init() error {
+	if err := c.backend.Init(); err != nil {
+		return fmt.Errorf("gpio: init %s backend: %w", c.backend, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range c.pins {
+		var dev hal.Device = c.relays[p.Name]
+		if p.Direction == DirectionIn {
+			dev = c.inputs[p.Name]
+		}
+		if err := dev.Init(); err != nil {
+			return err
+		}
+	}
+	c.ready = true
+	return nil
+}
+
+// Close stops watching inputs and returns every output to its default state
+func (c *Controller) Close() error {
+	c.mu.Lock()
+	c.ready = false
+	c.mu.Unlock()
+
+	// Not under c.mu, a watcher may be publishing a change while it stops
+	var errs []error
+	for _, in := range c.inputs {
+		errs = append(errs, in.Close())
+	}
+	for _, r := range c.relays {
+		errs = append(errs, r.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Info returns the controller status
+func (c *Controller) Info() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.ready {
+		return "offline"
+	}
+	return fmt.Sprintf("online (%d pins, %s)", len(c.pins), c.backend)
+}
+
+// Health reports the controller and the state of every pin
+func (c *Controller) Health() hal.Health {
+	c.mu.Lock()
+	ready := c.ready
+	c.mu.Unlock()
+
+	h := hal.Health{State: hal.HealthOffline}
+	if ready {
+		h.State = hal.HealthOK
+	}
+	pins := make(map[string]any, len(c.pins))
+	var changes uint64
+	var last time.Time
+	for _, p := range c.pins {
+		var ph hal.Health
+		if r, ok := c.relays[p.Name]; ok {
+			ph = r.Health()
+		} else {
+			ph = c.inputs[p.Name].Health()
+			changes += ph.Counters["changes"]
+		}
+		if ph.LastSeen != nil && ph.LastSeen.After(last) {
+			last = *ph.LastSeen
+		}
+		pins[p.Name] = ph.Metrics
+	}
+	h.LastSeen = hal.LastSeen(last)
+	h.Counters = map[string]uint64{"inputChanges": changes}
+	h.Metrics = map[string]any{"backend": c.backend.String(), "pins": pins}
+	return h
+}
+
+// Relay returns the relay driving the named output
+func (c *Controller) Relay(name string) (*Relay, error) {
+	if r, ok := c.relays[name]; ok {
+		return r, nil
+	}
+	for _, p := range c.pins {
+		if p.Name == name {
+			return nil, fmt.Errorf("%w: %s", ErrNotOutput, name)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownPin, name)
+}
+
+// Input returns the named input
+func (c *Controller) Input(name string) (*Input, error) {
+	if in, ok := c.inputs[name]; ok {
+		return in, nil
+	}
+	if _, ok := c.relays[name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotInput, name)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownPin, name)
+}
+
+// Drive sets the level of a simulated pin: an input as if its switch was
+// operated, an output through its relay so the relay state follows
+func (c *Controller) Drive(name string, high bool) error {
+	sim, ok := c.backend.(*SimBackend)
+	if !ok {
+		return ErrNotSimulated
+	}
+	if r, ok := c.relays[name]; ok {
+		if high != r.cfg.ActiveLow {
+			return r.Command("on")
+		}
+		return r.Command("off")
+	}
+	in, err := c.Input(name)
+	if err != nil {
+		return err
+	}
+	sim.Drive(in.cfg.BCM, gpio.Level(high))
+	return nil
+}
+
+// ShutdownHold returns how long the shutdown button must be held
+func (c *Controller) ShutdownHold() time.Duration {
+	return c.hold
+}
+
+// Subscribe returns a channel receiving input changes until ctx is done
+func (c *Controller) Subscribe(ctx context.Context) <-chan InputEvent {
+	ch := make(chan InputEvent, 16)
+	c.mu.Lock()
+	c.subs[ch] = struct{}{}
+	c.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		c.mu.Lock()
+		delete(c.subs, ch)
+		close(ch)
+		c.mu.Unlock()
+	}()
+	return ch
+}
+
+// publish hands an input change to subscribers without blocking
+func (c *Controller) publish(ev InputEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ch := range c.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// States returns a snapshot of every pin in pin map order
+func (c *Controller) States() []PinState {
+	states := make([]PinState, 0, len(c.pins))
+	for _, p := range c.pins {
+		states = append(states, c.state(p))
+	}
+	return states
+}
+
+// State returns a snapshot of the named pin
+func (c *Controller) State(name string) (PinState, error) {
+	for _, p := range c.pins {
+		if p.Name == name {
+			return c.state(p), nil
+		}
+	}
+	return PinState{}, fmt.Errorf("%w: %s", ErrUnknownPin, name)
+}
+
+// state reads one pin
+func (c *Controller) state(p PinConfig) PinState {
+	st := PinState{Name: p.Name, BCM: p.BCM, Direction: p.Direction, ActiveLow: p.ActiveLow}
+	if r, ok := c.relays[p.Name]; ok {
+		st.Active = r.On()
+	} else {
+		in := c.inputs[p.Name]
+		st.Role = in.role
+		st.Active, _ = in.Active()
+	}
+	st.Level = levelString(st.Active, p.ActiveLow)
+	return st
+}
+
+// Momentary runs a momentary switch sequence and returns once it has finished
+func (c *Controller) Momentary(m MomentaryConfig) (err error) {
+	d := DefaultPulse
+	if m.Duration != "" {
+		var err error
+		if d, err = time.ParseDuration(m.Duration); err != nil {
+			return fmt.Errorf("gpio: invalid momentary duration: %w", err)
+		}
+	}
+	pulse, err := c.Relay(m.Pulse)
+	if err != nil {
+		return err
+	}
+	var hold *Relay
+	if m.Hold != "" {
+		if hold, err = c.Relay(m.Hold); err != nil {
+			return err
+		}
+	}
+
+	if hold != nil {
+		wasOn := hold.On()
+		if err := hold.Command("off"); err != nil {
+			return err
+		}
+		// Restore the held output however the pulse ends
+		defer func() {
+			if wasOn {
+				err = errors.Join(err, hold.Command("on"))
+			}
+		}()
+	}
+	if err := pulse.Command("on"); err != nil {
+		return err
+	}
+	time.Sleep(d)
+	return pulse.Command("off")
+}
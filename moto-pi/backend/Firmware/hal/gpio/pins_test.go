@@ -0,0 +1,262 @@
+package gpio
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+)
+
+// newSimController builds and initialises a controller on the sim backend
+func newSimController(t *testing.T, cfg Config) *Controller {
+	t.Helper()
+	cfg.Backend = BackendSimulated
+	c, err := NewController(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// level reads the electrical level of a simulated pin
+func level(c *Controller, bcm int) gpio.Level {
+	return c.backend.(*SimBackend).pin(bcm).Read()
+}
+
+func TestMomentary(t *testing.T) {
+	tests := []struct {
+		name       string
+		holdOn     bool // hold relay state before the sequence
+		defaultOn  bool
+		wantHoldOn bool
+	}{
+		{"hold on by default is restored", true, true, true},
+		{"hold switched on is restored", true, false, true},
+		{"hold off stays off", false, false, false},
+		{"hold switched off stays off", false, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newSimController(t, Config{Pins: []PinConfig{
+				{Name: "starter", BCM: 21},
+				{Name: "ignition", BCM: 26, ActiveLow: true, DefaultOn: tt.defaultOn},
+			}})
+			// The ignition relay is active low
+			if err := c.Drive("ignition", !tt.holdOn); err != nil {
+				t.Fatal(err)
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				done <- c.Momentary(MomentaryConfig{Pulse: "starter", Hold: "ignition", Duration: "50ms"})
+			}()
+
+			time.Sleep(20 * time.Millisecond)
+			if st, _ := c.State("starter"); !st.Active || level(c, 21) != gpio.High {
+				t.Errorf("during pulse starter = %+v, want active and high", st)
+			}
+			if st, _ := c.State("ignition"); st.Active || level(c, 26) != gpio.High {
+				t.Errorf("during pulse ignition = %+v, want inactive and high (active low)", st)
+			}
+
+			if err := <-done; err != nil {
+				t.Fatalf("Momentary() error = %v", err)
+			}
+			if st, _ := c.State("starter"); st.Active || level(c, 21) != gpio.Low {
+				t.Errorf("after pulse starter = %+v, want inactive and low", st)
+			}
+			if st, _ := c.State("ignition"); st.Active != tt.wantHoldOn {
+				t.Errorf("after pulse ignition active = %v, want %v", st.Active, tt.wantHoldOn)
+			}
+		})
+	}
+}
+
+func TestMomentaryErrors(t *testing.T) {
+	c := newSimController(t, Config{Pins: []PinConfig{
+		{Name: "starter", BCM: 21},
+		{Name: "ignitionSense", BCM: 5, Direction: DirectionIn},
+	}})
+
+	tests := []struct {
+		name string
+		cfg  MomentaryConfig
+		want error
+	}{
+		{"unknown pulse pin", MomentaryConfig{Pulse: "horn"}, ErrUnknownPin},
+		{"input as pulse", MomentaryConfig{Pulse: "ignitionSense"}, ErrNotOutput},
+		{"input as hold", MomentaryConfig{Pulse: "starter", Hold: "ignitionSense"}, ErrNotOutput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.Momentary(tt.cfg); !errors.Is(err, tt.want) {
+				t.Errorf("Momentary() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if err := c.Momentary(MomentaryConfig{Pulse: "starter", Duration: "soon"}); err == nil {
+		t.Error("Momentary() accepted an invalid duration")
+	}
+}
+
+func TestDriveOutput(t *testing.T) {
+	c := newSimController(t, Config{Pins: []PinConfig{
+		{Name: "siren", BCM: 20},
+		{Name: "lock", BCM: 16, ActiveLow: true},
+	}})
+
+	tests := []struct {
+		pin        string
+		bcm        int
+		high       bool
+		wantActive bool
+	}{
+		{"siren", 20, true, true},
+		{"siren", 20, false, false},
+		{"lock", 16, false, true},
+		{"lock", 16, true, false},
+	}
+	for _, tt := range tests {
+		if err := c.Drive(tt.pin, tt.high); err != nil {
+			t.Fatalf("Drive(%s, %v) error = %v", tt.pin, tt.high, err)
+		}
+		r, _ := c.Relay(tt.pin)
+		if r.On() != tt.wantActive || level(c, tt.bcm) != gpio.Level(tt.high) {
+			t.Errorf("Drive(%s, %v): relay on = %v, level = %v", tt.pin, tt.high, r.On(), level(c, tt.bcm))
+		}
+	}
+}
+
+func TestRelayPulseDuration(t *testing.T) {
+	c := newSimController(t, Config{Pins: []PinConfig{{Name: "siren", BCM: 20}}})
+	r, err := c.Relay("siren")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []any
+		wantErr bool
+	}{
+		{"default", nil, false},
+		{"duration", []any{50 * time.Millisecond}, false},
+		{"string", []any{"50ms"}, false},
+		{"zero duration", []any{time.Duration(0)}, true},
+		{"negative duration", []any{-time.Second}, true},
+		{"zero string", []any{"0s"}, true},
+		{"negative string", []any{"-1s"}, true},
+		{"invalid string", []any{"soon"}, true},
+		{"other type", []any{50}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.Command("off"); err != nil {
+				t.Fatal(err)
+			}
+			err := r.Command("pulse", tt.args...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Command(pulse, %v) error = %v, want error %v", tt.args, err, tt.wantErr)
+			}
+			if r.On() == tt.wantErr {
+				t.Errorf("relay on = %v after pulse error %v", r.On(), err)
+			}
+		})
+	}
+}
+
+func TestDriveNotSimulated(t *testing.T) {
+	c, err := NewController(Config{Pins: []PinConfig{{Name: "siren", BCM: 20}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Drive("siren", true); !errors.Is(err, ErrNotSimulated) {
+		t.Errorf("Drive() error = %v, want %v", err, ErrNotSimulated)
+	}
+}
+
+func TestInputDebounce(t *testing.T) {
+	c := newSimController(t, Config{
+		Pins: []PinConfig{
+			{Name: "stand", BCM: 6, Direction: DirectionIn, Pull: PullUp, ActiveLow: true, Debounce: "30ms"},
+		},
+		Roles: Roles{SideStand: "stand"},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := c.Subscribe(ctx)
+
+	// Pulled up and active low, the stand starts up
+	if st, _ := c.State("stand"); st.Active || st.Level != "high" {
+		t.Fatalf("initial state = %+v, want inactive and high", st)
+	}
+
+	// A glitch shorter than the debounce time is ignored
+	c.Drive("stand", false)
+	time.Sleep(5 * time.Millisecond)
+	c.Drive("stand", true)
+	select {
+	case ev := <-events:
+		t.Fatalf("glitch reported as %+v", ev)
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	// A level held past the debounce time is reported once
+	c.Drive("stand", false)
+	select {
+	case ev := <-events:
+		if ev.Pin != "stand" || ev.Role != RoleSideStand || !ev.Active || ev.Level != "low" {
+			t.Errorf("event = %+v, want stand down", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event after the stand went down")
+	}
+	if active, _ := c.inputs["stand"].Active(); !active {
+		t.Error("input not active after the event")
+	}
+
+	c.Drive("stand", true)
+	select {
+	case ev := <-events:
+		if ev.Active || ev.Level != "high" {
+			t.Errorf("event = %+v, want stand up", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event after the stand went up")
+	}
+}
+
+func TestInputInitTwice(t *testing.T) {
+	c := newSimController(t, Config{Pins: []PinConfig{
+		{Name: "stand", BCM: 6, Direction: DirectionIn, Pull: PullUp, ActiveLow: true, Debounce: "10ms"},
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := c.Subscribe(ctx)
+
+	c.Drive("stand", false)
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Fatal("no event after the stand went down")
+	}
+
+	// A second Init must not reconfigure the pin under the running watcher
+	in := c.inputs["stand"]
+	if err := in.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if level(c, 6) != gpio.Low {
+		t.Error("second Init reset the pull-up level")
+	}
+	if active, _ := in.Active(); !active {
+		t.Error("input not active after a second Init")
+	}
+}
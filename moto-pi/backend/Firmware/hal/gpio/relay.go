@@ -0,0 +1,179 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
+	"periph.io/x/conn/v3/gpio"
+)
+
+// DefaultPulse is the pulse length used when "pulse" is given no duration
+const DefaultPulse = 3 * time.Second
+
+// Relay drives a relay (or siren) on a GPIO output
+type Relay struct {
+	cfg     PinConfig
+	backend Backend
+
+	mu       sync.Mutex
+	pin      Pin
+	on       bool
+	timer    *time.Timer
+	switches uint64    // level changes since Init
+	switched time.Time // last level change
+}
+
+// Ensure Relay implements hal.Actuator
+var _ hal.Actuator = (*Relay)(nil)
+
+// NewRelay constructs a relay on a pin of backend
+func NewRelay(cfg PinConfig, backend Backend) *Relay {
+	return &Relay{cfg: cfg, backend: backend}
+}
+
+// Init resolves the pin and puts the relay in its default state
+func (r *Relay) Init() error {
+	if err := r.backend.Init(); err != nil {
+		return fmt.Errorf("relay %s: init GPIO: %w", r.cfg.Name, err)
+	}
+	pin, err := r.backend.Pin(r.cfg.BCM)
+	if err != nil {
+		return fmt.Errorf("relay %s: %w", r.cfg.Name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pin = pin
+	return r.setLocked(r.cfg.DefaultOn)
+}
+
+// Close returns the relay to its default state
+func (r *Relay) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pin == nil {
+		return nil
+	}
+	return r.setLocked(r.cfg.DefaultOn)
+}
+
+// On reports whether the relay is switched on
+func (r *Relay) On() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.on
+}
+
+// Info returns the relay state
+func (r *Relay) Info() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case r.pin == nil:
+		return "offline"
+	case r.on:
+		return "online (on)"
+	}
+	return "online (off)"
+}
+
+// Health reports the relay state
+func (r *Relay) Health() hal.Health {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pin == nil {
+		return hal.Health{State: hal.HealthOffline}
+	}
+	return hal.Health{
+		State:    hal.HealthOK,
+		LastSeen: hal.LastSeen(r.switched),
+		Counters: map[string]uint64{"switches": r.switches},
+		Metrics: map[string]any{
+			"bcm":   r.cfg.BCM,
+			"on":    r.on,
+			"level": levelString(r.on, r.cfg.ActiveLow),
+		},
+	}
+}
+
+// Command accepts "on", "off" and "pulse" with an optional time.Duration
+// or duration string argument
+func (r *Relay) Command(cmd string, args ...any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pin == nil {
+		return fmt.Errorf("relay %s: not initialised", r.cfg.Name)
+	}
+
+	switch cmd {
+	case "on":
+		return r.setLocked(true)
+	case "off":
+		return r.setLocked(false)
+	case "pulse":
+		d, err := pulseDuration(args)
+		if err != nil {
+			return err
+		}
+		if err := r.setLocked(true); err != nil {
+			return err
+		}
+		var t *time.Timer
+		t = time.AfterFunc(d, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			// Ignore a pulse superseded by a later command
+			if r.timer == t {
+				r.timer = nil
+				r.setLocked(false)
+			}
+		})
+		r.timer = t
+		return nil
+	}
+	return fmt.Errorf("relay %s: unknown command %q", r.cfg.Name, cmd)
+}
+
+// setLocked drives the pin, cancelling any pending pulse
+func (r *Relay) setLocked(on bool) error {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	level := gpio.Level(on != r.cfg.ActiveLow)
+	if err := r.pin.Out(level); err != nil {
+		return fmt.Errorf("relay %s: %w", r.cfg.Name, err)
+	}
+	if on != r.on || r.switched.IsZero() {
+		r.switches++
+		r.switched = time.Now()
+	}
+	r.on = on
+	return nil
+}
+
+// pulseDuration reads the optional duration argument of "pulse"
+func pulseDuration(args []any) (time.Duration, error) {
+	if len(args) == 0 {
+		return DefaultPulse, nil
+	}
+	var d time.Duration
+	switch v := args[0].(type) {
+	case time.Duration:
+		d = v
+	case string:
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			return 0, fmt.Errorf("relay: invalid pulse duration %q", v)
+		}
+	default:
+		return 0, errors.New("relay: pulse duration must be a time.Duration or string")
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("relay: pulse duration %v must be positive", d)
+	}
+	return d, nil
+}
@@ -2,130 +2,216 @@ package gps
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
 	"github.com/adrianmo/go-nmea"
-	"go.bug.st/serial"
 )
 
 // GPSData holds the parsed values from the GPS
 type GPSData struct {
-	Time       string
-	Latitude   float64
-	Longitude  float64
-	Altitude   float64
-	Satellites int
-	SpeedKph   float64
-	TrackAngle float64
-	ValidFix   bool
+	Time             string
+	Timestamp        time.Time // UTC date and time of the fix, zero until a date is known
+	Latitude         float64
+	Longitude        float64
+	Altitude         float64
+	Satellites       int // satellites used in the fix
+	SpeedKph         float64
+	GroundSpeedKph   float64 // from VTG
+	TrackAngle       float64
+	ValidFix         bool
+	FixType          FixType
+	HDOP             float64
+	PDOP             float64
+	VDOP             float64
+	SatellitesInView []SatelliteInfo
+	Estimated        bool    // position was predicted by the filter, not measured
+	Confidence       float64 // 1 for measured fixes, decaying towards 0 while estimated
+	PositionSigma    float64 // filter position uncertainty in metres, 0 when unfiltered
+}
+
+// GoodFix reports whether the fix is measured, valid and its HDOP is within
+// maxHDOP. A maxHDOP of 0 only checks validity. Estimated fixes are
+// rejected, a dead-reckoned position must not trigger alarms or fences.
+func (d GPSData) GoodFix(maxHDOP float64) bool {
+	if !d.ValidFix || d.FixType == FixNone || d.Estimated {
+		return false
+	}
+	return maxHDOP <= 0 || (d.HDOP > 0 && d.HDOP <= maxHDOP)
 }
 
 // GPS implements a background-reading GPS receiver
 type GPS struct {
-	port       serial.Port
-	portName   string
-	baudRate   int
-	data       GPSData
-	mu         sync.RWMutex
-	running    bool
-	cancelFunc func()
-	wg         sync.WaitGroup
+	source    Source
+	data      GPSData // raw state assembled from sentences
+	fix       GPSData // last filtered epoch, used when filter is set
+	filter    *kalman
+	parser    parser
+	fanout    broadcaster
+	mu        sync.RWMutex // guards the fields below and the parsed state above
+	running   bool         // sentences are arriving
+	ended     bool         // the source ran out of sentences, e.g. a replay without Loop
+	err       error        // read error that stopped the reader
+	lastSeen  time.Time
+	sentences uint64
+	badLines  uint64 // lines that failed to parse
+
+	// life serialises Init and Close
+	life    sync.Mutex
+	stream  io.ReadCloser
+	cancel  context.CancelFunc
+	stopped chan struct{} // closed when the reader exits
 }
 
-// NewGPS constructs GPS instance
+// Ensure GPS implements hal.ContextDevice and can be supervised
+var (
+	_ hal.ContextDevice = (*GPS)(nil)
+	_ hal.Monitored     = (*GPS)(nil)
+)
+
+// NewGPS constructs GPS instance reading from a serial port
 func NewGPS(portName string, baudRate int) *GPS {
+	return NewGPSWithSource(&SerialSource{PortName: portName, BaudRate: baudRate})
+}
+
+// NewGPSWithSource constructs GPS instance reading from any sentence source
+func NewGPSWithSource(source Source) *GPS {
 	return &GPS{
-		portName: portName,
-		baudRate: baudRate,
+		source: source,
 	}
 }
 
-// Init opens the serial port and starts background reading
+// SetFilter enables position smoothing. It must be called before Init.
+func (g *GPS) SetFilter(cfg FilterConfig) error {
+	if !cfg.Enabled {
+		g.filter = nil
+		return nil
+	}
+	k, err := newKalman(cfg)
+	if err != nil {
+		return err
+	}
+	g.filter = k
+	return nil
+}
+
+// Init opens the sentence source and starts background reading
 func (g *GPS) Init() error {
-	if g.running {
+	return g.InitContext(context.Background())
+}
+
+// InitContext opens the sentence source unless ctx is already done and
+// starts background reading
+func (g *GPS) InitContext(ctx context.Context) error {
+	g.life.Lock()
+	defer g.life.Unlock()
+	if g.cancel != nil {
 		return nil
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	mode := &serial.Mode{BaudRate: g.baudRate}
-	port, err := serial.Open(g.portName, mode)
+	stream, err := g.source.Open()
 	if err != nil {
-		fmt.Println("Warning: failed to open GPS port:", err)
-		g.running = false
-		return nil
+		return fmt.Errorf("failed to open GPS source %s: %w", g.source, err)
 	}
-	g.port = port
+	g.stream = stream
+	g.mu.Lock()
+	g.err = nil
+	g.ended = false
+	g.mu.Unlock()
 
-	ctxDone := make(chan struct{})
-	g.cancelFunc = func() { close(ctxDone) }
+	readCtx, cancel := context.WithCancel(context.Background())
+	g.cancel = cancel
+	g.stopped = make(chan struct{})
+	go g.readLoop(readCtx, stream, g.stopped)
 
-	g.wg.Add(1)
-	go func() {
-		defer g.wg.Done()
-		defer func() { g.running = false }() // reset when goroutine exits
+	return nil
+}
 
-		scanner := bufio.NewScanner(g.port)
-		scanner.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
-			for i := 0; i < len(data); i++ {
-				if data[i] == '\n' {
-					return i + 1, data[:i], nil
-				}
-			}
-			if atEOF && len(data) > 0 {
-				return len(data), data, nil
+// readLoop parses sentences from stream until it ends, fails or ctx is done
+func (g *GPS) readLoop(ctx context.Context, stream io.Reader, stopped chan struct{}) {
+	defer close(stopped)
+	defer func() {
+		g.mu.Lock()
+		g.running = false
+		g.mu.Unlock()
+	}()
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		for i := 0; i < len(data); i++ {
+			if data[i] == '\n' {
+				return i + 1, data[:i], nil
 			}
-			return 0, nil, nil
-		})
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
 
-		for {
-			select {
-			case <-ctxDone:
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if !scanner.Scan() {
+			err := scanner.Err()
+			if err == nil {
+				// Source exhausted (end of a replay or reader). This is not
+				// a failure, the supervisor leaves the reader stopped.
+				g.mu.Lock()
+				g.ended = true
+				g.mu.Unlock()
 				return
+			}
+			select {
+			case <-ctx.Done():
+				// The stream was closed by Close
 			default:
-				if !scanner.Scan() {
-					if err := scanner.Err(); err != nil {
-						fmt.Println("GPS read error:", err)
-					}
-					time.Sleep(100 * time.Millisecond)
-					continue
-				}
-
-				line := scanner.Text()
-				var msg nmea.Sentence
-				if len(line) > 0 && line[0] == '$' {
-					msg, err = nmea.Parse(line)
-					if err != nil {
-						continue
-					}
-				}
-
+				// A dead port stays dead, leave reopening it to the supervisor
+				fmt.Println("GPS read error:", err)
 				g.mu.Lock()
-				switch m := msg.(type) {
-				case nmea.GGA:
-					g.data.Time = m.Time.String()
-					g.data.Latitude = m.Latitude
-					g.data.Longitude = m.Longitude
-					g.data.Altitude = m.Altitude
-					g.data.Satellites = int(m.NumSatellites)
-					g.data.ValidFix = m.FixQuality > nmea.Invalid
-				case nmea.RMC:
-					g.data.Time = m.Time.String()
-					g.data.Latitude = m.Latitude
-					g.data.Longitude = m.Longitude
-					g.data.SpeedKph = m.Speed * 1.852
-					g.data.TrackAngle = m.Course
-					g.data.ValidFix = m.Validity == "A"
-				}
+				g.err = err
 				g.mu.Unlock()
-
-				g.running = true
-				time.Sleep(50 * time.Millisecond)
 			}
+			return
 		}
-	}()
 
-	return nil
+		line := scanner.Text()
+		if len(line) == 0 || line[0] != '$' {
+			continue
+		}
+		msg, err := nmea.Parse(line)
+		g.mu.Lock()
+		g.lastSeen = time.Now()
+		if err != nil {
+			g.badLines++
+			g.mu.Unlock()
+			continue
+		}
+		g.sentences++
+		g.running = true
+		complete := g.parser.apply(&g.data, msg)
+		data := g.data
+		if complete && g.filter != nil {
+			data = g.filter.step(data, g.parser.kinds[nmea.TypeRMC])
+			g.fix = data
+		}
+		g.mu.Unlock()
+
+		if complete {
+			g.fanout.publish(data)
+		}
+	}
 }
 
 // Read returns the latest GPS data as a map
@@ -133,37 +219,98 @@ func (g *GPS) Read() (GPSData, error) {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 
-	return GPSData{
-		Time:       g.data.Time,
-		Latitude:   g.data.Latitude,
-		Longitude:  g.data.Longitude,
-		Altitude:   g.data.Altitude,
-		Satellites: g.data.Satellites,
-		SpeedKph:   g.data.SpeedKph,
-		TrackAngle: g.data.TrackAngle,
-		ValidFix:   g.data.ValidFix,
-	}, nil
+	data := g.data
+	if g.filter != nil {
+		data = g.fix
+	}
+	data.SatellitesInView = append([]SatelliteInfo(nil), data.SatellitesInView...)
+	return data, nil
 }
 
 // Info returns online/offline status
 func (g *GPS) Info() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.ended {
+		return "offline (source ended)"
+	}
 	if !g.running {
 		return "offline"
 	}
-	if g.data.ValidFix {
+	data := g.data
+	if g.filter != nil {
+		data = g.fix
+	}
+	if data.ValidFix {
 		return "online (fix)"
 	}
 	return "online (no fix)"
 }
 
-// Close stops background reading and closes serial port
+// Health reports the receiver, degraded while it has no fix
+func (g *GPS) Health() hal.Health {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	data := g.data
+	if g.filter != nil {
+		data = g.fix
+	}
+	h := hal.Health{
+		State:    hal.HealthOK,
+		LastSeen: hal.LastSeen(g.lastSeen),
+		Counters: map[string]uint64{"sentences": g.sentences, "parseErrors": g.badLines},
+		Metrics: map[string]any{
+			"source":     g.source.String(),
+			"validFix":   data.ValidFix,
+			"fixType":    data.FixType,
+			"satellites": data.Satellites,
+			"hdop":       data.HDOP,
+			"estimated":  data.Estimated,
+		},
+	}
+	switch {
+	case g.err != nil:
+		h.State, h.Error = hal.HealthFailed, g.err.Error()
+	case g.ended:
+		h.State, h.Error = hal.HealthOffline, "source ended"
+	case !g.running:
+		h.State = hal.HealthOffline
+	case !data.ValidFix:
+		h.State, h.Error = hal.HealthDegraded, "no fix"
+	}
+	return h
+}
+
+// Err returns the read error that stopped the reader, nil while it runs
+func (g *GPS) Err() error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.err
+}
+
+// Close stops background reading and closes the sentence source
 func (g *GPS) Close() error {
-	if g.cancelFunc != nil {
-		g.cancelFunc()
+	return g.CloseContext(context.Background())
+}
+
+// CloseContext stops background reading and closes the sentence source,
+// waiting for the reader to exit until ctx is done
+func (g *GPS) CloseContext(ctx context.Context) error {
+	g.life.Lock()
+	defer g.life.Unlock()
+	if g.cancel == nil {
+		return nil
 	}
-	g.wg.Wait()
-	if g.port != nil {
-		return g.port.Close()
+	g.cancel()
+	// Closing the stream unblocks a pending read
+	err := g.stream.Close()
+	stopped := g.stopped
+	g.cancel, g.stream, g.stopped = nil, nil, nil
+
+	select {
+	case <-stopped:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("gps: reader did not stop: %w", ctx.Err())
 	}
-	return nil
 }
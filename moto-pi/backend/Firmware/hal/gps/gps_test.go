@@ -0,0 +1,121 @@
+package gps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
+)
+
+// sentence wraps an NMEA body in its start delimiter and checksum
+func sentence(body string) string {
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return fmt.Sprintf("$%s*%02X\r\n", body, sum)
+}
+
+// feed writes one GGA and RMC epoch per second of receiver time to w until
+// a write fails
+func feed(w io.Writer) error {
+	for i := 0; ; i++ {
+		t := fmt.Sprintf("12%02d%02d.00", i/60%60, i%60)
+		epoch := sentence("GPGGA,"+t+",4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,") +
+			sentence("GPRMC,"+t+",A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W")
+		if _, err := io.WriteString(w, epoch); err != nil {
+			return err
+		}
+	}
+}
+
+func TestConcurrentLifecycle(t *testing.T) {
+	pr, pw := io.Pipe()
+	g := NewGPSWithSource(&ReaderSource{Reader: pr})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fixes := g.Subscribe(ctx)
+
+	fed := make(chan error, 1)
+	go func() { fed <- feed(pw) }()
+
+	// Concurrent Init calls open the one-shot source once
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := g.Init(); err != nil {
+				t.Errorf("Init() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	select {
+	case fix := <-fixes:
+		if !fix.GoodFix(0) || fix.Latitude == 0 {
+			t.Errorf("published fix = %+v, want a good fix", fix)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no fix published")
+	}
+
+	// Readers race the reader loop and a Close that interrupts it mid-stream
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				data, _ := g.Read()
+				_ = len(data.SatellitesInView)
+				_ = g.Info()
+				_ = g.Health()
+				_ = g.Err()
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	var closers sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		closers.Add(1)
+		go func() {
+			defer closers.Done()
+			if err := g.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		}()
+	}
+	closers.Wait()
+	close(stop)
+	wg.Wait()
+
+	if err := <-fed; !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("writer error = %v, want %v", err, io.ErrClosedPipe)
+	}
+	if info := g.Info(); info != "offline" {
+		t.Errorf("Info() after Close = %q, want offline", info)
+	}
+	if h := g.Health(); h.State != hal.HealthOffline || h.Counters["sentences"] == 0 {
+		t.Errorf("Health() after Close = %+v, want offline with sentences counted", h)
+	}
+	if err := g.Err(); err != nil {
+		t.Errorf("Err() after Close = %v, want nil", err)
+	}
+	if err := g.Init(); err == nil || !strings.Contains(err.Error(), "already consumed") {
+		t.Errorf("Init() after Close error = %v, want the source consumed", err)
+	}
+}
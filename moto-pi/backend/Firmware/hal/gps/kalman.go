@@ -0,0 +1,223 @@
+package gps
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// FilterConfig enables and tunes the position smoothing filter
+type FilterConfig struct {
+	Enabled      bool    `json:"enabled"`
+	MaxPredict   string  `json:"maxPredict"`   // how long to dead-reckon through fix loss, e.g. "10s"
+	Acceleration float64 `json:"acceleration"` // expected acceleration noise in m/s², 0 uses the default
+	UERE         float64 `json:"uere"`         // user equivalent range error in metres, scaled by HDOP
+}
+
+const (
+	defaultMaxPredict   = 10 * time.Second
+	defaultAcceleration = 2.0 // m/s²
+	defaultUERE         = 4.0 // m
+	speedNoise          = 0.5 // m/s, standard deviation of reported ground speed
+	resetDistance       = 500 // m, measurements further from the estimate restart the filter
+	recenterDistance    = 10000
+)
+
+// axis is a constant-velocity Kalman filter along one local axis, holding
+// position p, velocity v and the symmetric covariance [[pp, pv], [pv, vv]]
+type axis struct {
+	p, v       float64
+	pp, pv, vv float64
+}
+
+// predict advances the state by dt seconds with white acceleration noise q
+func (a *axis) predict(dt, q float64) {
+	q2 := q * q
+	a.p += a.v * dt
+	a.pp += 2*dt*a.pv + dt*dt*a.vv + q2*dt*dt*dt*dt/4
+	a.pv += dt*a.vv + q2*dt*dt*dt/2
+	a.vv += q2 * dt * dt
+}
+
+// updatePosition folds in a position measurement z with variance r
+func (a *axis) updatePosition(z, r float64) {
+	s := a.pp + r
+	kp, kv := a.pp/s, a.pv/s
+	y := z - a.p
+	a.p += kp * y
+	a.v += kv * y
+	a.vv -= kv * a.pv
+	a.pv -= kp * a.pv
+	a.pp -= kp * a.pp
+}
+
+// updateVelocity folds in a velocity measurement z with variance r
+func (a *axis) updateVelocity(z, r float64) {
+	s := a.vv + r
+	kp, kv := a.pv/s, a.vv/s
+	y := z - a.v
+	a.p += kp * y
+	a.v += kv * y
+	a.pp -= kp * a.pv
+	a.pv -= kp * a.vv
+	a.vv -= kv * a.vv
+}
+
+// kalman smooths fixes with a constant-velocity model in a local east/north
+// plane and dead-reckons through short fix loss
+type kalman struct {
+	maxPredict time.Duration
+	accel      float64
+	uere       float64
+
+	ready        bool
+	lat0, lon0   float64 // origin of the local plane
+	east, north  axis
+	last         time.Time // time of the last predict or update
+	lastMeasured time.Time
+}
+
+// newKalman builds a filter from its config
+func newKalman(cfg FilterConfig) (*kalman, error) {
+	k := &kalman{
+		maxPredict: defaultMaxPredict,
+		accel:      defaultAcceleration,
+		uere:       defaultUERE,
+	}
+	if cfg.MaxPredict != "" {
+		d, err := time.ParseDuration(cfg.MaxPredict)
+		if err != nil {
+			return nil, fmt.Errorf("gps: invalid filter max predict: %w", err)
+		}
+		k.maxPredict = d
+	}
+	if cfg.Acceleration > 0 {
+		k.accel = cfg.Acceleration
+	}
+	if cfg.UERE > 0 {
+		k.uere = cfg.UERE
+	}
+	return k, nil
+}
+
+// step filters one epoch. Valid fixes update the estimate; invalid ones are
+// replaced by a prediction while the last measurement is recent enough.
+// hasVelocity tells whether the receiver reports ground speed (RMC).
+func (k *kalman) step(d GPSData, hasVelocity bool) GPSData {
+	now := d.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	if !d.ValidFix {
+		if !k.ready || now.Sub(k.lastMeasured) > k.maxPredict {
+			k.ready = false
+			return d
+		}
+		k.advance(now)
+		d.Estimated = true
+		d.ValidFix = true
+		d.FixType = FixEstimated
+		d.Confidence = 1 - now.Sub(k.lastMeasured).Seconds()/k.maxPredict.Seconds()
+		return k.output(d)
+	}
+
+	if !k.ready || now.Sub(k.lastMeasured) > k.maxPredict {
+		k.reset(d, now, hasVelocity)
+		return k.output(d)
+	}
+
+	k.advance(now)
+	e, n := k.project(d.Latitude, d.Longitude)
+	if math.Hypot(e-k.east.p, n-k.north.p) > resetDistance {
+		k.reset(d, now, hasVelocity)
+		return k.output(d)
+	}
+
+	r := k.positionVariance(d.HDOP)
+	k.east.updatePosition(e, r)
+	k.north.updatePosition(n, r)
+	if hasVelocity {
+		k.updateVelocity(d)
+	}
+	k.lastMeasured = now
+
+	if math.Hypot(k.east.p, k.north.p) > recenterDistance {
+		k.recenter()
+	}
+	return k.output(d)
+}
+
+// reset starts the filter at a measured fix
+func (k *kalman) reset(d GPSData, now time.Time, hasVelocity bool) {
+	r := k.positionVariance(d.HDOP)
+	k.lat0, k.lon0 = d.Latitude, d.Longitude
+	k.east = axis{pp: r, vv: 100}
+	k.north = axis{pp: r, vv: 100}
+	if hasVelocity {
+		k.updateVelocity(d)
+	}
+	k.last, k.lastMeasured = now, now
+	k.ready = true
+}
+
+// advance predicts the state forward to now
+func (k *kalman) advance(now time.Time) {
+	dt := now.Sub(k.last).Seconds()
+	if dt > 0 {
+		k.east.predict(dt, k.accel)
+		k.north.predict(dt, k.accel)
+	}
+	k.last = now
+}
+
+// updateVelocity folds in the reported ground speed and course
+func (k *kalman) updateVelocity(d GPSData) {
+	speed := d.SpeedKph / 3.6
+	course := d.TrackAngle * math.Pi / 180
+	r := speedNoise * speedNoise
+	k.east.updateVelocity(speed*math.Sin(course), r)
+	k.north.updateVelocity(speed*math.Cos(course), r)
+}
+
+// positionVariance estimates the measurement variance from HDOP
+func (k *kalman) positionVariance(hdop float64) float64 {
+	if hdop <= 0 {
+		hdop = 1
+	}
+	sigma := hdop * k.uere
+	return sigma * sigma
+}
+
+// output writes the filtered estimate into d
+func (k *kalman) output(d GPSData) GPSData {
+	d.Latitude, d.Longitude = k.unproject(k.east.p, k.north.p)
+	speed := math.Hypot(k.east.v, k.north.v)
+	d.SpeedKph = speed * 3.6
+	if speed > 1 {
+		d.TrackAngle = math.Mod(math.Atan2(k.east.v, k.north.v)*180/math.Pi+360, 360)
+	}
+	d.PositionSigma = math.Sqrt(k.east.pp + k.north.pp)
+	if !d.Estimated {
+		d.Confidence = 1
+	}
+	return d
+}
+
+// recenter moves the plane origin to the current estimate
+func (k *kalman) recenter() {
+	k.lat0, k.lon0 = k.unproject(k.east.p, k.north.p)
+	k.east.p, k.north.p = 0, 0
+}
+
+// project maps a coordinate into the local east/north plane in metres
+func (k *kalman) project(lat, lon float64) (east, north float64) {
+	scale := EarthRadiusMeters * math.Pi / 180
+	return (lon - k.lon0) * scale * math.Cos(k.lat0*math.Pi/180), (lat - k.lat0) * scale
+}
+
+// unproject maps local plane metres back to a coordinate
+func (k *kalman) unproject(east, north float64) (lat, lon float64) {
+	scale := EarthRadiusMeters * math.Pi / 180
+	return k.lat0 + north/scale, k.lon0 + east/(scale*math.Cos(k.lat0*math.Pi/180))
+}
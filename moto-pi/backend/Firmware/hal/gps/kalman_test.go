@@ -0,0 +1,172 @@
+package gps
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var kalmanStart = time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+// measured is a 3D fix sec seconds in, north metres north of 48°N 11°E,
+// riding north at 36 km/h
+func measured(sec, north float64) GPSData {
+	return GPSData{
+		Timestamp:  kalmanStart.Add(time.Duration(sec * float64(time.Second))),
+		Latitude:   48 + north/(EarthRadiusMeters*math.Pi/180),
+		Longitude:  11,
+		SpeedKph:   36,
+		ValidFix:   true,
+		FixType:    Fix3D,
+		HDOP:       1,
+		Satellites: 8,
+	}
+}
+
+// lost is an epoch without a fix sec seconds in
+func lost(sec float64) GPSData {
+	return GPSData{Timestamp: kalmanStart.Add(time.Duration(sec * float64(time.Second))), FixType: FixNone}
+}
+
+// northOf returns how far north of the start latitude d lies
+func northOf(d GPSData) float64 {
+	return (d.Latitude - 48) * EarthRadiusMeters * math.Pi / 180
+}
+
+func newTestKalman(t *testing.T, maxPredict string) *kalman {
+	t.Helper()
+	k, err := newKalman(FilterConfig{Enabled: true, MaxPredict: maxPredict})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestKalmanDeadReckoning(t *testing.T) {
+	k := newTestKalman(t, "5s")
+	for sec := 0.0; sec <= 4; sec++ {
+		k.step(measured(sec, 10*sec), true)
+	}
+
+	// Fix loss after the measurement at 4s: the filter carries on at 10 m/s
+	// until maxPredict has passed since that measurement
+	tests := []struct {
+		sec           float64
+		wantEstimated bool
+		wantNorth     float64
+		wantConf      float64
+	}{
+		{5, true, 50, 0.8},
+		{7, true, 70, 0.4},
+		{9, true, 90, 0},
+		{9.5, false, 0, 0},
+		{10, false, 0, 0},
+	}
+	prevSigma := 0.0
+	for _, tt := range tests {
+		got := k.step(lost(tt.sec), true)
+		if got.Estimated != tt.wantEstimated || got.ValidFix != tt.wantEstimated {
+			t.Fatalf("at %vs: Estimated = %v, ValidFix = %v, want %v", tt.sec, got.Estimated, got.ValidFix, tt.wantEstimated)
+		}
+		if !tt.wantEstimated {
+			if got.FixType != FixNone || got.Latitude != 0 {
+				t.Errorf("at %vs: %+v, want the raw epoch without a fix", tt.sec, got)
+			}
+			continue
+		}
+		if got.FixType != FixEstimated || got.GoodFix(0) {
+			t.Errorf("at %vs: FixType = %s, GoodFix = %v, want an estimate that is not a good fix", tt.sec, got.FixType, got.GoodFix(0))
+		}
+		if north := northOf(got); math.Abs(north-tt.wantNorth) > 1 {
+			t.Errorf("at %vs: %.1f m north, want %.0f m", tt.sec, north, tt.wantNorth)
+		}
+		if math.Abs(got.SpeedKph-36) > 1 {
+			t.Errorf("at %vs: SpeedKph = %.1f, want 36", tt.sec, got.SpeedKph)
+		}
+		if math.Abs(got.Confidence-tt.wantConf) > 1e-9 {
+			t.Errorf("at %vs: Confidence = %v, want %v", tt.sec, got.Confidence, tt.wantConf)
+		}
+		if got.PositionSigma <= prevSigma {
+			t.Errorf("at %vs: PositionSigma = %.2f, want it growing past %.2f", tt.sec, got.PositionSigma, prevSigma)
+		}
+		prevSigma = got.PositionSigma
+	}
+
+	// The next fix restarts the filter at the measurement
+	got := k.step(measured(20, 500), true)
+	if got.Estimated || got.Confidence != 1 || math.Abs(northOf(got)-500) > 1e-6 {
+		t.Errorf("after the gap: %+v, want the measured fix", got)
+	}
+}
+
+func TestKalmanNoPredictionBeforeFirstFix(t *testing.T) {
+	k := newTestKalman(t, "")
+	if got := k.step(lost(0), true); got.ValidFix || got.Estimated {
+		t.Errorf("step() = %+v, want no estimate without a measurement", got)
+	}
+}
+
+func TestKalmanSmoothing(t *testing.T) {
+	k := newTestKalman(t, "")
+
+	// Noisy fixes along a straight line stay within a few metres of it
+	noise := []float64{3, -4, 2, -3, 4, -2, 3, -4, 2, -3}
+	var got GPSData
+	for i, n := range noise {
+		sec := float64(i)
+		got = k.step(measured(sec, 10*sec+n), true)
+		if got.Estimated || got.Confidence != 1 || !got.GoodFix(0) {
+			t.Fatalf("at %vs: %+v, want a measured good fix", sec, got)
+		}
+	}
+	if d := math.Abs(northOf(got) - 90); d > 2 {
+		t.Errorf("filtered position is %.1f m off the track, want under 2 m", d)
+	}
+	if got.PositionSigma <= 0 || got.PositionSigma > 4*math.Sqrt2 {
+		t.Errorf("PositionSigma = %.2f, want it within the measurement error", got.PositionSigma)
+	}
+
+	// A jump further than resetDistance restarts at the measurement
+	got = k.step(measured(10, 5000), true)
+	if math.Abs(northOf(got)-5000) > 1e-6 {
+		t.Errorf("after a jump: %.1f m north, want 5000", northOf(got))
+	}
+}
+
+func TestNewKalman(t *testing.T) {
+	k := newTestKalman(t, "")
+	if k.maxPredict != defaultMaxPredict || k.accel != defaultAcceleration || k.uere != defaultUERE {
+		t.Errorf("defaults = %v, %v, %v", k.maxPredict, k.accel, k.uere)
+	}
+	if _, err := newKalman(FilterConfig{MaxPredict: "soon"}); err == nil {
+		t.Error("newKalman() accepted an invalid maxPredict")
+	}
+}
+
+func TestGoodFix(t *testing.T) {
+	good := measured(0, 0)
+	tests := []struct {
+		name    string
+		fix     func(d *GPSData)
+		maxHDOP float64
+		want    bool
+	}{
+		{"measured", func(d *GPSData) {}, 0, true},
+		{"within hdop", func(d *GPSData) { d.HDOP = 2 }, 2, true},
+		{"above hdop", func(d *GPSData) { d.HDOP = 2.1 }, 2, false},
+		{"unknown hdop with a limit", func(d *GPSData) { d.HDOP = 0 }, 2, false},
+		{"invalid", func(d *GPSData) { d.ValidFix = false }, 0, false},
+		{"no fix type", func(d *GPSData) { d.FixType = FixNone }, 0, false},
+		{"estimated", func(d *GPSData) { d.Estimated, d.FixType = true, FixEstimated }, 0, false},
+		{"estimated within hdop", func(d *GPSData) { d.Estimated, d.FixType = true, FixEstimated }, 5, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := good
+			tt.fix(&d)
+			if got := d.GoodFix(tt.maxHDOP); got != tt.want {
+				t.Errorf("GoodFix(%v) = %v, want %v", tt.maxHDOP, got, tt.want)
+			}
+		})
+	}
+}
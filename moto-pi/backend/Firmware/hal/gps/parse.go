@@ -0,0 +1,246 @@
+package gps
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/adrianmo/go-nmea"
+)
+
+// FixType describes the kind of position solution
+type FixType string
+
+const (
+	FixNone FixType = "none"
+	Fix2D   FixType = "2d"
+	Fix3D   FixType = "3d"
+	FixDGPS FixType = "dgps"
+	FixRTK  FixType = "rtk"
+	// FixEstimated marks a dead-reckoned position during fix loss
+	FixEstimated FixType = "estimated"
+)
+
+// SatelliteInfo describes one satellite in view, as reported by GSV
+type SatelliteInfo struct {
+	Constellation string `json:"constellation"` // talker of the GSV sentence, e.g. GP, GL, GA, GB
+	PRN           int    `json:"prn"`
+	Elevation     int    `json:"elevation"` // degrees
+	Azimuth       int    `json:"azimuth"`   // degrees from true north
+	SNR           int    `json:"snr"`       // dB-Hz, 0 when not tracking
+	Used          bool   `json:"used"`
+}
+
+// parser folds individual NMEA sentences into a GPSData snapshot. It keeps
+// the state that spans several sentences: the current date, the GGA quality
+// and GSA dimension that together give the fix type, and partial GSV cycles.
+type parser struct {
+	kinds     map[string]bool // position sentence types this receiver emits
+	epoch     nmea.Time       // time of the epoch being assembled
+	seen      map[string]bool // position sentences received for epoch
+	date      nmea.Date
+	quality   string
+	dimension string
+	published bool                       // epoch was already published
+	used      map[satellite]bool         // satellites used in the solution, from the GSAs of one epoch
+	usedDone  bool                       // the epoch completed, the next GSA starts a new used set
+	gsv       map[string][]SatelliteInfo // GSV cycle being assembled, per talker
+	inView    map[string][]SatelliteInfo // last complete GSV cycle, per talker
+}
+
+// satellite identifies a satellite across constellations, PRNs overlap
+// between systems
+type satellite struct {
+	system string
+	prn    int
+}
+
+// gsaSystems maps the NMEA 4.1 GSA system ID to the GSV talker of that system
+var gsaSystems = map[int64]string{1: "GP", 2: "GL", 3: "GA", 4: "GB", 5: "GQ", 6: "GI"}
+
+// apply merges msg into d and reports whether it completed an epoch, i.e.
+// every position sentence the receiver emits has arrived for the same time
+func (p *parser) apply(d *GPSData, msg nmea.Sentence) bool {
+	switch m := msg.(type) {
+	case nmea.GGA:
+		d.Time = m.Time.String()
+		d.Timestamp = p.timestamp(m.Time)
+		d.Latitude = m.Latitude
+		d.Longitude = m.Longitude
+		d.Altitude = m.Altitude
+		d.Satellites = int(m.NumSatellites)
+		d.HDOP = m.HDOP
+		d.ValidFix = m.FixQuality > nmea.Invalid
+		d.Confidence = confidence(d.ValidFix)
+		p.quality = m.FixQuality
+		d.FixType = p.fixType(d.ValidFix)
+		return p.mark(m.DataType(), m.Time)
+	case nmea.RMC:
+		if m.Date.Valid {
+			p.date = m.Date
+		}
+		d.Time = m.Time.String()
+		d.Timestamp = p.timestamp(m.Time)
+		d.Latitude = m.Latitude
+		d.Longitude = m.Longitude
+		d.SpeedKph = m.Speed * 1.852
+		d.TrackAngle = m.Course
+		d.ValidFix = m.Validity == "A"
+		d.Confidence = confidence(d.ValidFix)
+		d.FixType = p.fixType(d.ValidFix)
+		return p.mark(m.DataType(), m.Time)
+	case nmea.ZDA:
+		if m.Year > 0 {
+			p.date = nmea.Date{Valid: true, DD: int(m.Day), MM: int(m.Month), YY: int(m.Year % 100)}
+		}
+		d.Time = m.Time.String()
+		d.Timestamp = p.timestamp(m.Time)
+	case nmea.GSA:
+		p.dimension = m.FixType
+		// Multi-GNSS receivers send one GSA per system each epoch
+		if p.used == nil || p.usedDone {
+			p.used = make(map[satellite]bool)
+			p.usedDone = false
+		}
+		for _, sv := range m.SV {
+			if prn, err := strconv.Atoi(sv); err == nil {
+				p.used[satellite{gsaSystem(m, prn), prn}] = true
+			}
+		}
+		d.PDOP = m.PDOP
+		d.HDOP = m.HDOP
+		d.VDOP = m.VDOP
+		d.FixType = p.fixType(d.ValidFix)
+		d.SatellitesInView = p.satellites()
+	case nmea.GSV:
+		p.collectGSV(m)
+		d.SatellitesInView = p.satellites()
+	case nmea.VTG:
+		d.GroundSpeedKph = m.GroundSpeedKPH
+	}
+	return false
+}
+
+// mark records a position sentence for the epoch at t and reports whether
+// the epoch is now complete. Each epoch completes once: while the sentence
+// types are still being learnt, a late type must not publish it again.
+func (p *parser) mark(kind string, t nmea.Time) bool {
+	if p.kinds == nil {
+		p.kinds = make(map[string]bool)
+	}
+	p.kinds[kind] = true
+	if p.seen == nil || t != p.epoch {
+		p.epoch = t
+		p.seen = make(map[string]bool)
+		p.published = false
+	}
+	p.seen[kind] = true
+
+	// Sentences without a time cannot be told apart, each one completes
+	if p.published && t.Valid {
+		return false
+	}
+	for k := range p.kinds {
+		if !p.seen[k] {
+			return false
+		}
+	}
+	p.published = true
+	p.usedDone = true
+	return true
+}
+
+// gsaSystem returns the system of a satellite used in m. GN talkers send one
+// GSA per system, identified by the NMEA 4.1 system ID or, before that, by
+// the PRN range.
+func gsaSystem(m nmea.GSA, prn int) string {
+	if talker := m.TalkerID(); talker != "GN" {
+		return talker
+	}
+	if system, ok := gsaSystems[m.SystemID]; ok {
+		return system
+	}
+	if prn >= 65 && prn <= 96 {
+		return "GL"
+	}
+	return "GP"
+}
+
+// confidence of an unfiltered fix
+func confidence(valid bool) float64 {
+	if valid {
+		return 1
+	}
+	return 0
+}
+
+// timestamp combines the last known date with a time of day
+func (p *parser) timestamp(t nmea.Time) time.Time {
+	return nmea.DateTime(0, p.date, t)
+}
+
+// fixType derives the fix type from the GGA quality and GSA dimension
+func (p *parser) fixType(valid bool) FixType {
+	if !valid {
+		return FixNone
+	}
+	switch p.quality {
+	case nmea.DGPS:
+		return FixDGPS
+	case nmea.RTK, nmea.FRTK:
+		return FixRTK
+	}
+	switch p.dimension {
+	case nmea.FixNone:
+		return FixNone
+	case nmea.Fix2D:
+		return Fix2D
+	case nmea.Fix3D:
+		return Fix3D
+	}
+	// Valid fix without a GSA sentence; GGA implies a 3D solution
+	return Fix3D
+}
+
+// collectGSV buffers a GSV sentence and publishes the cycle once complete
+func (p *parser) collectGSV(m nmea.GSV) {
+	if p.gsv == nil {
+		p.gsv = make(map[string][]SatelliteInfo)
+		p.inView = make(map[string][]SatelliteInfo)
+	}
+	talker := m.TalkerID()
+	if m.MessageNumber == 1 {
+		p.gsv[talker] = nil
+	}
+	for _, info := range m.Info {
+		p.gsv[talker] = append(p.gsv[talker], SatelliteInfo{
+			Constellation: talker,
+			PRN:           int(info.SVPRNNumber),
+			Elevation:     int(info.Elevation),
+			Azimuth:       int(info.Azimuth),
+			SNR:           int(info.SNR),
+		})
+	}
+	if m.MessageNumber == m.TotalMessages {
+		p.inView[talker] = p.gsv[talker]
+		delete(p.gsv, talker)
+	}
+}
+
+// satellites returns the satellites in view across all talkers
+func (p *parser) satellites() []SatelliteInfo {
+	talkers := make([]string, 0, len(p.inView))
+	for talker := range p.inView {
+		talkers = append(talkers, talker)
+	}
+	sort.Strings(talkers)
+
+	var sats []SatelliteInfo
+	for _, talker := range talkers {
+		for _, sat := range p.inView[talker] {
+			sat.Used = p.used[satellite{sat.Constellation, sat.PRN}]
+			sats = append(sats, sat)
+		}
+	}
+	return sats
+}
@@ -0,0 +1,246 @@
+package gps
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adrianmo/go-nmea"
+)
+
+// feedParser applies NMEA bodies in order and returns how many epochs completed
+func feedParser(t *testing.T, p *parser, d *GPSData, bodies ...string) int {
+	t.Helper()
+	published := 0
+	for _, body := range bodies {
+		msg, err := nmea.Parse(strings.TrimSpace(sentence(body)))
+		if err != nil {
+			t.Fatalf("parse %q: %v", body, err)
+		}
+		if p.apply(d, msg) {
+			published++
+		}
+	}
+	return published
+}
+
+// usedSatellites lists the satellites in view flagged as used, e.g. "GP04"
+func usedSatellites(d GPSData) []string {
+	var used []string
+	for _, sat := range d.SatellitesInView {
+		if sat.Used {
+			used = append(used, fmt.Sprintf("%s%02d", sat.Constellation, sat.PRN))
+		}
+	}
+	return used
+}
+
+// Sentences of one receiver epoch at 12:00:0<n>
+func rmc(n int, status string) string {
+	return fmt.Sprintf("GPRMC,12000%d.00,%s,4807.0380,N,01131.0000,E,19.438,84.4,150326,,,A", n, status)
+}
+
+func gga(n int, quality string) string {
+	return fmt.Sprintf("GPGGA,12000%d.00,4807.0380,N,01131.0000,E,%s,07,1.2,545.4,M,46.9,M,,", n, quality)
+}
+
+const (
+	gsa3D  = "GPGSA,A,3,04,05,09,,,,,,,,,,2.1,1.2,1.7"
+	gsa2D  = "GPGSA,A,2,04,05,09,,,,,,,,,,3.5,2.4,2.5"
+	gsv1of = "GPGSV,2,1,05,04,40,083,46,05,12,300,38,09,64,145,44,12,22,210,41"
+	gsv2of = "GPGSV,2,2,05,24,55,045,45"
+	vtg    = "GPVTG,84.4,T,,M,19.438,N,36.000,K,A"
+)
+
+func TestParserApply(t *testing.T) {
+	tests := []struct {
+		name          string
+		sentences     []string
+		wantPublished int
+		wantFixType   FixType
+		wantValid     bool
+		wantDOP       [3]float64 // PDOP, HDOP, VDOP
+		wantTime      time.Time
+		wantInView    int
+		wantUsed      []string
+		wantGround    float64
+	}{
+		{
+			name: "full epochs",
+			sentences: []string{
+				rmc(0, "A"), vtg, gga(0, "1"), gsa3D, gsv1of, gsv2of,
+				rmc(1, "A"), vtg, gga(1, "1"), gsa3D, gsv1of, gsv2of,
+			},
+			wantPublished: 2,
+			wantFixType:   Fix3D,
+			wantValid:     true,
+			wantDOP:       [3]float64{2.1, 1.2, 1.7},
+			wantTime:      time.Date(2026, 3, 15, 12, 0, 1, 0, time.UTC),
+			wantInView:    5,
+			wantUsed:      []string{"GP04", "GP05", "GP09"},
+			wantGround:    36,
+		},
+		{
+			name:          "2d fix",
+			sentences:     []string{rmc(0, "A"), gga(0, "1"), gsa2D},
+			wantPublished: 1,
+			wantFixType:   Fix2D,
+			wantValid:     true,
+			wantDOP:       [3]float64{3.5, 2.4, 2.5},
+			wantTime:      time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "dgps",
+			sentences:     []string{rmc(0, "A"), gga(0, "2"), gsa3D},
+			wantPublished: 1,
+			wantFixType:   FixDGPS,
+			wantValid:     true,
+			wantDOP:       [3]float64{2.1, 1.2, 1.7},
+			wantTime:      time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "rtk",
+			sentences:     []string{gga(0, "4")},
+			wantPublished: 1,
+			wantFixType:   FixRTK,
+			wantValid:     true,
+			wantDOP:       [3]float64{0, 1.2, 0},
+			wantTime:      time.Time{}, // no date yet
+		},
+		{
+			name:          "no fix",
+			sentences:     []string{rmc(0, "V"), gga(0, "0")},
+			wantPublished: 1,
+			wantFixType:   FixNone,
+			wantDOP:       [3]float64{0, 1.2, 0},
+			wantTime:      time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "date from zda",
+			sentences:     []string{"GPZDA,120000.00,14,03,2026,00,00", gga(1, "1")},
+			wantPublished: 1,
+			wantFixType:   Fix3D,
+			wantValid:     true,
+			wantDOP:       [3]float64{0, 1.2, 0},
+			wantTime:      time.Date(2026, 3, 14, 12, 0, 1, 0, time.UTC),
+		},
+		{
+			name: "used set follows the latest epoch",
+			sentences: []string{
+				rmc(0, "A"), gga(0, "1"), gsa3D, gsv1of, gsv2of,
+				rmc(1, "A"), gga(1, "1"), "GPGSA,A,3,12,24,,,,,,,,,,,2.1,1.2,1.7",
+			},
+			wantPublished: 2,
+			wantFixType:   Fix3D,
+			wantValid:     true,
+			wantDOP:       [3]float64{2.1, 1.2, 1.7},
+			wantTime:      time.Date(2026, 3, 15, 12, 0, 1, 0, time.UTC),
+			wantInView:    5,
+			wantUsed:      []string{"GP12", "GP24"},
+		},
+		{
+			name: "multi-gnss gsa by system id",
+			sentences: []string{
+				rmc(0, "A"), gga(0, "1"),
+				"GPGSV,1,1,02,05,40,083,46,09,12,300,38",
+				"GAGSV,1,1,02,05,64,145,44,11,22,210,41",
+				"GLGSV,1,1,01,70,55,045,45",
+				"GNGSA,A,3,05,,,,,,,,,,,,2.1,1.2,1.7,1",
+				"GNGSA,A,3,11,,,,,,,,,,,,2.1,1.2,1.7,3",
+				"GNGSA,A,3,70,,,,,,,,,,,,2.1,1.2,1.7,2",
+			},
+			wantPublished: 1,
+			wantFixType:   Fix3D,
+			wantValid:     true,
+			wantDOP:       [3]float64{2.1, 1.2, 1.7},
+			wantTime:      time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC),
+			wantInView:    5,
+			wantUsed:      []string{"GA11", "GL70", "GP05"},
+		},
+		{
+			name: "multi-gnss gsa by prn range",
+			sentences: []string{
+				rmc(0, "A"), gga(0, "1"),
+				"GPGSV,1,1,02,05,40,083,46,09,12,300,38",
+				"GLGSV,1,1,02,70,55,045,45,71,10,100,30",
+				"GNGSA,A,3,09,,,,,,,,,,,,2.1,1.2,1.7",
+				"GNGSA,A,3,71,,,,,,,,,,,,2.1,1.2,1.7",
+			},
+			wantPublished: 1,
+			wantFixType:   Fix3D,
+			wantValid:     true,
+			wantDOP:       [3]float64{2.1, 1.2, 1.7},
+			wantTime:      time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC),
+			wantInView:    4,
+			wantUsed:      []string{"GL71", "GP09"},
+		},
+		{
+			name: "repeated sentences publish an epoch once",
+			sentences: []string{
+				rmc(0, "A"), gga(0, "1"), gga(0, "1"), rmc(0, "A"),
+				rmc(1, "A"), rmc(1, "A"), gga(1, "1"), gga(1, "1"),
+			},
+			wantPublished: 2,
+			wantFixType:   Fix3D,
+			wantValid:     true,
+			wantDOP:       [3]float64{0, 1.2, 0},
+			wantTime:      time.Date(2026, 3, 15, 12, 0, 1, 0, time.UTC),
+		},
+		{
+			name:          "incomplete gsv cycle",
+			sentences:     []string{rmc(0, "A"), gga(0, "1"), gsa3D, gsv1of},
+			wantPublished: 1,
+			wantFixType:   Fix3D,
+			wantValid:     true,
+			wantDOP:       [3]float64{2.1, 1.2, 1.7},
+			wantTime:      time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p parser
+			var d GPSData
+			if got := feedParser(t, &p, &d, tt.sentences...); got != tt.wantPublished {
+				t.Errorf("published %d epochs, want %d", got, tt.wantPublished)
+			}
+			if d.FixType != tt.wantFixType || d.ValidFix != tt.wantValid {
+				t.Errorf("FixType = %s, ValidFix = %v, want %s, %v", d.FixType, d.ValidFix, tt.wantFixType, tt.wantValid)
+			}
+			if dop := [3]float64{d.PDOP, d.HDOP, d.VDOP}; dop != tt.wantDOP {
+				t.Errorf("PDOP, HDOP, VDOP = %v, want %v", dop, tt.wantDOP)
+			}
+			if !d.Timestamp.Equal(tt.wantTime) {
+				t.Errorf("Timestamp = %v, want %v", d.Timestamp, tt.wantTime)
+			}
+			if len(d.SatellitesInView) != tt.wantInView {
+				t.Errorf("%d satellites in view, want %d", len(d.SatellitesInView), tt.wantInView)
+			}
+			if used := usedSatellites(d); !slices.Equal(used, tt.wantUsed) {
+				t.Errorf("used satellites = %v, want %v", used, tt.wantUsed)
+			}
+			if d.GroundSpeedKph != tt.wantGround {
+				t.Errorf("GroundSpeedKph = %v, want %v", d.GroundSpeedKph, tt.wantGround)
+			}
+		})
+	}
+}
+
+func TestParserSatellites(t *testing.T) {
+	var p parser
+	var d GPSData
+	feedParser(t, &p, &d, rmc(0, "A"), gga(0, "1"), gsa3D, gsv1of, gsv2of)
+
+	want := []SatelliteInfo{
+		{Constellation: "GP", PRN: 4, Elevation: 40, Azimuth: 83, SNR: 46, Used: true},
+		{Constellation: "GP", PRN: 5, Elevation: 12, Azimuth: 300, SNR: 38, Used: true},
+		{Constellation: "GP", PRN: 9, Elevation: 64, Azimuth: 145, SNR: 44, Used: true},
+		{Constellation: "GP", PRN: 12, Elevation: 22, Azimuth: 210, SNR: 41},
+		{Constellation: "GP", PRN: 24, Elevation: 55, Azimuth: 45, SNR: 45},
+	}
+	if !slices.Equal(d.SatellitesInView, want) {
+		t.Errorf("SatellitesInView = %+v, want %+v", d.SatellitesInView, want)
+	}
+}
@@ -0,0 +1,254 @@
+package gps
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adrianmo/go-nmea"
+	"go.bug.st/serial"
+)
+
+// Source types selectable from SourceConfig
+const (
+	SourceSerial = "serial"
+	SourceReplay = "replay"
+)
+
+// Source opens a stream of NMEA sentences for the GPS reader
+type Source interface {
+	Open() (io.ReadCloser, error)
+	String() string
+}
+
+// SourceConfig selects and configures a Source
+type SourceConfig struct {
+	Type     string  `json:"type"`     // "serial" (default) or "replay"
+	Port     string  `json:"port"`     // serial device, e.g. /dev/ttyAMA0
+	BaudRate int     `json:"baudRate"` // serial baud rate
+	Path     string  `json:"path"`     // recorded .nmea file for replay
+	Speed    float64 `json:"speed"`    // replay speed multiplier, 0 replays as fast as possible
+	Loop     bool    `json:"loop"`     // restart the replay when the file ends
+}
+
+// NewSource builds the Source described by cfg
+func NewSource(cfg SourceConfig) (Source, error) {
+	switch cfg.Type {
+	case "", SourceSerial:
+		if cfg.Port == "" {
+			return nil, errors.New("gps: serial source requires a port")
+		}
+		return &SerialSource{PortName: cfg.Port, BaudRate: cfg.BaudRate}, nil
+	case SourceReplay:
+		if cfg.Path == "" {
+			return nil, errors.New("gps: replay source requires a path")
+		}
+		return &ReplaySource{Path: cfg.Path, Speed: cfg.Speed, Loop: cfg.Loop}, nil
+	default:
+		return nil, fmt.Errorf("gps: unknown source type %q", cfg.Type)
+	}
+}
+
+// serialReadTimeout bounds each read of the serial port, so a closed stream
+// is noticed even when the driver does not unblock a pending read
+const serialReadTimeout = 500 * time.Millisecond
+
+// SerialSource reads sentences from a receiver on a serial port
+type SerialSource struct {
+	PortName string
+	BaudRate int
+}
+
+// Open opens the serial port
+func (s *SerialSource) Open() (io.ReadCloser, error) {
+	port, err := serial.Open(s.PortName, &serial.Mode{BaudRate: s.BaudRate})
+	if err != nil {
+		return nil, err
+	}
+	if err := port.SetReadTimeout(serialReadTimeout); err != nil {
+		port.Close()
+		return nil, err
+	}
+	return &serialStream{port: port, done: make(chan struct{})}, nil
+}
+
+func (s *SerialSource) String() string {
+	return fmt.Sprintf("serial %s@%d", s.PortName, s.BaudRate)
+}
+
+// serialStream reads a port opened with a read timeout, retrying timed out
+// reads until Close
+type serialStream struct {
+	port serial.Port
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func (s *serialStream) Read(p []byte) (int, error) {
+	for {
+		n, err := s.port.Read(p)
+		if n > 0 || err != nil {
+			return n, err
+		}
+		// A timeout reads nothing
+		select {
+		case <-s.done:
+			return 0, io.EOF
+		default:
+		}
+	}
+}
+
+func (s *serialStream) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return s.port.Close()
+}
+
+// ReaderSource reads sentences from an arbitrary io.Reader. It can only be opened once.
+type ReaderSource struct {
+	Reader io.Reader
+	opened bool
+}
+
+// Open returns the wrapped reader
+func (s *ReaderSource) Open() (io.ReadCloser, error) {
+	if s.opened {
+		return nil, errors.New("gps: reader source already consumed")
+	}
+	s.opened = true
+	if rc, ok := s.Reader.(io.ReadCloser); ok {
+		return rc, nil
+	}
+	return io.NopCloser(s.Reader), nil
+}
+
+func (s *ReaderSource) String() string {
+	return "reader"
+}
+
+// ReplaySource replays a recorded .nmea file, pacing sentences by their
+// timestamps divided by Speed. A Speed of 0 replays without delays.
+// Without Loop the reader stops at the end of the file and the GPS reports
+// itself offline with "source ended".
+type ReplaySource struct {
+	Path  string
+	Speed float64
+	Loop  bool
+}
+
+// Open opens the recording and starts the replay
+func (s *ReplaySource) Open() (io.ReadCloser, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &replayReader{src: s, file: f, lines: bufio.NewReader(f), done: make(chan struct{})}, nil
+}
+
+func (s *ReplaySource) String() string {
+	return fmt.Sprintf("replay %s (x%g)", s.Path, s.Speed)
+}
+
+// replayReader hands out the recording line by line, sleeping whenever the
+// sentence timestamp advances
+type replayReader struct {
+	src     *ReplaySource
+	file    *os.File
+	lines   *bufio.Reader
+	pending []byte
+	last    time.Duration
+	hasLast bool
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func (r *replayReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		line, err := r.lines.ReadBytes('\n')
+		if len(line) > 0 {
+			if err := r.pace(string(line)); err != nil {
+				return 0, err
+			}
+			r.pending = line
+			break
+		}
+		if err == io.EOF && r.src.Loop {
+			if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+				return 0, err
+			}
+			r.lines.Reset(r.file)
+			r.hasLast = false
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// pace sleeps for the gap between the previous and current sentence time
+func (r *replayReader) pace(line string) error {
+	if r.src.Speed <= 0 {
+		return nil
+	}
+	ts, ok := sentenceTime(line)
+	if !ok {
+		return nil
+	}
+	if r.hasLast && ts > r.last {
+		delay := time.Duration(float64(ts-r.last) / r.src.Speed)
+		select {
+		case <-time.After(delay):
+		case <-r.done:
+			return io.EOF
+		}
+	}
+	r.last = ts
+	r.hasLast = true
+	return nil
+}
+
+func (r *replayReader) Close() error {
+	r.closeOnce.Do(func() { close(r.done) })
+	return r.file.Close()
+}
+
+// sentenceTime returns the time of day carried by a timestamped sentence
+func sentenceTime(line string) (time.Duration, bool) {
+	line = strings.TrimSpace(line)
+	if len(line) == 0 || line[0] != '$' {
+		return 0, false
+	}
+	msg, err := nmea.Parse(line)
+	if err != nil {
+		return 0, false
+	}
+
+	var t nmea.Time
+	switch m := msg.(type) {
+	case nmea.GGA:
+		t = m.Time
+	case nmea.RMC:
+		t = m.Time
+	default:
+		return 0, false
+	}
+	if !t.Valid {
+		return 0, false
+	}
+	return time.Duration(t.Hour)*time.Hour +
+		time.Duration(t.Minute)*time.Minute +
+		time.Duration(t.Second)*time.Second +
+		time.Duration(t.Millisecond)*time.Millisecond, true
+}
@@ -0,0 +1,153 @@
+package gps
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
+)
+
+// rideFixture is a five second ride north at 36 km/h, one epoch per second
+const rideFixture = "testdata/ride.nmea"
+
+// waitEnded polls g until its source has ended
+func waitEnded(t *testing.T, g *GPS) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for g.Health().Error != "source ended" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Health() = %+v, want the source ended", g.Health())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// replay runs the ride fixture through a GPS and returns the published fixes
+func replay(t *testing.T, cfg SourceConfig) (*GPS, []GPSData) {
+	t.Helper()
+	cfg.Type, cfg.Path = SourceReplay, rideFixture
+	source, err := NewSource(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGPSWithSource(source)
+	ctx, cancel := context.WithCancel(context.Background())
+	fixes := g.Subscribe(ctx)
+	if err := g.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { g.Close() })
+
+	waitEnded(t, g)
+	cancel()
+	var got []GPSData
+	for fix := range fixes {
+		got = append(got, fix)
+	}
+	return g, got
+}
+
+func TestReplaySource(t *testing.T) {
+	g, fixes := replay(t, SourceConfig{})
+
+	if len(fixes) != 5 {
+		t.Fatalf("published %d fixes, want one per epoch (5)", len(fixes))
+	}
+	start := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	for i, fix := range fixes {
+		if want := start.Add(time.Duration(i) * time.Second); !fix.Timestamp.Equal(want) {
+			t.Errorf("fix %d: Timestamp = %v, want %v", i, fix.Timestamp, want)
+		}
+		if !fix.GoodFix(0) || math.Abs(fix.SpeedKph-36) > 0.01 {
+			t.Errorf("fix %d = %+v, want a good fix at 36 km/h", i, fix)
+		}
+		if i > 0 {
+			if d := DistanceMeters(fixes[i-1].Latitude, fixes[i-1].Longitude, fix.Latitude, fix.Longitude); math.Abs(d-10) > 0.5 {
+				t.Errorf("fix %d moved %.1f m, want 10 m", i, d)
+			}
+		}
+	}
+
+	// The reader stopped at the end of the file without failing
+	last, _ := g.Read()
+	if last.FixType != Fix3D || last.PDOP != 2.1 || last.VDOP != 1.7 || len(last.SatellitesInView) != 8 {
+		t.Errorf("Read() = %+v, want the last epoch with its GSA and GSV", last)
+	}
+	if err := g.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+	if info := g.Info(); info != "offline (source ended)" {
+		t.Errorf("Info() = %q, want offline (source ended)", info)
+	}
+	if h := g.Health(); h.State != hal.HealthOffline || h.Counters["sentences"] != 30 || h.Counters["parseErrors"] != 0 {
+		t.Errorf("Health() = %+v, want offline after 30 sentences", h)
+	}
+}
+
+func TestReplaySourcePacing(t *testing.T) {
+	start := time.Now()
+	_, fixes := replay(t, SourceConfig{Speed: 20})
+
+	// Four one second gaps at 20 times speed
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("replay took %s, want at least 200ms", elapsed)
+	}
+	if len(fixes) != 5 {
+		t.Errorf("published %d fixes, want 5", len(fixes))
+	}
+}
+
+func TestReplaySourceLoop(t *testing.T) {
+	source, err := NewSource(SourceConfig{Type: SourceReplay, Path: rideFixture, Loop: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGPSWithSource(source)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fixes := g.Subscribe(ctx)
+	if err := g.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	// The time jumps back at every restart of the file, each pass still publishes
+	for i := 0; i < 12; i++ {
+		select {
+		case <-fixes:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("looping replay stopped after %d fixes", i)
+		}
+	}
+	if info := g.Info(); info == "offline (source ended)" {
+		t.Error("looping replay ended")
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SourceConfig
+		want    string
+		wantErr bool
+	}{
+		{"serial by default", SourceConfig{Port: "/dev/ttyAMA0", BaudRate: 9600}, "serial /dev/ttyAMA0@9600", false},
+		{"replay", SourceConfig{Type: SourceReplay, Path: rideFixture, Speed: 2}, "replay testdata/ride.nmea (x2)", false},
+		{"serial without port", SourceConfig{Type: SourceSerial}, "", true},
+		{"replay without path", SourceConfig{Type: SourceReplay}, "", true},
+		{"unknown type", SourceConfig{Type: "gpsd"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewSource(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && source.String() != tt.want {
+				t.Errorf("String() = %q, want %q", source.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package gps
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultSubscriberBuffer is the number of fixes buffered per subscriber
+const DefaultSubscriberBuffer = 16
+
+// broadcaster fans fixes out to subscribers without ever blocking the
+// publisher. When a subscriber's buffer is full the oldest fix is dropped.
+type broadcaster struct {
+	mu   sync.Mutex
+	subs map[chan GPSData]struct{}
+}
+
+// Subscribe returns a channel receiving every new fix until ctx is done,
+// at which point the channel is closed
+func (g *GPS) Subscribe(ctx context.Context) <-chan GPSData {
+	return g.SubscribeBuffer(ctx, DefaultSubscriberBuffer)
+}
+
+// SubscribeBuffer is Subscribe with an explicit buffer size
+func (g *GPS) SubscribeBuffer(ctx context.Context, size int) <-chan GPSData {
+	if size < 1 {
+		size = 1
+	}
+	ch := make(chan GPSData, size)
+
+	b := &g.fanout
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[chan GPSData]struct{})
+	}
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subs, ch)
+		close(ch)
+		b.mu.Unlock()
+	}()
+
+	return ch
+}
+
+// publish delivers a fix to every subscriber, dropping the oldest buffered
+// fix for subscribers that are not keeping up
+func (b *broadcaster) publish(data GPSData) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- data:
+			continue
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+}
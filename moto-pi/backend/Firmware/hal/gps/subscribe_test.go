@@ -0,0 +1,130 @@
+package gps
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// drain reads every buffered fix from ch without blocking
+func drain(ch <-chan GPSData) []float64 {
+	var got []float64
+	for {
+		select {
+		case fix, ok := <-ch:
+			if !ok {
+				return got
+			}
+			got = append(got, fix.Latitude)
+		default:
+			return got
+		}
+	}
+}
+
+// waitClosed waits until ch is closed, failing after a second
+func waitClosed(t *testing.T, ch <-chan GPSData) {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("channel not closed")
+		}
+	}
+}
+
+// subscribers counts the registered subscribers of g
+func subscribers(g *GPS) int {
+	g.fanout.mu.Lock()
+	defer g.fanout.mu.Unlock()
+	return len(g.fanout.subs)
+}
+
+func TestSubscribeDropsOldest(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		publish int
+		want    []float64
+	}{
+		{"within buffer", 4, 3, []float64{1, 2, 3}},
+		{"slow subscriber keeps the newest", 2, 5, []float64{4, 5}},
+		{"minimum buffer of one", 0, 3, []float64{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGPSWithSource(&ReaderSource{})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			ch := g.SubscribeBuffer(ctx, tt.size)
+
+			for i := 1; i <= tt.publish; i++ {
+				g.fanout.publish(GPSData{Latitude: float64(i)})
+			}
+			if got := drain(ch); !slices.Equal(got, tt.want) {
+				t.Errorf("received %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscribeMultipleConsumers(t *testing.T) {
+	g := NewGPSWithSource(&ReaderSource{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fast := g.Subscribe(ctx)
+	slow := g.SubscribeBuffer(ctx, 1)
+
+	// The fast consumer sees every fix while the slow one only keeps the
+	// latest, and publishing never waits for either
+	var got []float64
+	for i := 1; i <= 20; i++ {
+		g.fanout.publish(GPSData{Latitude: float64(i)})
+		got = append(got, drain(fast)...)
+	}
+	want := make([]float64, 20)
+	for i := range want {
+		want[i] = float64(i + 1)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("fast subscriber received %v, want %v", got, want)
+	}
+	if got := drain(slow); !slices.Equal(got, []float64{20}) {
+		t.Errorf("slow subscriber received %v, want [20]", got)
+	}
+}
+
+func TestSubscribeCancel(t *testing.T) {
+	g := NewGPSWithSource(&ReaderSource{})
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	first := g.Subscribe(ctx1)
+	second := g.Subscribe(ctx2)
+
+	cancel1()
+	waitClosed(t, first)
+	if n := subscribers(g); n != 1 {
+		t.Errorf("%d subscribers after cancel, want 1", n)
+	}
+
+	// Fixes still reach the remaining subscriber
+	g.fanout.publish(GPSData{Latitude: 1})
+	if got := drain(second); !slices.Equal(got, []float64{1}) {
+		t.Errorf("remaining subscriber received %v, want [1]", got)
+	}
+
+	cancel2()
+	waitClosed(t, second)
+	if n := subscribers(g); n != 0 {
+		t.Errorf("%d subscribers after cancel, want 0", n)
+	}
+	// Publishing without subscribers is a no-op
+	g.fanout.publish(GPSData{Latitude: 2})
+}
@@ -1,10 +1,13 @@
 package hal
 
+import "context"
+
 // Device is a generic HAL device
 type Device interface {
     Init() error
     Close() error
-    Info() string
+    Info() string   // human readable summary for logs
+    Health() Health // structured report for the API
 }
 
 // Sensor is a device that provides readings
@@ -13,6 +16,20 @@ type Sensor interface {
     Read() (map[string]any, error) // generic key/value reading
 }
 
+// ContextDevice is a device whose Init and Close honour cancellation and
+// deadlines of ctx
+type ContextDevice interface {
+    Device
+    InitContext(ctx context.Context) error
+    CloseContext(ctx context.Context) error
+}
+
+// ContextSensor is a sensor whose readings honour cancellation and deadlines of ctx
+type ContextSensor interface {
+    Sensor
+    ReadContext(ctx context.Context) (map[string]any, error)
+}
+
 // Actuator is a device that performs actions
 type Actuator interface {
     Device
@@ -0,0 +1,44 @@
+package hal
+
+import "time"
+
+// HealthState is the coarse condition of a device
+type HealthState string
+
+// Health states
+const (
+	HealthOK       HealthState = "ok"
+	HealthDegraded HealthState = "degraded" // working with reduced function, e.g. no GPS fix
+	HealthFailed   HealthState = "failed"   // stopped by an error
+	HealthOffline  HealthState = "offline"  // not started or closed
+	HealthStarting HealthState = "starting"
+	HealthDisabled HealthState = "disabled"
+)
+
+// Health is the report every device returns from Health.
+//
+//	{
+//	  "state": "degraded",
+//	  "lastSeen": "2026-10-18T12:00:39Z",
+//	  "error": "no fix",
+//	  "counters": {"sentences": 1520, "parseErrors": 3},
+//	  "metrics": {"satellites": 3, "hdop": 4.2, "validFix": false}
+//	}
+//
+// Counters only grow while the process runs. Metrics are device specific
+// snapshots.
+type Health struct {
+	State    HealthState       `json:"state"`
+	LastSeen *time.Time        `json:"lastSeen,omitempty"` // last time the device answered or produced data
+	Error    string            `json:"error,omitempty"`
+	Counters map[string]uint64 `json:"counters,omitempty"`
+	Metrics  map[string]any    `json:"metrics,omitempty"`
+}
+
+// LastSeen converts a timestamp for Health.LastSeen, nil when t is zero
+func LastSeen(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,211 @@
+package hal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Device types reported by the registry
+const (
+	TypeDevice   = "device"
+	TypeSensor   = "sensor"
+	TypeActuator = "actuator"
+)
+
+// Registry states, besides the Supervisor states
+const (
+	StateDisabled = "disabled" // turned off in the config
+	StateStopped  = "stopped"  // not started yet or closed
+)
+
+var (
+	// ErrDeviceNotFound is returned for names that were never registered
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrDuplicateDevice is returned when a name is registered twice
+	ErrDuplicateDevice = errors.New("device already registered")
+)
+
+// Config enables or disables devices by name and sets how they are
+// restarted. Devices missing from Enabled are enabled.
+type Config struct {
+	Enabled map[string]bool `json:"enabled"`
+	Restart BackoffConfig   `json:"restart"`
+}
+
+// IsEnabled reports whether the named device should be initialised
+func (c Config) IsEnabled(name string) bool {
+	enabled, ok := c.Enabled[name]
+	return !ok || enabled
+}
+
+// DeviceStatus is a snapshot of one registered device
+//
+//	{
+//	  "name": "gps",
+//	  "type": "device",
+//	  "state": "degraded",
+//	  "health": {"state": "failed", "error": "open /dev/ttyAMA0: no such file or directory", "counters": {"failures": 2, "restarts": 0}},
+//	  "lastFailure": "2026-10-18T12:00:39Z",
+//	  "nextRetry": "2026-10-18T12:00:41Z"
+//	}
+type DeviceStatus struct {
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	State       string     `json:"state"` // supervisor state
+	Health      Health     `json:"health"`
+	LastFailure *time.Time `json:"lastFailure,omitempty"`
+	NextRetry   *time.Time `json:"nextRetry,omitempty"`
+}
+
+// Registry owns the lifecycle of the HAL devices: each runs under a
+// Supervisor, they are started in registration order and closed in reverse
+type Registry struct {
+	cfg Config
+
+	mu      sync.Mutex
+	entries []*entry
+}
+
+type entry struct {
+	name    string
+	sup     *Supervisor
+	enabled bool
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry(cfg Config) (*Registry, error) {
+	if _, err := cfg.Restart.parse(); err != nil {
+		return nil, err
+	}
+	return &Registry{cfg: cfg}, nil
+}
+
+// Register adds dev under name. Its type is derived from the interfaces it implements.
+func (r *Registry) Register(name string, dev Device) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		if e.name == name {
+			return fmt.Errorf("%w: %s", ErrDuplicateDevice, name)
+		}
+	}
+
+	sup, err := NewSupervisor(name, dev, r.cfg.Restart)
+	if err != nil {
+		return err
+	}
+	r.entries = append(r.entries, &entry{name: name, sup: sup, enabled: r.cfg.IsEnabled(name)})
+	return nil
+}
+
+// Init starts every enabled device. A device failing to start is retried
+// by its supervisor and the others are still started; the failures are
+// returned joined.
+func (r *Registry) Init() error {
+	return r.InitContext(context.Background())
+}
+
+// InitContext starts every enabled device like Init, each start bounded by ctx
+func (r *Registry) InitContext(ctx context.Context) error {
+	var errs []error
+	for _, e := range r.snapshot() {
+		if !e.enabled {
+			continue
+		}
+		if err := e.sup.InitContext(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the devices in reverse registration order
+func (r *Registry) Close() error {
+	return r.CloseContext(context.Background())
+}
+
+// CloseContext closes the devices like Close. Once ctx is done the remaining
+// devices are only told to stop, without waiting for them.
+func (r *Registry) CloseContext(ctx context.Context) error {
+	entries := r.snapshot()
+	var errs []error
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := entries[i].sup.CloseContext(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entries[i].name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Get returns the named device
+func (r *Registry) Get(name string) (Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		if e.name == name {
+			return e.sup.Device(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, name)
+}
+
+// Ready reports whether the named device is healthy
+func (r *Registry) Ready(name string) bool {
+	for _, e := range r.snapshot() {
+		if e.name == name {
+			return e.sup.Status().State == StateHealthy
+		}
+	}
+	return false
+}
+
+// Status returns a snapshot of every device in registration order
+func (r *Registry) Status() []DeviceStatus {
+	entries := r.snapshot()
+	status := make([]DeviceStatus, 0, len(entries))
+	for _, e := range entries {
+		st := e.sup.Status()
+		st.Health = e.sup.Health()
+		if !e.enabled {
+			st.State = StateDisabled
+			st.Health.State = HealthDisabled
+		}
+		status = append(status, st)
+	}
+	return status
+}
+
+// snapshot copies the entry list
+func (r *Registry) snapshot() []*entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*entry{}, r.entries...)
+}
+
+// Find returns the first enabled device of type T
+func Find[T Device](r *Registry) (T, bool) {
+	for _, e := range r.snapshot() {
+		if !e.enabled {
+			continue
+		}
+		if dev, ok := e.sup.Device().(T); ok {
+			return dev, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// deviceType names the most specific HAL interface dev implements
+func deviceType(dev Device) string {
+	switch dev.(type) {
+	case Actuator:
+		return TypeActuator
+	case Sensor:
+		return TypeSensor
+	}
+	return TypeDevice
+}
@@ -0,0 +1,249 @@
+package rfid
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gps"
+)
+
+// Access results
+const (
+	ResultAuthorized = "authorized"
+	ResultDenied     = "denied"
+)
+
+// DefaultMaxEvents is the number of events kept when none is configured
+const DefaultMaxEvents = 10000
+
+// DefaultQueryLimit caps query results when no limit is given
+const DefaultQueryLimit = 100
+
+// PositionSource provides the current fix, satisfied by *gps.GPS
+type PositionSource interface {
+	Read() (gps.GPSData, error)
+}
+
+// Position is where the bike was when a tag was presented
+type Position struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lng"`
+	Estimated bool    `json:"estimated"`
+}
+
+// Event records one tag presentation
+type Event struct {
+	Time         time.Time `json:"time"`
+	Protocol     string    `json:"protocol"`
+	Type         string    `json:"type"`
+	UID          string    `json:"uid"`
+	Result       string    `json:"result"`
+	Reason       string    `json:"reason,omitempty"` // why access was denied or the unlock suppressed
+	Unlocked     bool      `json:"unlocked"`         // the unlock action was dispatched
+	Rider        string    `json:"rider,omitempty"`
+	CredentialID string    `json:"credentialId,omitempty"`
+	Position     *Position `json:"position"` // nil without a valid fix
+}
+
+// EventQuery filters the access log. Zero fields match everything.
+type EventQuery struct {
+	From   time.Time
+	To     time.Time
+	Result string
+	Limit  int // DefaultQueryLimit when 0
+}
+
+// matches reports whether ev passes the filter
+func (q EventQuery) matches(ev Event) bool {
+	switch {
+	case !q.From.IsZero() && ev.Time.Before(q.From):
+		return false
+	case !q.To.IsZero() && ev.Time.After(q.To):
+		return false
+	case q.Result != "" && ev.Result != q.Result:
+		return false
+	}
+	return true
+}
+
+// AccessLog persists tag presentations as JSON lines and streams them to subscribers
+type AccessLog struct {
+	path      string
+	maxEvents int
+
+	mu      sync.RWMutex
+	events  []Event // oldest first
+	written int     // lines in the file, compacted past twice maxEvents
+	subs    map[chan Event]struct{}
+}
+
+// OpenAccessLog loads the events kept in path. maxEvents bounds both memory
+// and file size, DefaultMaxEvents when 0.
+func OpenAccessLog(path string, maxEvents int) (*AccessLog, error) {
+	if maxEvents <= 0 {
+		maxEvents = DefaultMaxEvents
+	}
+	l := &AccessLog{path: path, maxEvents: maxEvents, subs: make(map[chan Event]struct{})}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, os.MkdirAll(filepath.Dir(path), 0o755)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var ev Event
+		// Skip a line torn by a power loss
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		l.events = append(l.events, ev)
+		l.written++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("rfid: read %s: %w", path, err)
+	}
+	if len(l.events) > maxEvents {
+		l.events = l.events[len(l.events)-maxEvents:]
+	}
+	return l, nil
+}
+
+// Record appends ev to the log and hands it to subscribers without blocking
+func (l *AccessLog) Record(ev Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events = append(l.events, ev)
+	if len(l.events) > l.maxEvents {
+		l.events = l.events[len(l.events)-l.maxEvents:]
+	}
+	for ch := range l.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+
+	if l.written >= 2*l.maxEvents {
+		return l.compactLocked()
+	}
+	return l.appendLocked(ev)
+}
+
+// Query returns matching events, newest first
+func (l *AccessLog) Query(q EventQuery) []Event {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultQueryLimit
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := []Event{}
+	for i := len(l.events) - 1; i >= 0 && len(out) < limit; i-- {
+		if q.matches(l.events[i]) {
+			out = append(out, l.events[i])
+		}
+	}
+	return out
+}
+
+// Subscribe returns a channel receiving new events until ctx is done
+func (l *AccessLog) Subscribe(ctx context.Context) <-chan Event {
+	ch := make(chan Event, 16)
+	l.mu.Lock()
+	l.subs[ch] = struct{}{}
+	l.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		l.mu.Lock()
+		delete(l.subs, ch)
+		close(ch)
+		l.mu.Unlock()
+	}()
+	return ch
+}
+
+// appendLocked writes one event line
+func (l *AccessLog) appendLocked(ev Event) error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(ev); err != nil {
+		return err
+	}
+	l.written++
+	return nil
+}
+
+// compactLocked rewrites the file with the retained events via a temp file and rename
+func (l *AccessLog) compactLocked() error {
+	tmp := l.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, ev := range l.events {
+		if err := enc.Encode(ev); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return err
+	}
+	l.written = len(l.events)
+	return nil
+}
+
+// newEvent builds the log entry for a scan of tag. suppressed is why an
+// authorized tag did not unlock, "" when it did.
+func newEvent(tag *Tag, d Decision, suppressed string, position PositionSource) Event {
+	ev := Event{
+		Time:     time.Now().UTC(),
+		Protocol: tag.Protocol,
+		Type:     tag.Type,
+		UID:      tag.UID,
+		Result:   ResultDenied,
+		Reason:   d.Reason,
+	}
+	if d.Authorized {
+		ev.Result = ResultAuthorized
+		ev.Reason = suppressed
+		ev.Unlocked = suppressed == ""
+	}
+	if d.Credential != nil {
+		ev.Rider = d.Credential.Name
+		ev.CredentialID = d.Credential.ID
+	}
+	if position != nil {
+		if fix, err := position.Read(); err == nil && fix.ValidFix {
+			ev.Position = &Position{Latitude: fix.Latitude, Longitude: fix.Longitude, Estimated: fix.Estimated}
+		}
+	}
+	return ev
+}
@@ -0,0 +1,346 @@
+package rfid
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCredentialNotFound is returned for unknown credential IDs
+	ErrCredentialNotFound = errors.New("credential not found")
+	// ErrInvalidCredential is wrapped by credential validation errors
+	ErrInvalidCredential = errors.New("invalid credential")
+)
+
+// Authorization denial reasons
+const (
+	ReasonUnknownTag  = "unknown tag"
+	ReasonRevoked     = "revoked"
+	ReasonNotYetValid = "not yet valid"
+	ReasonExpired     = "expired"
+)
+
+// Credential kinds
+const (
+	KindSignature = "signature" // tag UID, optionally with plain text in tag memory
+	KindHMAC      = "hmac"      // UID-bound HMAC payload, see SignPayload
+)
+
+// Credential is an allowed tag. A signature credential matches when every
+// non-empty field among UID and Signature matches; a Signature is a legacy
+// match, only used when the store allows it and always bound to a UID. An
+// HMAC credential matches when the tag carries a valid payload for its UID
+// and Serial.
+type Credential struct {
+	ID        string     `json:"id"`
+	Kind      string     `json:"kind"`
+	Name      string     `json:"name"`                // rider the tag belongs to
+	Protocol  string     `json:"protocol,omitempty"`  // restricts the credential to one protocol
+	UID       string     `json:"uid,omitempty"`       // tag UID as uppercase hex
+	Signature string     `json:"signature,omitempty"` // ASCII text that must appear in tag memory
+	Serial    uint32     `json:"serial,omitempty"`    // payload serial of an HMAC credential
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+	NotAfter  *time.Time `json:"notAfter,omitempty"`
+	Revoked   bool       `json:"revoked"`
+	Created   time.Time  `json:"created"`
+}
+
+// Validate checks the credential fields and normalises the UID
+func (c *Credential) Validate() error {
+	c.UID = NormalizeUID(c.UID)
+	if c.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidCredential)
+	}
+	switch c.Kind {
+	case "", KindSignature:
+		c.Kind = KindSignature
+		if c.UID == "" {
+			return fmt.Errorf("%w: uid is required", ErrInvalidCredential)
+		}
+	case KindHMAC:
+		if c.UID == "" || c.Serial == 0 {
+			return fmt.Errorf("%w: uid and serial are required", ErrInvalidCredential)
+		}
+		if c.Signature != "" {
+			return fmt.Errorf("%w: hmac credentials take no signature", ErrInvalidCredential)
+		}
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidCredential, c.Kind)
+	}
+	if _, err := hex.DecodeString(c.UID); err != nil {
+		return fmt.Errorf("%w: uid must be hex", ErrInvalidCredential)
+	}
+	if c.NotBefore != nil && c.NotAfter != nil && c.NotAfter.Before(*c.NotBefore) {
+		return fmt.Errorf("%w: notAfter is before notBefore", ErrInvalidCredential)
+	}
+	return nil
+}
+
+// matches reports whether the tag presents this credential. serial and
+// signed are the result of verifying the tag's HMAC payload, legacy allows
+// Signature matches.
+func (c *Credential) matches(tag *Tag, serial uint32, signed, legacy bool) bool {
+	if c.Protocol != "" && c.Protocol != tag.Protocol {
+		return false
+	}
+	// Every credential names its tag, an unbound one is never used
+	if c.UID == "" || c.UID != tag.UID {
+		return false
+	}
+	if c.Kind == KindHMAC {
+		return signed && c.Serial == serial
+	}
+	if c.Signature != "" && (!legacy || !bytes.Contains(tag.Memory(), []byte(c.Signature))) {
+		return false
+	}
+	return true
+}
+
+// check returns why a matching credential may not be used at now, or ""
+func (c *Credential) check(now time.Time) string {
+	switch {
+	case c.Revoked:
+		return ReasonRevoked
+	case c.NotBefore != nil && now.Before(*c.NotBefore):
+		return ReasonNotYetValid
+	case c.NotAfter != nil && now.After(*c.NotAfter):
+		return ReasonExpired
+	}
+	return ""
+}
+
+// Decision is the outcome of an authorization check
+type Decision struct {
+	Authorized bool
+	Reason     string
+	Credential *Credential
+}
+
+// NormalizeUID uppercases a hex UID and strips separators
+func NormalizeUID(uid string) string {
+	uid = strings.ToUpper(strings.TrimSpace(uid))
+	return strings.NewReplacer(" ", "", ":", "", "-", "").Replace(uid)
+}
+
+// AuthStore holds the allowed credentials, persisted as a JSON file
+type AuthStore struct {
+	path string
+
+	mu     sync.RWMutex
+	creds  []Credential
+	secret []byte // device secret verifying HMAC credentials
+	legacy bool   // Signature matches are allowed
+}
+
+// LoadAuthStore reads the credential file. A missing file yields an empty
+// store, every tag is denied until a credential is added.
+func LoadAuthStore(path string) (*AuthStore, error) {
+	s := &AuthStore{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.creds); err != nil {
+		return nil, fmt.Errorf("rfid: parse %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// SetSecret sets the device secret used to verify HMAC credentials
+func (s *AuthStore) SetSecret(secret []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secret = secret
+}
+
+// AllowLegacySignatures turns matching tags by the Signature text in their
+// memory on or off, for tags provisioned before HMAC credentials existed
+func (s *AuthStore) AllowLegacySignatures(allow bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.legacy = allow
+}
+
+// signingSecret returns the device secret
+func (s *AuthStore) signingSecret() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.secret
+}
+
+// NextSerial returns a payload serial not used by any stored credential
+func (s *AuthStore) NextSerial() uint32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var serial uint32
+	for _, c := range s.creds {
+		serial = max(serial, c.Serial)
+	}
+	return serial + 1
+}
+
+// Authorize checks a presented tag against the store
+func (s *AuthStore) Authorize(tag *Tag, now time.Time) Decision {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	serial, signed := VerifyPayload(s.secret, tag.UID, tag.Memory())
+
+	// Prefer a usable credential; otherwise report why the first match failed
+	var denied *Decision
+	for i := range s.creds {
+		c := s.creds[i]
+		if !c.matches(tag, serial, signed, s.legacy) {
+			continue
+		}
+		if reason := c.check(now); reason != "" {
+			if denied == nil {
+				denied = &Decision{Reason: reason, Credential: &c}
+			}
+			continue
+		}
+		return Decision{Authorized: true, Credential: &c}
+	}
+	if denied != nil {
+		return *denied
+	}
+	return Decision{Reason: ReasonUnknownTag}
+}
+
+// List returns every credential
+func (s *AuthStore) List() []Credential {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Credential{}, s.creds...)
+}
+
+// Get returns a credential by ID
+func (s *AuthStore) Get(id string) (Credential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if i := s.indexLocked(id); i >= 0 {
+		return s.creds[i], nil
+	}
+	return Credential{}, ErrCredentialNotFound
+}
+
+// Add validates and stores a new credential, assigning its ID
+func (s *AuthStore) Add(c Credential) (Credential, error) {
+	if err := c.Validate(); err != nil {
+		return Credential{}, err
+	}
+	id, err := newCredentialID()
+	if err != nil {
+		return Credential{}, err
+	}
+	c.ID = id
+	c.Created = time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.commitLocked(append(append([]Credential{}, s.creds...), c)); err != nil {
+		return Credential{}, err
+	}
+	return c, nil
+}
+
+// Update replaces the credential with the given ID
+func (s *AuthStore) Update(id string, c Credential) (Credential, error) {
+	if err := c.Validate(); err != nil {
+		return Credential{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.indexLocked(id)
+	if i < 0 {
+		return Credential{}, ErrCredentialNotFound
+	}
+	c.ID = id
+	c.Created = s.creds[i].Created
+	creds := append([]Credential{}, s.creds...)
+	creds[i] = c
+	if err := s.commitLocked(creds); err != nil {
+		return Credential{}, err
+	}
+	return c, nil
+}
+
+// Revoke marks a credential as revoked without deleting it
+func (s *AuthStore) Revoke(id string) (Credential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.indexLocked(id)
+	if i < 0 {
+		return Credential{}, ErrCredentialNotFound
+	}
+	creds := append([]Credential{}, s.creds...)
+	creds[i].Revoked = true
+	if err := s.commitLocked(creds); err != nil {
+		return Credential{}, err
+	}
+	return creds[i], nil
+}
+
+// Delete removes a credential
+func (s *AuthStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.indexLocked(id)
+	if i < 0 {
+		return ErrCredentialNotFound
+	}
+	creds := append(append([]Credential{}, s.creds[:i]...), s.creds[i+1:]...)
+	return s.commitLocked(creds)
+}
+
+func (s *AuthStore) indexLocked(id string) int {
+	for i := range s.creds {
+		if s.creds[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// commitLocked persists creds via a temp file and rename, then makes them current
+func (s *AuthStore) commitLocked(creds []Credential) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.creds = creds
+	return nil
+}
+
+// newCredentialID returns a random credential ID
+func newCredentialID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,89 @@
+package rfid
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestStore returns a store holding creds, verifying payloads with testSecret
+func newTestStore(t *testing.T, legacy bool, creds ...Credential) *AuthStore {
+	t.Helper()
+	store, err := LoadAuthStore(filepath.Join(t.TempDir(), "auth.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetSecret(testSecret)
+	store.AllowLegacySignatures(legacy)
+	for _, c := range creds {
+		if _, err := store.Add(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestAuthorize(t *testing.T) {
+	now := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	yesterday, tomorrow := now.Add(-24*time.Hour), now.Add(24*time.Hour)
+	creds := []Credential{
+		{Kind: KindHMAC, Name: "alice", UID: testUID, Serial: 1},
+		{Kind: KindHMAC, Name: "bob", UID: "E0040150AAAAAAAA", Serial: 2, Revoked: true},
+		{Kind: KindHMAC, Name: "carol", UID: "E0040150BBBBBBBB", Serial: 3, NotAfter: &yesterday},
+		{Kind: KindHMAC, Name: "dave", UID: "E0040150CCCCCCCC", Serial: 4, NotBefore: &tomorrow},
+		{Kind: KindSignature, Name: "erin", UID: "04A1B2C3", Signature: "motopi"},
+		{Kind: KindSignature, Name: "frank", UID: "04D4E5F6", Protocol: ProtocolISO14443A},
+	}
+	hmacTag := func(uid string, payload []byte) *Tag {
+		return &Tag{Protocol: ProtocolISO15693, UID: uid, Blocks: [][]byte{payload}}
+	}
+	alice := signed(t, testSecret, testUID, 1)
+
+	tests := []struct {
+		name     string
+		legacy   bool
+		tag      *Tag
+		wantName string // authorized rider, "" when denied
+		wantWhy  string
+	}{
+		{"valid hmac tag", false, hmacTag(testUID, alice), "alice", ""},
+		{"payload cloned onto another uid", false, hmacTag("E0040150DEADBEEF", alice), "", ReasonUnknownTag},
+		{"payload signed by another device", false, hmacTag(testUID, signed(t, otherSecret, testUID, 1)), "", ReasonUnknownTag},
+		{"serial of another credential", false, hmacTag(testUID, signed(t, testSecret, testUID, 2)), "", ReasonUnknownTag},
+		{"truncated payload", false, hmacTag(testUID, alice[:PayloadSize-4]), "", ReasonUnknownTag},
+		{"bad magic", false, hmacTag(testUID, append([]byte("XXXX"), alice[4:]...)), "", ReasonUnknownTag},
+		{"uid without payload", false, hmacTag(testUID, nil), "", ReasonUnknownTag},
+		{"revoked", false, hmacTag("E0040150AAAAAAAA", signed(t, testSecret, "E0040150AAAAAAAA", 2)), "", ReasonRevoked},
+		{"expired", false, hmacTag("E0040150BBBBBBBB", signed(t, testSecret, "E0040150BBBBBBBB", 3)), "", ReasonExpired},
+		{"not yet valid", false, hmacTag("E0040150CCCCCCCC", signed(t, testSecret, "E0040150CCCCCCCC", 4)), "", ReasonNotYetValid},
+		{"legacy signature disabled", false, &Tag{UID: "04A1B2C3", Blocks: [][]byte{[]byte("motopi")}}, "", ReasonUnknownTag},
+		{"legacy signature allowed", true, &Tag{UID: "04A1B2C3", Blocks: [][]byte{[]byte("motopi")}}, "erin", ""},
+		{"legacy signature missing", true, &Tag{UID: "04A1B2C3", Blocks: [][]byte{[]byte("other")}}, "", ReasonUnknownTag},
+		{"legacy signature on another uid", true, &Tag{UID: "04FFFFFF", Blocks: [][]byte{[]byte("motopi")}}, "", ReasonUnknownTag},
+		{"uid credential", false, &Tag{Protocol: ProtocolISO14443A, UID: "04D4E5F6"}, "frank", ""},
+		{"uid credential on another protocol", false, &Tag{Protocol: ProtocolLF, UID: "04D4E5F6"}, "", ReasonUnknownTag},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t, tt.legacy, creds...)
+			d := store.Authorize(tt.tag, now)
+			name := ""
+			if d.Authorized {
+				name = d.Credential.Name
+			}
+			if name != tt.wantName || d.Reason != tt.wantWhy {
+				t.Errorf("Authorize() = %v for %q (%s), want %q (%s)", d.Authorized, name, d.Reason, tt.wantName, tt.wantWhy)
+			}
+		})
+	}
+}
+
+func TestAuthorizeWithoutSecret(t *testing.T) {
+	store := newTestStore(t, false, Credential{Kind: KindHMAC, Name: "alice", UID: testUID, Serial: 1})
+	store.SetSecret(nil)
+	tag := &Tag{UID: testUID, Blocks: [][]byte{signed(t, testSecret, testUID, 1)}}
+	if d := store.Authorize(tag, time.Now()); d.Authorized {
+		t.Errorf("Authorize() without a device secret = %+v, want denied", d)
+	}
+}
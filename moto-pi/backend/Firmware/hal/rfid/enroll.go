@@ -0,0 +1,90 @@
+package rfid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Services/journal"
+)
+
+var (
+	// ErrEnrollmentBusy is returned while another enrollment is waiting for a tag
+	ErrEnrollmentBusy = errors.New("an enrollment is already in progress")
+	// ErrEnrollmentTimeout is returned when no tag was presented in time
+	ErrEnrollmentTimeout = errors.New("no tag presented before the enrollment timed out")
+)
+
+// enrollment is a pending request to enroll the next presented tag
+type enrollment struct {
+	name   string
+	result chan enrollResult
+}
+
+type enrollResult struct {
+	cred Credential
+	err  error
+}
+
+// Enroll waits for the next tag presented to the reader, writes an HMAC
+// credential for name to it, verifies it by reading it back and adds it to
+// the authorization store. A tag already on the reader must be taken away
+// and presented again. The tag does not unlock the bike while enrolling.
+func (r *RFIDScanner) Enroll(ctx context.Context, name string) (Credential, error) {
+	if name == "" {
+		return Credential{}, fmt.Errorf("%w: name is required", ErrInvalidCredential)
+	}
+	if r.Auth == nil {
+		return Credential{}, errors.New("rfid: no authorization store configured")
+	}
+
+	e := &enrollment{name: name, result: make(chan enrollResult, 1)}
+	r.mu.Lock()
+	if r.pending != nil {
+		r.mu.Unlock()
+		return Credential{}, ErrEnrollmentBusy
+	}
+	r.pending = e
+	r.mu.Unlock()
+
+	select {
+	case res := <-e.result:
+		return res.cred, res.err
+	case <-ctx.Done():
+		r.mu.Lock()
+		taken := r.pending != e
+		if !taken {
+			r.pending = nil
+		}
+		r.mu.Unlock()
+		if taken {
+			// The scanner picked a tag just now, report its outcome
+			res := <-e.result
+			return res.cred, res.err
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return Credential{}, ErrEnrollmentTimeout
+		}
+		return Credential{}, ctx.Err()
+	}
+}
+
+// takeEnrollment returns and clears the pending enrollment, if any
+func (r *RFIDScanner) takeEnrollment() *enrollment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.pending
+	r.pending = nil
+	return e
+}
+
+// enroll completes e with the presented tag
+func (r *RFIDScanner) enroll(ctx context.Context, e *enrollment, tag *Tag) {
+	cred, err := enrollTag(ctx, r.Reader, r.Auth, r.Auth.signingSecret(), tag, e.name)
+	if err != nil {
+		journal.Log(fmt.Sprintf("[RFID_ENROLL_FAILED] %s uid=%s error=%v", tag.Protocol, tag.UID, err))
+	} else {
+		journal.Log(fmt.Sprintf("[RFID_ENROLLED] rider=%s %s uid=%s serial=%d", cred.Name, tag.Protocol, tag.UID, cred.Serial))
+	}
+	e.result <- enrollResult{cred: cred, err: err}
+}
@@ -0,0 +1,157 @@
+package rfid
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Tag payload layout. An HMAC credential is stored at the start of the tag's
+// payload blocks as PayloadMagic, a big-endian serial and the first macSize
+// bytes of HMAC-SHA256(secret, PayloadMagic || UID || serial). Binding the UID
+// means a payload copied onto another tag no longer verifies.
+const (
+	PayloadMagic = "MPK1"
+	PayloadSize  = len(PayloadMagic) + 4 + macSize
+	secretSize   = 32
+	macSize      = 16
+)
+
+// ErrNoTag is returned when an operation needs a tag and none is in the field
+var ErrNoTag = errors.New("no tag in the field")
+
+// LoadSecret reads the device secret, generating one when the file is missing
+func LoadSecret(path string) ([]byte, error) {
+	secret, err := os.ReadFile(path)
+	if err == nil {
+		if len(secret) < secretSize {
+			return nil, fmt.Errorf("rfid: secret %s is shorter than %d bytes", path, secretSize)
+		}
+		return secret, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	secret = make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, secret, 0o600); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// SignPayload builds the tag payload binding serial to uid
+func SignPayload(secret []byte, uid string, serial uint32) ([]byte, error) {
+	raw, err := hex.DecodeString(NormalizeUID(uid))
+	if err != nil || len(raw) == 0 {
+		return nil, fmt.Errorf("%w: uid must be hex", ErrInvalidCredential)
+	}
+
+	payload := make([]byte, 0, PayloadSize)
+	payload = append(payload, PayloadMagic...)
+	payload = binary.BigEndian.AppendUint32(payload, serial)
+	payload = append(payload, payloadMAC(secret, raw, serial)...)
+	return payload, nil
+}
+
+// VerifyPayload checks a payload read from the tag with the given uid and
+// returns the serial it carries
+func VerifyPayload(secret []byte, uid string, memory []byte) (uint32, bool) {
+	if len(secret) == 0 || len(memory) < PayloadSize {
+		return 0, false
+	}
+	if !bytes.HasPrefix(memory, []byte(PayloadMagic)) {
+		return 0, false
+	}
+	raw, err := hex.DecodeString(NormalizeUID(uid))
+	if err != nil || len(raw) == 0 {
+		return 0, false
+	}
+
+	body := memory[len(PayloadMagic):]
+	serial := binary.BigEndian.Uint32(body)
+	mac := body[4 : 4+macSize]
+	if !hmac.Equal(mac, payloadMAC(secret, raw, serial)) {
+		return 0, false
+	}
+	return serial, true
+}
+
+// payloadMAC returns the truncated MAC over magic, UID and serial
+func payloadMAC(secret, uid []byte, serial uint32) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(PayloadMagic))
+	m.Write(uid)
+	binary.Write(m, binary.BigEndian, serial)
+	return m.Sum(nil)[:macSize]
+}
+
+// ProvisionTag writes a fresh HMAC credential to the tag in the field,
+// verifies it by reading it back and adds it to the store under name
+func ProvisionTag(ctx context.Context, reader *TagReader, store *AuthStore, secret []byte, name string) (Credential, error) {
+	tag, err := reader.Read(ctx)
+	if err != nil {
+		return Credential{}, err
+	}
+	if tag == nil {
+		return Credential{}, ErrNoTag
+	}
+	return enrollTag(ctx, reader, store, secret, tag, name)
+}
+
+// enrollTag writes an HMAC credential for name to tag, reads it back and
+// adds it to the store
+func enrollTag(ctx context.Context, reader *TagReader, store *AuthStore, secret []byte, tag *Tag, name string) (Credential, error) {
+	if name == "" {
+		return Credential{}, fmt.Errorf("%w: name is required", ErrInvalidCredential)
+	}
+	if len(secret) == 0 {
+		return Credential{}, errors.New("rfid: no device secret configured")
+	}
+
+	serial := store.NextSerial()
+	payload, err := SignPayload(secret, tag.UID, serial)
+	if err != nil {
+		return Credential{}, err
+	}
+	if err := reader.WritePayload(ctx, tag, payload); err != nil {
+		return Credential{}, err
+	}
+
+	written, err := reader.Read(ctx)
+	if err != nil {
+		return Credential{}, err
+	}
+	if written == nil || written.UID != tag.UID {
+		return Credential{}, errors.New("rfid: tag left the field while writing")
+	}
+	if got, ok := VerifyPayload(secret, tag.UID, written.Memory()); !ok || got != serial {
+		return Credential{}, errors.New("rfid: payload did not verify after writing")
+	}
+
+	return store.Add(Credential{
+		Kind:     KindHMAC,
+		Name:     name,
+		Protocol: tag.Protocol,
+		UID:      tag.UID,
+		Serial:   serial,
+	})
+}
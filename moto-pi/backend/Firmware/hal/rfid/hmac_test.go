@@ -0,0 +1,150 @@
+package rfid
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	testSecret  = bytes.Repeat([]byte{0x5A}, secretSize)
+	otherSecret = bytes.Repeat([]byte{0xA5}, secretSize)
+)
+
+const testUID = "E004015012345678"
+
+// signed returns the payload for uid and serial under secret
+func signed(t *testing.T, secret []byte, uid string, serial uint32) []byte {
+	t.Helper()
+	payload, err := SignPayload(secret, uid, serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func TestSignPayload(t *testing.T) {
+	payload := signed(t, testSecret, testUID, 7)
+	if len(payload) != PayloadSize || !bytes.HasPrefix(payload, []byte(PayloadMagic)) {
+		t.Errorf("payload = %X, want %d bytes starting with %s", payload, PayloadSize, PayloadMagic)
+	}
+	if again := signed(t, testSecret, "e0:04:01:50:12:34:56:78", 7); !bytes.Equal(again, payload) {
+		t.Error("payload depends on the UID notation")
+	}
+	for _, uid := range []string{"", "not hex"} {
+		if _, err := SignPayload(testSecret, uid, 1); err == nil {
+			t.Errorf("SignPayload(%q) accepted an invalid UID", uid)
+		}
+	}
+}
+
+func TestVerifyPayload(t *testing.T) {
+	valid := signed(t, testSecret, testUID, 7)
+	badMagic := append([]byte("MPK0"), valid[len(PayloadMagic):]...)
+	flipped := append([]byte{}, valid...)
+	flipped[len(flipped)-1] ^= 1
+	otherSerial := append([]byte{}, valid...)
+	otherSerial[len(PayloadMagic)+3] = 8
+
+	tests := []struct {
+		name       string
+		secret     []byte
+		uid        string
+		memory     []byte
+		wantSerial uint32
+		wantOK     bool
+	}{
+		{"valid", testSecret, testUID, valid, 7, true},
+		{"trailing block padding", testSecret, testUID, append(append([]byte{}, valid...), make([]byte, 8)...), 7, true},
+		{"uid notation", testSecret, "e0 04 01 50 12 34 56 78", valid, 7, true},
+		{"cloned onto another uid", testSecret, "E0040150DEADBEEF", valid, 0, false},
+		{"wrong secret", otherSecret, testUID, valid, 0, false},
+		{"no secret", nil, testUID, valid, 0, false},
+		{"truncated", testSecret, testUID, valid[:PayloadSize-1], 0, false},
+		{"empty memory", testSecret, testUID, nil, 0, false},
+		{"bad magic", testSecret, testUID, badMagic, 0, false},
+		{"tampered mac", testSecret, testUID, flipped, 0, false},
+		{"tampered serial", testSecret, testUID, otherSerial, 0, false},
+		{"plain text", testSecret, testUID, []byte(strings.Repeat("motopi", 4)), 0, false},
+		{"invalid uid", testSecret, "zz", valid, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serial, ok := VerifyPayload(tt.secret, tt.uid, tt.memory)
+			if serial != tt.wantSerial || ok != tt.wantOK {
+				t.Errorf("VerifyPayload() = %d, %v, want %d, %v", serial, ok, tt.wantSerial, tt.wantOK)
+			}
+		})
+	}
+}
+
+// iso15693Transcript is a blank tag with testUID that accepts written as its
+// payload and then reads back readBack
+func iso15693Transcript(written, readBack []byte) string {
+	var b strings.Builder
+	b.WriteString("[usb] pm3 --> hf 15 info\n[+]            UID: E0 04 01 50 12 34 56 78\n")
+	for _, memory := range [][]byte{make([]byte, PayloadSize), readBack} {
+		b.WriteString("[usb] pm3 --> hf 15 rdmulti -* -b 3 --cnt 6\n")
+		for i := 0; i < 6; i++ {
+			block := memory[i*4 : i*4+4]
+			fmt.Fprintf(&b, "[=]   %d | % X |     | ....\n", 3+i, block)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		fmt.Fprintf(&b, "[usb] pm3 --> hf 15 wrbl -* -b %d -d %X\n[+] Write OK\n", 3+i, written[i*4:i*4+4])
+	}
+	return b.String()
+}
+
+func TestProvisionTag(t *testing.T) {
+	blank := make([]byte, PayloadSize)
+	tests := []struct {
+		name      string
+		rider     string
+		readBack  func(payload []byte) []byte
+		wantErr   string
+		wantStore int
+	}{
+		{"writes and stores a credential", "alice", func(p []byte) []byte { return p }, "", 1},
+		{"write did not take", "alice", func([]byte) []byte { return blank }, "did not verify", 0},
+		{"rider name required", "", func(p []byte) []byte { return p }, "name is required", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := LoadAuthStore(filepath.Join(t.TempDir(), "auth.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.SetSecret(testSecret)
+
+			// The first credential gets serial 1
+			payload := signed(t, testSecret, testUID, 1)
+			client, err := ParseTranscript(strings.NewReader(iso15693Transcript(payload, tt.readBack(payload))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader := NewTagReader(client, Config{})
+
+			cred, err := ProvisionTag(context.Background(), reader, store, testSecret, tt.rider)
+			if (err == nil) != (tt.wantErr == "") || err != nil && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ProvisionTag() error = %v, want %q", err, tt.wantErr)
+			}
+			if n := len(store.List()); n != tt.wantStore {
+				t.Fatalf("store holds %d credentials, want %d", n, tt.wantStore)
+			}
+			if err != nil {
+				return
+			}
+			if cred.Kind != KindHMAC || cred.UID != testUID || cred.Serial != 1 || cred.Protocol != ProtocolISO15693 || cred.Name != tt.rider {
+				t.Errorf("credential = %+v", cred)
+			}
+			tag := &Tag{Protocol: ProtocolISO15693, UID: testUID, Blocks: [][]byte{payload}}
+			if d := store.Authorize(tag, cred.Created); !d.Authorized || d.Credential.ID != cred.ID {
+				t.Errorf("Authorize() of the provisioned tag = %+v", d)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package rfid
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// hexTablePattern matches the data column of a pm3 block dump, e.g. "|  3 | 6D 6F 74 6F | moto"
+var hexTablePattern = regexp.MustCompile(`\|\s+([0-9A-Fa-f]{2}(?:\s[0-9A-Fa-f]{2})*)\s+\|`)
+
+// uidPattern matches the UID line of "hf 15 info", e.g. "UID....: E0 04 01 50 12 34 56 78"
+var uidPattern = regexp.MustCompile(`UID\.*:?\s+((?:[0-9A-Fa-f]{2} ?){4,10})`)
+
+// parseHexTable concatenates the data bytes of a pm3 block dump
+func parseHexTable(output string) ([]byte, error) {
+	matches := hexTablePattern.FindAllStringSubmatch(output, -1)
+	if matches == nil {
+		return nil, errors.New("rfid: no block data in output")
+	}
+
+	var memory []byte
+	for _, m := range matches {
+		b, err := hex.DecodeString(strings.ReplaceAll(m[1], " ", ""))
+		if err != nil {
+			return nil, err
+		}
+		memory = append(memory, b...)
+	}
+	return memory, nil
+}
+
+// parseUID returns the normalised UID printed in output, "" when there is none
+func parseUID(output string) string {
+	m := uidPattern.FindStringSubmatch(output)
+	if m == nil {
+		return ""
+	}
+	return NormalizeUID(m[1])
+}
+
+// extractASCIISnippet returns the printable bytes around target in memory
+func extractASCIISnippet(memory []byte, target []byte, padding int) []byte {
+	idx := bytes.Index(memory, target)
+	if idx == -1 {
+		return nil
+	}
+	start := idx - padding
+	if start < 0 {
+		start = 0
+	}
+	end := idx + len(target) + padding
+	if end > len(memory) {
+		end = len(memory)
+	}
+	snippet := memory[start:end]
+	printable := make([]byte, 0, len(snippet))
+	for _, b := range snippet {
+		if b >= 32 && b <= 126 {
+			printable = append(printable, b)
+		}
+	}
+	return printable
+}
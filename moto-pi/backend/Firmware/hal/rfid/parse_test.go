@@ -0,0 +1,109 @@
+package rfid
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseHexTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:   "single block",
+			output: "[=]   4 | 6D 6F 74 6F |     | moto\n",
+			want:   []byte("moto"),
+		},
+		{
+			name: "multi-block dump",
+			output: "[=] #  | data        | lck | ascii\n" +
+				"[=] ---+-------------+-----+------\n" +
+				"[=]   3 | 6D 6F 74 6F |     | moto\n" +
+				"[=]   4 | 70 69 2D 31 |     | pi-1\n" +
+				"[=]  10 | 00 00 00 00 |     | ....\n",
+			want: []byte("motopi-1\x00\x00\x00\x00"),
+		},
+		{
+			name: "mifare block with sector header",
+			output: "[=]   # | sector 01 / 0x01                                | ascii\n" +
+				"[=] ----+-------------------------------------------------+-----------------\n" +
+				"[=]   4 | 6D 6F 74 6F 70 69 00 00 00 00 00 00 00 00 00 FF | motopi..........\n",
+			want: append([]byte("motopi"), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xFF),
+		},
+		{
+			name: "short row",
+			output: "[=]   3 | 41 42 |     | AB\n" +
+				"[=]   4 | 43 44 45 46 |     | CDEF\n",
+			want: []byte("ABCDEF"),
+		},
+		{
+			name:   "lowercase hex",
+			output: "[=]   3 | 6d 6f 74 6f |     | moto\n",
+			want:   []byte("moto"),
+		},
+		{
+			name: "non-hex rows are skipped",
+			output: "[=]   3 | ?? ?? ?? ?? |     | ....\n" +
+				"[=]   4 | 41 4G 43 44 |     | A?CD\n" +
+				"[=]   5 | 41 42 43 44 |     | ABCD\n",
+			want: []byte("ABCD"),
+		},
+		{
+			name:    "no rows",
+			output:  "[-] Fail reading block 4\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty output",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHexTable(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHexTable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("parseHexTable() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractASCIISnippet(t *testing.T) {
+	memory := []byte("xxmotopi-bike\x00\x01zz")
+
+	tests := []struct {
+		name    string
+		memory  []byte
+		target  string
+		padding int
+		want    []byte
+	}{
+		{"padding inside memory", memory, "pi", 2, []byte("topi-b")},
+		{"no padding", memory, "moto", 0, []byte("moto")},
+		{"padding clipped at start", memory, "xx", 5, []byte("xxmotop")},
+		{"padding clipped at end", memory, "zz", 3, []byte("ezz")},
+		{"target spans whole memory", []byte("moto"), "moto", 10, []byte("moto")},
+		{"non-printable bytes dropped", []byte("\x00\x7Fmoto\x1F\x80"), "moto", 2, []byte("moto")},
+		{"target missing", memory, "car", 2, nil},
+		{"empty memory", nil, "moto", 2, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractASCIISnippet(tt.memory, []byte(tt.target), tt.padding)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("extractASCIISnippet() = %q, want %q", got, tt.want)
+			}
+			if tt.want == nil && got != nil {
+				t.Errorf("extractASCIISnippet() = %q, want nil", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package rfid
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client types selectable from Config
+const (
+	ClientSession = "session"
+	ClientExec    = "exec"
+	ClientFake    = "fake"
+)
+
+// Client runs proxmark3 client commands. Run gives up once ctx is done.
+type Client interface {
+	Run(ctx context.Context, command string) (Result, error)
+	Close() error
+	String() string
+}
+
+// Result is the output of one proxmark3 command
+type Result struct {
+	Command string
+	Output  string
+}
+
+// NoTag reports whether the command found no tag in the field
+func (r Result) NoTag() bool {
+	return strings.Contains(r.Output, "no tag") ||
+		strings.Contains(r.Output, "No tag") ||
+		strings.Contains(r.Output, "No valid") ||
+		strings.Contains(r.Output, "No known") ||
+		strings.Contains(r.Output, "select failed") ||
+		strings.TrimSpace(r.Output) == ""
+}
+
+// Failed reports whether the client printed an error or warning line
+func (r Result) Failed() bool {
+	return strings.Contains(r.Output, "[!]") || strings.Contains(r.Output, "[-]")
+}
+
+// NewClient builds the Client described by cfg
+func NewClient(cfg Config) (Client, error) {
+	port := cfg.Port
+	if port == "" {
+		port = PM3Port
+	}
+
+	switch cfg.Client {
+	case "", ClientSession:
+		var timeout time.Duration
+		if cfg.CommandTimeout != "" {
+			d, err := time.ParseDuration(cfg.CommandTimeout)
+			if err != nil {
+				return nil, fmt.Errorf("rfid: invalid command timeout: %w", err)
+			}
+			timeout = d
+		}
+		return NewSessionClient(PM3Binary, port, timeout), nil
+	case ClientExec:
+		return &ExecClient{Binary: PM3Binary, Port: port}, nil
+	case ClientFake:
+		if cfg.Transcript == "" {
+			return nil, errors.New("rfid: fake client requires a transcript")
+		}
+		return LoadTranscript(cfg.Transcript)
+	default:
+		return nil, fmt.Errorf("rfid: unknown client type %q", cfg.Client)
+	}
+}
+
+// ExecClient runs each command through a fresh proxmark3 process. It is slow
+// and reopens the port every time; SessionClient is preferred.
+type ExecClient struct {
+	Binary string
+	Port   string
+}
+
+// Run executes command and returns its combined output. The process is
+// killed when ctx is done.
+func (c *ExecClient) Run(ctx context.Context, command string) (Result, error) {
+	cmd := exec.CommandContext(ctx, c.Binary, c.Port, "-c", command)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+	res := Result{Command: command, Output: out.String()}
+	if err != nil {
+		return res, fmt.Errorf("failed to run PM3 command: %w", err)
+	}
+	return res, nil
+}
+
+// Close is a no-op, every command runs in its own process
+func (c *ExecClient) Close() error {
+	return nil
+}
+
+func (c *ExecClient) String() string {
+	return fmt.Sprintf("%s %s", c.Binary, c.Port)
+}
+
+// promptPattern matches a command line of a pm3 transcript, e.g. "[usb] pm3 --> hf 15 info"
+var promptPattern = regexp.MustCompile(`^\[[\w|]+\] pm3 --> (.*)$`)
+
+// FakeClient replays captured pm3 transcripts. Each command answers with its
+// recorded outputs in order, repeating the last one once they run out.
+type FakeClient struct {
+	name string
+
+	mu      sync.Mutex
+	outputs map[string][]string
+	calls   []string
+}
+
+// LoadTranscript reads a transcript file into a FakeClient
+func LoadTranscript(path string) (*FakeClient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c, err := ParseTranscript(f)
+	if err != nil {
+		return nil, fmt.Errorf("rfid: parse %s: %w", path, err)
+	}
+	c.name = path
+	return c, nil
+}
+
+// ParseTranscript reads a transcript of "[usb] pm3 --> command" lines each
+// followed by the output the client printed
+func ParseTranscript(r io.Reader) (*FakeClient, error) {
+	c := &FakeClient{name: "transcript", outputs: make(map[string][]string)}
+
+	var command string
+	var output strings.Builder
+	flush := func() {
+		if command != "" {
+			c.outputs[command] = append(c.outputs[command], output.String())
+		}
+		output.Reset()
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := promptPattern.FindStringSubmatch(line); m != nil {
+			flush()
+			command = strings.TrimSpace(m[1])
+			continue
+		}
+		if command != "" {
+			output.WriteString(line)
+			output.WriteByte('\n')
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return c, nil
+}
+
+// Run returns the next recorded output for command
+func (c *FakeClient) Run(ctx context.Context, command string) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{Command: command}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, command)
+
+	outputs := c.outputs[command]
+	if len(outputs) == 0 {
+		return Result{Command: command}, fmt.Errorf("rfid: no transcript for %q", command)
+	}
+	out := outputs[0]
+	if len(outputs) > 1 {
+		c.outputs[command] = outputs[1:]
+	}
+	return Result{Command: command, Output: out}, nil
+}
+
+// Calls returns every command run so far
+func (c *FakeClient) Calls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.calls...)
+}
+
+// Close is a no-op
+func (c *FakeClient) Close() error {
+	return nil
+}
+
+func (c *FakeClient) String() string {
+	return "fake " + c.name
+}
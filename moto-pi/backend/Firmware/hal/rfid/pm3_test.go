@@ -0,0 +1,82 @@
+package rfid
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFakeClient(t *testing.T) {
+	client, err := ParseTranscript(strings.NewReader("[usb] pm3 --> hw version\n" +
+		"[=] Proxmark3 RFID instrument\n" +
+		"[usb] pm3 --> hf 15 info\n" +
+		"[-] No tag found.\n" +
+		"[usb] pm3 --> hf 15 info\n" +
+		"[+]            UID: E0 04 01 50 12 34 56 78\n" +
+		"[+]      TYPE: NXP (Philips); ICODE SLIX\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		command string
+		want    string
+		wantErr bool
+	}{
+		{"hw version", "[=] Proxmark3 RFID instrument\n", false},
+		{"hf 15 info", "[-] No tag found.\n", false},
+		{"hf 15 info", "[+]            UID: E0 04 01 50 12 34 56 78\n[+]      TYPE: NXP (Philips); ICODE SLIX\n", false},
+		// The last output repeats once the recorded ones run out
+		{"hf 15 info", "[+]            UID: E0 04 01 50 12 34 56 78\n[+]      TYPE: NXP (Philips); ICODE SLIX\n", false},
+		{"lf search", "", true},
+	}
+	for _, tt := range tests {
+		res, err := client.Run(context.Background(), tt.command)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Run(%q) error = %v, wantErr %v", tt.command, err, tt.wantErr)
+		}
+		if res.Command != tt.command || res.Output != tt.want {
+			t.Errorf("Run(%q) = %+v, want output %q", tt.command, res, tt.want)
+		}
+	}
+
+	want := []string{"hw version", "hf 15 info", "hf 15 info", "hf 15 info", "lf search"}
+	if calls := client.Calls(); !slices.Equal(calls, want) {
+		t.Errorf("Calls() = %q, want %q", calls, want)
+	}
+
+	// A canceled command is not run
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Run(ctx, "hw version"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() with a canceled context error = %v, want %v", err, context.Canceled)
+	}
+	if calls := client.Calls(); len(calls) != len(want) {
+		t.Errorf("Calls() = %q, the canceled command was recorded", calls)
+	}
+}
+
+func TestResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		output     string
+		wantNoTag  bool
+		wantFailed bool
+	}{
+		{"tag answered", "[+]            UID: E0 04 01 50 12 34 56 78\n", false, false},
+		{"no tag found", "[-] No tag found.\n", true, true},
+		{"no valid tag", "[!] No valid ISO15693 tag\n", true, true},
+		{"empty output", "", true, false},
+		{"write failed", "[!] Write failed\n", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Result{Output: tt.output}
+			if res.NoTag() != tt.wantNoTag || res.Failed() != tt.wantFailed {
+				t.Errorf("NoTag() = %v, Failed() = %v, want %v, %v", res.NoTag(), res.Failed(), tt.wantNoTag, tt.wantFailed)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package rfid
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Defaults for the presence and unlock timing
+const (
+	DefaultCooldown    = 5 * time.Second
+	DefaultRemoveAfter = time.Second
+	dispatchQueueSize  = 4
+)
+
+// Reasons an authorized tag did not unlock, see Event.Reason
+const (
+	SuppressedCooldown  = "cooldown"          // the previous unlock was less than the cooldown ago
+	SuppressedNoAction  = "no unlock action"  // no OnUnlock is configured
+	SuppressedQueueFull = "unlock queue full" // earlier unlock actions are still running
+)
+
+// Presence transitions reported by the tracker
+const (
+	PresenceNone    = ""
+	PresenceArrived = "arrived" // a tag entered the field
+	PresenceStill   = "present" // the same tag is still in the field
+	PresenceRemoved = "removed" // the tag left the field
+)
+
+// presence follows one tag across scans. A tag only counts as removed once
+// it has been missing for removeAfter, so a missed read does not make it
+// arrive again.
+type presence struct {
+	removeAfter time.Duration
+
+	uid      string // tag in the field, "" when none
+	lastSeen time.Time
+}
+
+// observe updates the tracker with the result of one scan and returns the
+// transition for uid ("" when no tag was read)
+func (p *presence) observe(uid string, now time.Time) string {
+	switch {
+	case uid == "" && p.uid == "":
+		return PresenceNone
+	case uid == "":
+		if now.Sub(p.lastSeen) < p.removeAfter {
+			return PresenceNone
+		}
+		p.uid = ""
+		return PresenceRemoved
+	case uid == p.uid:
+		p.lastSeen = now
+		return PresenceStill
+	}
+	// A different tag replaces the previous one without a removal in between
+	p.uid, p.lastSeen = uid, now
+	return PresenceArrived
+}
+
+// dispatcher runs unlock actions off the scan loop, one at a time
+type dispatcher struct {
+	queue chan func()
+}
+
+func newDispatcher() *dispatcher {
+	return &dispatcher{queue: make(chan func(), dispatchQueueSize)}
+}
+
+// dispatch queues fn and reports false when the queue is full
+func (d *dispatcher) dispatch(fn func()) bool {
+	select {
+	case d.queue <- fn:
+		return true
+	default:
+		return false
+	}
+}
+
+// run executes queued actions until ctx is done
+func (d *dispatcher) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case fn := <-d.queue:
+			func() {
+				defer func() {
+					if rec := recover(); rec != nil {
+						fmt.Println("RFID unlock action panicked:", rec)
+					}
+				}()
+				fn()
+			}()
+		}
+	}
+}
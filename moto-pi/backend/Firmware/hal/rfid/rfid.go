@@ -1,195 +1,410 @@
 package rfid
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"os/exec"
-	"regexp"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
-	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gpio"
+	"github.com/B64-Cryptzo/MotoPi/backend/Services/journal"
 )
 
 // Configuration constants
 const (
-	PM3Client      = "proxmark3"
+	PM3Binary      = "proxmark3"
 	PM3Port        = "/dev/ttyACM0"
-	TargetString   = "enzogenovese.com"
 	ScanInterval   = 10 * time.Millisecond
 	SnippetPadding = 10
 )
 
-// RFIDScanner implements hal.Device
+// Config holds the RFID settings loaded from the backend config
+type Config struct {
+	AuthFile         string   `json:"authFile"`         // JSON file holding the allowed credentials
+	SecretFile       string   `json:"secretFile"`       // device secret for HMAC credentials, generated when missing
+	LegacySignatures bool     `json:"legacySignatures"` // opt-in: match UID-bound signature credentials on text in tag memory
+	Client           string   `json:"client"`           // "session" (default), "exec" or "fake"
+	Port             string   `json:"port"`             // Proxmark3 serial device
+	Transcript       string   `json:"transcript"`       // captured pm3 session replayed by the fake client
+	CommandTimeout   string   `json:"commandTimeout"`   // per-command limit of the session client, e.g. "10s"
+	Protocols        []string `json:"protocols"`        // probe order, e.g. ["iso15693", "iso14443a", "lf"]
+	MifareKey        string   `json:"mifareKey"`        // MIFARE Classic key A as hex
+	EventsFile       string   `json:"eventsFile"`       // JSON lines access log of every tag presentation
+	MaxEvents        int      `json:"maxEvents"`        // access log entries kept
+	Cooldown         string   `json:"cooldown"`         // minimum time between unlocks, e.g. "5s"
+	RemoveAfter      string   `json:"removeAfter"`      // how long a tag must be gone to count as removed
+}
+
+// RFIDScanner implements hal.Device. Each tag presentation is authorized
+// once when the tag arrives; holding it to the reader does not retrigger.
 type RFIDScanner struct {
+	Auth     *AuthStore       // allowed credentials, every tag is denied when nil
+	Reader   *TagReader       // tag reader, a session on PM3Port with default protocols when nil
+	Events   *AccessLog       // optional access log of every presentation
+	Position PositionSource   // optional, stamps events with the current fix
+	OnUnlock func(Credential) // unlock action, nothing is switched when nil
+
+	cooldown   time.Duration
+	presence   presence
+	dispatch   *dispatcher
+	lastUnlock time.Time
+
+	// life serialises Init and Close
+	life       sync.Mutex
 	cancelFunc context.CancelFunc
 	wg         sync.WaitGroup
-	running    bool
+
+	mu      sync.Mutex
+	state   scanState
+	stats   func() SessionStats // latency of a session client, nil otherwise
+	pending *enrollment         // enrollment waiting for the next tag
+	err     error               // error that stopped the scan loop
+	seen    time.Time           // last successful scan
+	counts  map[string]uint64
 }
 
-// Ensure RFIDScanner implements hal.Device
-var _ hal.Device = (*RFIDScanner)(nil)
+// scanState is the state of the scan loop
+type scanState int
+
+const (
+	scanStopped    scanState = iota
+	scanConnecting           // started, waiting for the reader to answer
+	scanRunning
+	scanFailed // the loop stopped on an error
+)
+
+// Ensure RFIDScanner implements hal.ContextDevice and can be supervised
+var (
+	_ hal.ContextDevice = (*RFIDScanner)(nil)
+	_ hal.Monitored     = (*RFIDScanner)(nil)
+)
+
+// NewRFIDScanner creates a scanner with the timing from cfg. Auth, Reader,
+// Events and Position are set by the caller.
+func NewRFIDScanner(cfg Config) (*RFIDScanner, error) {
+	r := &RFIDScanner{cooldown: DefaultCooldown}
+	r.presence.removeAfter = DefaultRemoveAfter
+	if cfg.Cooldown != "" {
+		d, err := time.ParseDuration(cfg.Cooldown)
+		if err != nil {
+			return nil, fmt.Errorf("rfid: invalid cooldown: %w", err)
+		}
+		r.cooldown = d
+	}
+	if cfg.RemoveAfter != "" {
+		d, err := time.ParseDuration(cfg.RemoveAfter)
+		if err != nil {
+			return nil, fmt.Errorf("rfid: invalid removeAfter: %w", err)
+		}
+		r.presence.removeAfter = d
+	}
+	return r, nil
+}
 
 // Init starts the scanning routine
 func (r *RFIDScanner) Init() error {
-	if r.running {
+	return r.InitContext(context.Background())
+}
+
+// InitContext starts the scanning routine unless ctx is already done. The
+// routine itself runs until Close.
+func (r *RFIDScanner) InitContext(ctx context.Context) error {
+	r.life.Lock()
+	defer r.life.Unlock()
+	if r.cancelFunc != nil {
 		return nil
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if r.Reader == nil {
+		r.Reader = &TagReader{Client: NewSessionClient(PM3Binary, PM3Port, 0)}
+	}
+	if r.dispatch == nil {
+		r.dispatch = newDispatcher()
+	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	scanCtx, cancel := context.WithCancel(context.Background())
 	r.cancelFunc = cancel
-	r.running = false // set false until first successful scan
+	r.mu.Lock()
+	r.state = scanConnecting // until the first successful scan
+	r.err = nil
+	r.stats = nil
+	if s, ok := r.Reader.Client.(interface{ Stats() SessionStats }); ok {
+		r.stats = s.Stats
+	}
+	r.mu.Unlock()
 
 	r.wg.Add(1)
 	go func() {
 		defer r.wg.Done()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				if err := r.safeScanOnce(); err != nil {
-					fmt.Println("RFIDScanner encountered error:", err)
-					r.running = false
-					return
-				}
-				r.running = true // mark running if scanOnce succeeded
-				time.Sleep(ScanInterval)
-			}
-		}
+		r.dispatch.run(scanCtx)
+	}()
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.scanLoop(scanCtx)
 	}()
 
 	return nil
 }
 
-// Close stops the scanning routine
+// scanLoop scans until ctx is done or a scan fails
+func (r *RFIDScanner) scanLoop(ctx context.Context) {
+	for {
+		err := r.safeScanOnce(ctx)
+		if ctx.Err() != nil {
+			// Close interrupted the scan
+			return
+		}
+		r.scanned(err)
+
+		wait := ScanInterval
+		switch {
+		case errors.Is(err, ErrDisconnected):
+			// The session reconnects on a later command
+			r.setState(scanConnecting, nil)
+			wait = reconnectDelay
+		case err != nil:
+			fmt.Println("RFIDScanner encountered error:", err)
+			r.setState(scanFailed, err)
+			return
+		default:
+			r.setState(scanRunning, nil)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Close stops the scanning routine, also after the loop has failed
 func (r *RFIDScanner) Close() error {
-	if !r.running {
+	return r.CloseContext(context.Background())
+}
+
+// CloseContext stops the scanning routine, waiting for it until ctx is done.
+// The command in flight is abandoned.
+func (r *RFIDScanner) CloseContext(ctx context.Context) error {
+	r.life.Lock()
+	defer r.life.Unlock()
+	if r.cancelFunc == nil {
 		return nil
 	}
 	r.cancelFunc()
-	r.wg.Wait()
-	r.running = false
-	return nil
+	r.cancelFunc = nil
+
+	stopped := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(stopped)
+	}()
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		err = fmt.Errorf("rfid: scanner did not stop: %w", ctx.Err())
+	}
+	r.setState(scanStopped, nil)
+	return err
+}
+
+// setState moves the scan loop to state, keeping err for a failure
+func (r *RFIDScanner) setState(state scanState, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state = state
+	if err != nil {
+		r.err = err
+	}
 }
 
-// Info returns the scanner status
+// Err returns the error that stopped the scan loop, nil while it runs
+func (r *RFIDScanner) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Info returns the scanner status and, for a session client, its latency
 func (r *RFIDScanner) Info() string {
-	if r.running {
-		return "online"
+	r.mu.Lock()
+	state, stats := r.state, r.stats
+	r.mu.Unlock()
+
+	if state != scanRunning {
+		return "offline"
+	}
+	if stats != nil {
+		st := stats()
+		return fmt.Sprintf("online (latency avg %s, last %s, max %s, %d reconnects)",
+			st.AvgLatency.Round(time.Millisecond), st.LastLatency.Round(time.Millisecond),
+			st.MaxLatency.Round(time.Millisecond), st.Reconnects)
+	}
+	return "online"
+}
+
+// Health reports the scan loop and, for a session client, its latency
+func (r *RFIDScanner) Health() hal.Health {
+	r.mu.Lock()
+	h := hal.Health{
+		State:    hal.HealthOK,
+		LastSeen: hal.LastSeen(r.seen),
+		Counters: make(map[string]uint64, len(r.counts)),
+	}
+	for k, v := range r.counts {
+		h.Counters[k] = v
+	}
+	state, err, stats := r.state, r.err, r.stats
+	r.mu.Unlock()
+
+	switch state {
+	case scanFailed:
+		h.State, h.Error = hal.HealthFailed, err.Error()
+	case scanStopped:
+		h.State = hal.HealthOffline
+	case scanConnecting:
+		h.State, h.Error = hal.HealthDegraded, ErrDisconnected.Error()
+	}
+	if stats != nil {
+		st := stats()
+		h.Counters["commands"] = st.Commands
+		h.Counters["commandFailures"] = st.Failures
+		h.Counters["reconnects"] = st.Reconnects
+		h.Metrics = map[string]any{
+			"connected":     st.Connected,
+			"queueDepth":    st.QueueDepth,
+			"lastLatencyMs": st.LastLatency.Milliseconds(),
+			"avgLatencyMs":  st.AvgLatency.Milliseconds(),
+			"maxLatencyMs":  st.MaxLatency.Milliseconds(),
+		}
+	}
+	return h
+}
+
+// scanned counts one scan and remembers when the reader last answered
+func (r *RFIDScanner) scanned(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.countLocked("scanErrors")
+		return
 	}
-	return "offline"
+	r.countLocked("scans")
+	r.seen = time.Now()
 }
 
-// journalLog logs a message with the gimo-events tag
-func journalLog(msg string) {
-	cmd := exec.Command("logger", "-t", "gimo-events", msg)
-	if err := cmd.Run(); err != nil {
-		fmt.Println("Failed to write to journal:", err)
+// count increments a health counter
+func (r *RFIDScanner) count(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.countLocked(name)
+}
+
+func (r *RFIDScanner) countLocked(name string) {
+	if r.counts == nil {
+		r.counts = make(map[string]uint64)
 	}
+	r.counts[name]++
 }
 
 // safeScanOnce wraps scanOnce and returns any error encountered
-func (r *RFIDScanner) safeScanOnce() (err error) {
+func (r *RFIDScanner) safeScanOnce(ctx context.Context) (err error) {
 	defer func() {
 		if rec := recover(); rec != nil {
 			err = fmt.Errorf("panic in scanOnce: %v", rec)
 		}
 	}()
-	err = r.scanOnce()
+	err = r.scanOnce(ctx)
 	return err
 }
 
 // scanOnce performs a single UID/memory check
-func (r *RFIDScanner) scanOnce() error {
-	mem, err := readTagMemory()
+func (r *RFIDScanner) scanOnce(ctx context.Context) error {
+	tag, err := r.Reader.Read(ctx)
 	if err != nil {
 		return err
 	}
 
-	if mem == nil {
-		return nil
+	uid := ""
+	if tag != nil {
+		uid = tag.UID
 	}
-
-	snippet := extractASCIISnippet(mem, []byte(TargetString), SnippetPadding)
-	if snippet == nil {
-		return nil
+	if r.presence.observe(uid, time.Now()) == PresenceArrived {
+		r.count("tags")
+		r.arrived(ctx, tag)
 	}
-
-	validRFIDTag := strings.Contains(string(snippet), "enzogenovese.com")
-	if validRFIDTag {
-		journalLog("[FOUND_VALID_RFID]")
-		gpio.MomentarySwitch()
-	}
-
 	return nil
 }
 
-func readTagMemory() ([]byte, error) {
-	cmd := exec.Command(PM3Client, PM3Port, "-c", "hf 15 rdmulti -* -b 3 --cnt 6")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-
-	err := cmd.Run()
-	output := out.String()
+// arrived authorizes a newly presented tag and dispatches the unlock action
+func (r *RFIDScanner) arrived(ctx context.Context, tag *Tag) {
+	if e := r.takeEnrollment(); e != nil {
+		r.enroll(ctx, e, tag)
+		return
+	}
 
-	// If proxmark3 runs but no tag is found, treat as "no data" not an error
-	if err != nil {
-		// If the error is only because no tag was detected, suppress it
-		if strings.Contains(output, "no tag") ||
-			strings.Contains(output, "No valid") ||
-			strings.TrimSpace(output) == "" {
-			return nil, nil
+	decision := Decision{Reason: ReasonUnknownTag}
+	if r.Auth != nil {
+		decision = r.Auth.Authorize(tag, time.Now().UTC())
+	}
+	suppressed := ""
+	if decision.Authorized {
+		r.count("authorized")
+		suppressed = r.unlock(*decision.Credential)
+	} else {
+		r.count("denied")
+	}
+	if r.Events != nil {
+		if err := r.Events.Record(newEvent(tag, decision, suppressed, r.Position)); err != nil {
+			fmt.Println("RFID access log error:", err)
 		}
-		// Otherwise, it's a real error
-		return nil, fmt.Errorf("failed to run PM3 command: %w", err)
-	}
-
-	re := regexp.MustCompile(`\|\s+([0-9A-Fa-f]{2}(?:\s[0-9A-Fa-f]{2})*)\s+\|`)
-	matches := re.FindAllStringSubmatch(output, -1)
-	if matches == nil {
-		return nil, nil
-	}
-
-	var memory []byte
-	for _, m := range matches {
-		bytesStr := strings.Split(m[1], " ")
-		for _, bStr := range bytesStr {
-			var b byte
-			if _, err := fmt.Sscanf(bStr, "%02X", &b); err != nil {
-				// Ignore parse errors gracefully
-				return nil, nil
-			}
-			memory = append(memory, b)
+	}
+
+	if !decision.Authorized {
+		if decision.Reason != ReasonUnknownTag {
+			journal.Log(fmt.Sprintf("[DENIED_RFID] %s uid=%s reason=%s", tag.Protocol, tag.UID, decision.Reason))
 		}
+		return
 	}
 
-	return memory, nil
+	msg := fmt.Sprintf("[FOUND_VALID_RFID] rider=%s %s uid=%s", decision.Credential.Name, tag.Protocol, tag.UID)
+	if sig := decision.Credential.Signature; sig != "" {
+		msg += fmt.Sprintf(" memory=%q", extractASCIISnippet(tag.Memory(), []byte(sig), SnippetPadding))
+	}
+	if decision.Credential.Kind == KindHMAC {
+		msg += fmt.Sprintf(" serial=%d", decision.Credential.Serial)
+	}
+	if suppressed != "" {
+		msg += fmt.Sprintf(" suppressed=%q", suppressed)
+	}
+	journal.Log(msg)
 }
 
-func extractASCIISnippet(memory []byte, target []byte, padding int) []byte {
-	idx := bytes.Index(memory, target)
-	if idx == -1 {
-		return nil
-	}
-	start := idx - padding
-	if start < 0 {
-		start = 0
+// unlock dispatches the unlock action for an authorized credential and
+// returns why it was suppressed, "" when it was dispatched
+func (r *RFIDScanner) unlock(cred Credential) string {
+	now := time.Now()
+	if !r.lastUnlock.IsZero() && now.Sub(r.lastUnlock) < r.cooldown {
+		return SuppressedCooldown
 	}
-	end := idx + len(target) + padding
-	if end > len(memory) {
-		end = len(memory)
+
+	unlock := r.OnUnlock
+	if unlock == nil {
+		fmt.Println("RFIDScanner has no unlock action configured")
+		return SuppressedNoAction
 	}
-	snippet := memory[start:end]
-	printable := make([]byte, 0, len(snippet))
-	for _, b := range snippet {
-		if b >= 32 && b <= 126 {
-			printable = append(printable, b)
-		}
+	if !r.dispatch.dispatch(func() { unlock(cred) }) {
+		fmt.Println("RFIDScanner unlock queue full, dropping unlock for", cred.Name)
+		return SuppressedQueueFull
 	}
-	return printable
+	r.lastUnlock = now
+	r.count("unlocks")
+	return ""
 }
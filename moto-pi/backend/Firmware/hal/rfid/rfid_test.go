@@ -0,0 +1,312 @@
+package rfid
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
+)
+
+// waitInfo polls the scanner until Info reports want
+func waitInfo(t *testing.T, r *RFIDScanner, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for r.Info() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Info() = %q, want %q", r.Info(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScannerConcurrentLifecycle(t *testing.T) {
+	client, err := ParseTranscript(strings.NewReader(noTag15693 + noTag14a +
+		"[usb] pm3 --> lf search\n" +
+		"[+] EM 410x ID 0F0368568B\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRFIDScanner(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Reader = NewTagReader(client, Config{})
+
+	// Restart the scanner a few times while every entry point races it
+	for cycle := 0; cycle < 3; cycle++ {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := r.InitContext(context.Background()); err != nil {
+					t.Errorf("InitContext() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		waitInfo(t, r, "online")
+
+		stop := make(chan struct{})
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					_ = r.Info()
+					_ = r.Health()
+					_ = r.Err()
+					_ = r.Init()
+				}
+			}()
+		}
+		time.Sleep(30 * time.Millisecond)
+		var closers sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			closers.Add(1)
+			go func() {
+				defer closers.Done()
+				if err := r.Close(); err != nil {
+					t.Errorf("Close() error = %v", err)
+				}
+			}()
+		}
+		closers.Wait()
+		close(stop)
+		wg.Wait()
+		// A reader's Init may have won the race with Close
+		if err := r.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+
+		if info := r.Info(); info != "offline" {
+			t.Errorf("cycle %d: Info() after Close = %q, want offline", cycle, info)
+		}
+		if h := r.Health(); h.State != hal.HealthOffline || h.Counters["scans"] == 0 {
+			t.Errorf("cycle %d: Health() after Close = %+v, want offline with scans counted", cycle, h)
+		}
+	}
+
+	if h := r.Health(); h.Counters["tags"] != 1 || h.Counters["scanErrors"] != 0 {
+		t.Errorf("Health() counters = %v, want one tag and no scan errors", h.Counters)
+	}
+	if len(client.Calls()) == 0 {
+		t.Error("the scanner never ran a command")
+	}
+}
+
+func TestScannerInitCanceled(t *testing.T) {
+	r, err := NewRFIDScanner(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.InitContext(ctx); err == nil {
+		t.Fatal("InitContext() started with a canceled context")
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("Close() without Init error = %v", err)
+	}
+	if h := r.Health(); h.State != hal.HealthOffline {
+		t.Errorf("Health() = %+v, want offline", h)
+	}
+}
+
+// waitHealth polls the scanner until Health reports state
+func waitHealth(t *testing.T, r *RFIDScanner, state hal.HealthState) hal.Health {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		h := r.Health()
+		if h.State == state {
+			return h
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Health() = %+v, want %s", h, state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// fakeProxmark writes a script standing in for the proxmark3 client. It finds
+// no tag, records its pid and refuses to start while dir/unplugged exists.
+func fakeProxmark(t *testing.T) (binary, dir string) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell to run the fake proxmark3 client")
+	}
+	dir = t.TempDir()
+	binary = filepath.Join(dir, "proxmark3")
+	script := fmt.Sprintf(`#!/bin/sh
+[ -e %[1]q/unplugged ] && exit 1
+echo $$ > %[1]q/pid
+echo "[=] fake proxmark3"
+while IFS= read -r line; do
+	case "$line" in
+	rem*) echo "[usb] pm3 --> $line" ;;
+	"") ;;
+	*) echo "[-] No tag found." ;;
+	esac
+done
+`, dir)
+	if err := os.WriteFile(binary, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return binary, dir
+}
+
+func TestScannerSessionDisconnect(t *testing.T) {
+	binary, dir := fakeProxmark(t)
+	client := NewSessionClient(binary, "/dev/fake", time.Second)
+	defer client.Close()
+
+	r, err := NewRFIDScanner(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Reader = NewTagReader(client, Config{})
+	if err := r.InitContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	waitHealth(t, r, hal.HealthOK)
+
+	// Unplug the reader: the client dies and cannot be restarted
+	if err := os.WriteFile(filepath.Join(dir, "unplugged"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "pid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+
+	h := waitHealth(t, r, hal.HealthDegraded)
+	if h.Error != ErrDisconnected.Error() || h.Metrics["connected"] != false || h.Counters["scanErrors"] == 0 {
+		t.Errorf("Health() after unplugging = %+v, want disconnected with scan errors", h)
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("Err() = %v, a disconnect must not stop the scan loop", err)
+	}
+
+	// Plugging it back in reconnects on a later scan
+	if err := os.Remove(filepath.Join(dir, "unplugged")); err != nil {
+		t.Fatal(err)
+	}
+	waitHealth(t, r, hal.HealthOK)
+	if st := client.Stats(); !st.Connected || st.Reconnects == 0 {
+		t.Errorf("Stats() = %+v, want reconnected", st)
+	}
+}
+
+func TestScannerUnlockOutcome(t *testing.T) {
+	alice := &Tag{Protocol: ProtocolISO15693, UID: testUID, Blocks: [][]byte{signed(t, testSecret, testUID, 1)}}
+	stranger := &Tag{Protocol: ProtocolISO15693, UID: "E0040150DEADBEEF"}
+
+	type outcome struct {
+		Result   string
+		Reason   string
+		Unlocked bool
+	}
+	tests := []struct {
+		name     string
+		noAction bool
+		queue    int // unlock actions already waiting
+		tags     []*Tag
+		want     []outcome
+	}{
+		{
+			name: "unlock then cooldown",
+			tags: []*Tag{alice, alice},
+			want: []outcome{
+				{ResultAuthorized, "", true},
+				{ResultAuthorized, SuppressedCooldown, false},
+			},
+		},
+		{
+			name: "denied tag does not unlock",
+			tags: []*Tag{stranger},
+			want: []outcome{{ResultDenied, ReasonUnknownTag, false}},
+		},
+		{
+			name:     "no unlock action",
+			noAction: true,
+			tags:     []*Tag{alice, alice},
+			want: []outcome{
+				{ResultAuthorized, SuppressedNoAction, false},
+				{ResultAuthorized, SuppressedNoAction, false},
+			},
+		},
+		{
+			name:  "unlock queue full",
+			queue: dispatchQueueSize,
+			tags:  []*Tag{alice},
+			want:  []outcome{{ResultAuthorized, SuppressedQueueFull, false}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := OpenAccessLog(filepath.Join(t.TempDir(), "events.jsonl"), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := NewRFIDScanner(Config{Cooldown: "1h"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Auth = newTestStore(t, false, Credential{Kind: KindHMAC, Name: "alice", UID: testUID, Serial: 1})
+			r.Events = events
+			r.dispatch = newDispatcher()
+			if !tt.noAction {
+				r.OnUnlock = func(Credential) {}
+			}
+			for i := 0; i < tt.queue; i++ {
+				r.dispatch.dispatch(func() {})
+			}
+
+			// The dispatcher is not running, every dispatched unlock stays queued
+			for _, tag := range tt.tags {
+				r.arrived(context.Background(), tag)
+			}
+			var got []outcome
+			for _, ev := range events.Query(EventQuery{}) {
+				got = append([]outcome{{ev.Result, ev.Reason, ev.Unlocked}}, got...)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("events = %+v, want %+v", got, tt.want)
+			}
+			unlocks := 0
+			for _, o := range tt.want {
+				if o.Unlocked {
+					unlocks++
+				}
+			}
+			if n := len(r.dispatch.queue) - tt.queue; n != unlocks || r.Health().Counters["unlocks"] != uint64(unlocks) {
+				t.Errorf("%d unlocks dispatched, %d counted, want %d", n, r.Health().Counters["unlocks"], unlocks)
+			}
+		})
+	}
+}
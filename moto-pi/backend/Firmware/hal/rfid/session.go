@@ -0,0 +1,342 @@
+package rfid
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCommandTimeout bounds how long a session command may run
+const DefaultCommandTimeout = 10 * time.Second
+
+const (
+	// markerPrefix tags the "rem" lines that delimit command output
+	markerPrefix = "motopi-sync-"
+	// reconnectDelay is the minimum time between attempts to restart the client
+	reconnectDelay = time.Second
+)
+
+var (
+	// ErrDisconnected is returned while the Proxmark3 is unreachable
+	ErrDisconnected = errors.New("proxmark3 disconnected")
+	// ErrCommandTimeout is returned when a command produced no end marker in time
+	ErrCommandTimeout = errors.New("proxmark3 command timed out")
+	// ErrSessionClosed is returned by Run after Close
+	ErrSessionClosed = errors.New("proxmark3 session closed")
+)
+
+// disconnectMarkers are client messages meaning the device went away
+var disconnectMarkers = []string{
+	"[offline",
+	"cannot communicate",
+	"Communicating with Proxmark3 device failed",
+	"Not connected",
+}
+
+// SessionStats reports the health and latency of a SessionClient
+type SessionStats struct {
+	Connected   bool          `json:"connected"`
+	Commands    uint64        `json:"commands"`
+	Failures    uint64        `json:"failures"`
+	Reconnects  uint64        `json:"reconnects"`
+	QueueDepth  int           `json:"queueDepth"`
+	LastLatency time.Duration `json:"lastLatency"`
+	AvgLatency  time.Duration `json:"avgLatency"` // exponentially weighted
+	MaxLatency  time.Duration `json:"maxLatency"`
+}
+
+// SessionClient keeps one interactive proxmark3 client open and feeds it
+// commands over stdin. Every command is followed by "rem <marker>" so the end
+// of its output can be found in the stream. Commands queue up and run one at
+// a time; a dead or unplugged device is restarted on the next command.
+type SessionClient struct {
+	Binary  string
+	Port    string
+	Timeout time.Duration
+
+	requests  chan sessionRequest
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+
+	mu    sync.Mutex
+	stats SessionStats
+
+	// owned by the worker goroutine
+	proc    *exec.Cmd
+	stdin   io.WriteCloser
+	lines   chan string
+	seq     uint64
+	started bool
+	retryAt time.Time
+}
+
+type sessionRequest struct {
+	ctx     context.Context
+	command string
+	reply   chan sessionReply
+}
+
+type sessionReply struct {
+	res Result
+	err error
+}
+
+// NewSessionClient starts the command worker. The proxmark3 process itself is
+// started by the first command.
+func NewSessionClient(binary, port string, timeout time.Duration) *SessionClient {
+	if timeout <= 0 {
+		timeout = DefaultCommandTimeout
+	}
+	c := &SessionClient{
+		Binary:   binary,
+		Port:     port,
+		Timeout:  timeout,
+		requests: make(chan sessionRequest),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go c.worker()
+	return c
+}
+
+// Run queues command and waits for its output until ctx is done. A command
+// abandoned while running restarts the client, its output would otherwise
+// end up in the next command's.
+func (c *SessionClient) Run(ctx context.Context, command string) (Result, error) {
+	c.mu.Lock()
+	c.stats.QueueDepth++
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.stats.QueueDepth--
+		c.mu.Unlock()
+	}()
+
+	req := sessionRequest{ctx: ctx, command: command, reply: make(chan sessionReply, 1)}
+	select {
+	case c.requests <- req:
+	case <-c.done:
+		return Result{Command: command}, ErrSessionClosed
+	case <-ctx.Done():
+		return Result{Command: command}, ctx.Err()
+	}
+	select {
+	case r := <-req.reply:
+		return r.res, r.err
+	case <-c.stopped:
+		return Result{Command: command}, ErrSessionClosed
+	}
+}
+
+// Close stops the worker and the proxmark3 process
+func (c *SessionClient) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		<-c.stopped
+	})
+	return nil
+}
+
+// Stats returns a snapshot of the session metrics
+func (c *SessionClient) Stats() SessionStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+func (c *SessionClient) String() string {
+	return fmt.Sprintf("%s %s (session)", c.Binary, c.Port)
+}
+
+// worker runs queued commands until Close
+func (c *SessionClient) worker() {
+	defer close(c.stopped)
+	defer c.stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case req := <-c.requests:
+			start := time.Now()
+			res, err := c.execute(req.ctx, req.command)
+			c.record(time.Since(start), err)
+			req.reply <- sessionReply{res: res, err: err}
+		}
+	}
+}
+
+// execute sends one command, (re)starting the client when needed
+func (c *SessionClient) execute(ctx context.Context, command string) (Result, error) {
+	res := Result{Command: command}
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+	if c.proc == nil {
+		if time.Now().Before(c.retryAt) {
+			return res, ErrDisconnected
+		}
+		if err := c.start(ctx); err != nil {
+			c.retryAt = time.Now().Add(reconnectDelay)
+			return res, fmt.Errorf("%w: %v", ErrDisconnected, err)
+		}
+	}
+
+	out, lost, err := c.exchange(ctx, command)
+	res.Output = out
+	if err != nil || lost {
+		c.stop()
+		if err == nil {
+			err = ErrDisconnected
+		}
+	}
+	return res, err
+}
+
+// exchange writes command and a marker, then collects output up to the
+// marker. Giving up early, on a timeout or ctx, loses the session.
+func (c *SessionClient) exchange(ctx context.Context, command string) (string, bool, error) {
+	c.seq++
+	seq := c.seq
+	if _, err := fmt.Fprintf(c.stdin, "%s\nrem %s%d\n", command, markerPrefix, seq); err != nil {
+		return "", true, fmt.Errorf("%w: %v", ErrDisconnected, err)
+	}
+
+	timer := time.NewTimer(c.Timeout)
+	defer timer.Stop()
+
+	var out strings.Builder
+	lost := false
+	for {
+		select {
+		case line, ok := <-c.lines:
+			if !ok {
+				return out.String(), true, ErrDisconnected
+			}
+			for _, m := range disconnectMarkers {
+				if strings.Contains(line, m) {
+					lost = true
+				}
+			}
+			if n, ok := markerSeq(line); ok {
+				// Markers of earlier commands (prompt echo and remark) are stale
+				if n == seq {
+					return out.String(), lost, nil
+				}
+				continue
+			}
+			if promptPattern.MatchString(line) {
+				continue
+			}
+			out.WriteString(line)
+			out.WriteByte('\n')
+		case <-timer.C:
+			return out.String(), true, ErrCommandTimeout
+		case <-ctx.Done():
+			return out.String(), true, ctx.Err()
+		}
+	}
+}
+
+// start launches the client and waits until it answers a marker or ctx is
+// done. The process outlives ctx, it runs until stop.
+func (c *SessionClient) start(ctx context.Context) error {
+	proc := exec.Command(c.Binary, "-f", c.Port)
+	stdin, err := proc.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := proc.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	proc.Stderr = proc.Stdout
+	if err := proc.Start(); err != nil {
+		return err
+	}
+
+	lines := make(chan string, 64)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	c.proc, c.stdin, c.lines = proc, stdin, lines
+	// Discard the banner and make sure the device is connected
+	if _, lost, err := c.exchange(ctx, ""); err != nil || lost {
+		c.stop()
+		if err == nil {
+			err = errors.New("device not connected")
+		}
+		return err
+	}
+
+	c.mu.Lock()
+	c.stats.Connected = true
+	if c.started {
+		c.stats.Reconnects++
+	}
+	c.mu.Unlock()
+	c.started = true
+	return nil
+}
+
+// stop kills the client process
+func (c *SessionClient) stop() {
+	if c.proc == nil {
+		return
+	}
+	c.stdin.Close()
+	c.proc.Process.Kill()
+	c.proc.Wait()
+	for range c.lines {
+	}
+	c.proc, c.stdin, c.lines = nil, nil, nil
+
+	c.mu.Lock()
+	c.stats.Connected = false
+	c.mu.Unlock()
+}
+
+// record updates the command metrics
+func (c *SessionClient) record(latency time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Commands++
+	if err != nil {
+		c.stats.Failures++
+		return
+	}
+	c.stats.LastLatency = latency
+	c.stats.MaxLatency = max(c.stats.MaxLatency, latency)
+	if c.stats.AvgLatency == 0 {
+		c.stats.AvgLatency = latency
+	} else {
+		c.stats.AvgLatency += (latency - c.stats.AvgLatency) / 5
+	}
+}
+
+// markerSeq returns the sequence number of a marker line
+func markerSeq(line string) (uint64, bool) {
+	i := strings.Index(line, markerPrefix)
+	if i < 0 {
+		return 0, false
+	}
+	digits := line[i+len(markerPrefix):]
+	end := strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' })
+	if end >= 0 {
+		digits = digits[:end]
+	}
+	n, err := strconv.ParseUint(digits, 10, 64)
+	return n, err == nil
+}
@@ -0,0 +1,318 @@
+package rfid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Protocols the reader can probe for
+const (
+	ProtocolISO14443A = "iso14443a"
+	ProtocolISO15693  = "iso15693"
+	ProtocolLF        = "lf" // 125 kHz proximity tags
+)
+
+// DefaultProtocols is the probe order used when none is configured. LF comes
+// last because "lf search" is by far the slowest probe.
+var DefaultProtocols = []string{ProtocolISO15693, ProtocolISO14443A, ProtocolLF}
+
+// DefaultMifareKey is the factory key A used to read MIFARE Classic blocks
+const DefaultMifareKey = "FFFFFFFFFFFF"
+
+// Tag types reported in Tag.Type
+const (
+	TypeISO15693        = "ISO15693"
+	TypeMifareClassic1K = "MIFARE Classic 1K"
+	TypeMifareClassic4K = "MIFARE Classic 4K"
+	TypeMifareMini      = "MIFARE Mini"
+	TypeUltralight      = "MIFARE Ultralight"
+	TypeNTAG            = "NTAG"
+	TypeISO14443A       = "ISO14443A"
+	TypeEM410x          = "EM410x"
+	TypeHIDProx         = "HID Prox"
+)
+
+// ErrUnsupportedTag is returned when a tag's memory cannot be written
+var ErrUnsupportedTag = errors.New("tag type does not support payloads")
+
+// Tag is a tag read through the Proxmark
+type Tag struct {
+	Protocol string   `json:"protocol"`
+	Type     string   `json:"type"`
+	UID      string   `json:"uid"`
+	ATQA     string   `json:"atqa,omitempty"` // ISO14443A only
+	SAK      string   `json:"sak,omitempty"`  // ISO14443A only
+	Blocks   [][]byte `json:"blocks,omitempty"`
+}
+
+// Memory returns the payload blocks concatenated
+func (t *Tag) Memory() []byte {
+	var memory []byte
+	for _, b := range t.Blocks {
+		memory = append(memory, b...)
+	}
+	return memory
+}
+
+// layout describes where a tag type keeps the payload and how to reach it
+type layout struct {
+	first, count, size int
+	read, write        string // pm3 commands taking the block number (and data)
+	keyed              bool   // commands take the MIFARE key after the block number
+}
+
+// layouts holds the payload location per writable tag type. The first user
+// blocks are used on every type so a payload always starts the memory.
+var layouts = map[string]layout{
+	TypeISO15693:        {first: 3, count: 6, size: 4, write: "hf 15 wrbl -* -b %d -d %X"},
+	TypeUltralight:      {first: 4, count: 6, size: 4, read: "hf mfu rdbl -b %d", write: "hf mfu wrbl -b %d -d %X"},
+	TypeNTAG:            {first: 4, count: 6, size: 4, read: "hf mfu rdbl -b %d", write: "hf mfu wrbl -b %d -d %X"},
+	TypeMifareClassic1K: {first: 4, count: 2, size: 16, read: "hf mf rdbl --blk %d -k %s", write: "hf mf wrbl --blk %d -k %s -d %X", keyed: true},
+	TypeMifareClassic4K: {first: 4, count: 2, size: 16, read: "hf mf rdbl --blk %d -k %s", write: "hf mf wrbl --blk %d -k %s -d %X", keyed: true},
+	TypeMifareMini:      {first: 4, count: 2, size: 16, read: "hf mf rdbl --blk %d -k %s", write: "hf mf wrbl --blk %d -k %s -d %X", keyed: true},
+}
+
+var (
+	atqaPattern   = regexp.MustCompile(`ATQA\.*:?\s+([0-9A-Fa-f]{2} ?[0-9A-Fa-f]{2})`)
+	sakPattern    = regexp.MustCompile(`SAK\.*:?\s+([0-9A-Fa-f]{2})`)
+	ntagPattern   = regexp.MustCompile(`NTAG ?2\d\d`)
+	em410xPattern = regexp.MustCompile(`EM 410x ID ([0-9A-Fa-f]+)`)
+	lfRawPattern  = regexp.MustCompile(`(?i)raw:\s*([0-9a-f]+)`)
+)
+
+// TagReader detects and reads tags through a Proxmark3 client
+type TagReader struct {
+	Client    Client
+	Protocols []string // probe order, DefaultProtocols when empty
+	MifareKey string   // key A for MIFARE Classic, DefaultMifareKey when empty
+}
+
+// NewTagReader builds a reader from the RFID config
+func NewTagReader(client Client, cfg Config) *TagReader {
+	return &TagReader{Client: client, Protocols: cfg.Protocols, MifareKey: cfg.MifareKey}
+}
+
+// Read probes each protocol in turn and returns the first tag found, or nil
+func (r *TagReader) Read(ctx context.Context) (*Tag, error) {
+	protocols := r.Protocols
+	if len(protocols) == 0 {
+		protocols = DefaultProtocols
+	}
+
+	for _, p := range protocols {
+		var tag *Tag
+		var err error
+		switch p {
+		case ProtocolISO15693:
+			tag, err = r.read15693(ctx)
+		case ProtocolISO14443A:
+			tag, err = r.read14a(ctx)
+		case ProtocolLF:
+			tag, err = r.readLF(ctx)
+		default:
+			return nil, fmt.Errorf("rfid: unknown protocol %q", p)
+		}
+		if err != nil || tag != nil {
+			return tag, err
+		}
+	}
+	return nil, nil
+}
+
+// WritePayload writes data to the payload blocks of tag
+func (r *TagReader) WritePayload(ctx context.Context, tag *Tag, data []byte) error {
+	l, ok := layouts[tag.Type]
+	if !ok || l.write == "" {
+		return fmt.Errorf("%w: %s", ErrUnsupportedTag, tag.Type)
+	}
+	if len(data) > l.count*l.size {
+		return fmt.Errorf("rfid: payload of %d bytes does not fit %s", len(data), tag.Type)
+	}
+
+	for i := 0; i*l.size < len(data); i++ {
+		block := make([]byte, l.size)
+		copy(block, data[i*l.size:])
+
+		var cmd string
+		if l.keyed {
+			cmd = fmt.Sprintf(l.write, l.first+i, r.mifareKey(), block)
+		} else {
+			cmd = fmt.Sprintf(l.write, l.first+i, block)
+		}
+		res, err := r.Client.Run(ctx, cmd)
+		if err != nil {
+			return fmt.Errorf("failed to write block %d: %w", l.first+i, err)
+		}
+		if res.Failed() {
+			return fmt.Errorf("failed to write block %d: %s", l.first+i, strings.TrimSpace(res.Output))
+		}
+	}
+	return nil
+}
+
+// read15693 reads an ISO15693 tag
+func (r *TagReader) read15693(ctx context.Context) (*Tag, error) {
+	res, err := r.Client.Run(ctx, "hf 15 info")
+	if err := commandErr(ctx, res, err); err != nil {
+		return nil, err
+	}
+	if res.NoTag() {
+		return nil, nil
+	}
+	uid := parseUID(res.Output)
+	if uid == "" {
+		return nil, nil
+	}
+
+	tag := &Tag{Protocol: ProtocolISO15693, Type: TypeISO15693, UID: uid}
+	l := layouts[TypeISO15693]
+	res, err = r.Client.Run(ctx, fmt.Sprintf("hf 15 rdmulti -* -b %d --cnt %d", l.first, l.count))
+	if err := commandErr(ctx, res, err); err != nil {
+		return nil, err
+	}
+	if res.NoTag() {
+		return nil, nil
+	}
+	memory, err := parseHexTable(res.Output)
+	if err != nil {
+		// Ignore parse errors gracefully, the UID alone may still match
+		return tag, nil
+	}
+	tag.Blocks = splitBlocks(memory, l.size)
+	return tag, nil
+}
+
+// read14a reads an ISO14443A tag and, for known types, its payload blocks
+func (r *TagReader) read14a(ctx context.Context) (*Tag, error) {
+	res, err := r.Client.Run(ctx, "hf 14a info")
+	if err := commandErr(ctx, res, err); err != nil {
+		return nil, err
+	}
+	if res.NoTag() {
+		return nil, nil
+	}
+	uid := parseUID(res.Output)
+	if uid == "" {
+		return nil, nil
+	}
+
+	tag := &Tag{Protocol: ProtocolISO14443A, UID: uid}
+	if m := atqaPattern.FindStringSubmatch(res.Output); m != nil {
+		tag.ATQA = NormalizeUID(m[1])
+	}
+	if m := sakPattern.FindStringSubmatch(res.Output); m != nil {
+		tag.SAK = strings.ToUpper(m[1])
+	}
+	tag.Type = type14a(tag.SAK, res.Output)
+
+	l, ok := layouts[tag.Type]
+	if !ok {
+		return tag, nil
+	}
+	for i := 0; i < l.count; i++ {
+		var cmd string
+		if l.keyed {
+			cmd = fmt.Sprintf(l.read, l.first+i, r.mifareKey())
+		} else {
+			cmd = fmt.Sprintf(l.read, l.first+i)
+		}
+		res, err := r.Client.Run(ctx, cmd)
+		if err := commandErr(ctx, res, err); err != nil {
+			return nil, err
+		}
+		if res.NoTag() || res.Failed() {
+			// Unreadable blocks (e.g. a non-default key) leave only the UID to match
+			break
+		}
+		block, err := parseHexTable(res.Output)
+		if err != nil || len(block) < l.size {
+			break
+		}
+		tag.Blocks = append(tag.Blocks, block[:l.size])
+	}
+	return tag, nil
+}
+
+// readLF searches for a 125 kHz tag. LF tags carry only an ID.
+func (r *TagReader) readLF(ctx context.Context) (*Tag, error) {
+	res, err := r.Client.Run(ctx, "lf search")
+	if err := commandErr(ctx, res, err); err != nil {
+		return nil, err
+	}
+	if res.NoTag() {
+		return nil, nil
+	}
+
+	if m := em410xPattern.FindStringSubmatch(res.Output); m != nil {
+		return &Tag{Protocol: ProtocolLF, Type: TypeEM410x, UID: NormalizeUID(m[1])}, nil
+	}
+	if strings.Contains(res.Output, "HID") {
+		if m := lfRawPattern.FindStringSubmatch(res.Output); m != nil {
+			return &Tag{Protocol: ProtocolLF, Type: TypeHIDProx, UID: NormalizeUID(m[1])}, nil
+		}
+	}
+	return nil, nil
+}
+
+// commandErr returns the error of a probe or read that has to stop the scan.
+// The exec client reports a command that found no tag as a non-zero exit of
+// the proxmark3 process, which is ignored unless the output shows the device
+// went away. Session errors such as ErrDisconnected and ErrCommandTimeout come
+// with empty output that must not be mistaken for an empty field.
+func commandErr(ctx context.Context, res Result, err error) error {
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || ctx.Err() != nil {
+		return err
+	}
+	for _, m := range disconnectMarkers {
+		if strings.Contains(res.Output, m) {
+			return fmt.Errorf("%w: %v", ErrDisconnected, err)
+		}
+	}
+	return nil
+}
+
+// mifareKey returns the configured MIFARE Classic key
+func (r *TagReader) mifareKey() string {
+	if r.MifareKey == "" {
+		return DefaultMifareKey
+	}
+	return r.MifareKey
+}
+
+// type14a identifies an ISO14443A tag from its SAK and the info output
+func type14a(sak, output string) string {
+	v, err := strconv.ParseUint(sak, 16, 8)
+	if err != nil {
+		return TypeISO14443A
+	}
+	switch v {
+	case 0x08, 0x88, 0x28:
+		return TypeMifareClassic1K
+	case 0x18, 0x38:
+		return TypeMifareClassic4K
+	case 0x09:
+		return TypeMifareMini
+	case 0x00:
+		if ntagPattern.MatchString(output) {
+			return TypeNTAG
+		}
+		return TypeUltralight
+	}
+	return TypeISO14443A
+}
+
+// splitBlocks cuts memory into blocks of size bytes
+func splitBlocks(memory []byte, size int) [][]byte {
+	var blocks [][]byte
+	for len(memory) >= size {
+		blocks = append(blocks, memory[:size])
+		memory = memory[size:]
+	}
+	return blocks
+}
@@ -0,0 +1,279 @@
+package rfid
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Probe outputs with no tag in the field
+const (
+	noTag15693 = "[usb] pm3 --> hf 15 info\n[-] No tag found.\n"
+	noTag14a   = "[usb] pm3 --> hf 14a info\n[!] iso14443a card select failed\n"
+	noTagLF    = "[usb] pm3 --> lf search\n[-] No known 125/134 kHz tags found!\n"
+)
+
+func TestTagReaderRead(t *testing.T) {
+	tests := []struct {
+		name       string
+		transcript string
+		want       *Tag
+		wantMemory string
+		wantCalls  []string
+	}{
+		{
+			name: "iso15693 with payload",
+			transcript: "[usb] pm3 --> hf 15 info\n" +
+				"[+]            UID: E0 04 01 50 12 34 56 78\n" +
+				"[usb] pm3 --> hf 15 rdmulti -* -b 3 --cnt 6\n" +
+				"[=]   3 | 6D 6F 74 6F |     | moto\n" +
+				"[=]   4 | 70 69 2D 31 |     | pi-1\n" +
+				"[=]   5 | 00 00 00 00 |     | ....\n" +
+				"[=]   6 | 00 00 00 00 |     | ....\n" +
+				"[=]   7 | 00 00 00 00 |     | ....\n" +
+				"[=]   8 | 00 00 00 00 |     | ....\n",
+			want:       &Tag{Protocol: ProtocolISO15693, Type: TypeISO15693, UID: "E004015012345678"},
+			wantMemory: "motopi-1" + strings.Repeat("\x00", 16),
+			wantCalls:  []string{"hf 15 info", "hf 15 rdmulti -* -b 3 --cnt 6"},
+		},
+		{
+			name: "mifare classic after an empty iso15693 probe",
+			transcript: noTag15693 +
+				"[usb] pm3 --> hf 14a info\n" +
+				"[+]  UID: 04 A1 B2 C3\n" +
+				"[+] ATQA: 00 04\n" +
+				"[+]  SAK: 08 [2]\n" +
+				"[usb] pm3 --> hf mf rdbl --blk 4 -k FFFFFFFFFFFF\n" +
+				"[=]   # | sector 01 / 0x01                                | ascii\n" +
+				"[=] ----+-------------------------------------------------+-----------------\n" +
+				"[=]   4 | 6D 6F 74 6F 70 69 00 00 00 00 00 00 00 00 00 00 | motopi..........\n" +
+				"[usb] pm3 --> hf mf rdbl --blk 5 -k FFFFFFFFFFFF\n" +
+				"[=]   5 | 62 69 6B 65 00 00 00 00 00 00 00 00 00 00 00 00 | bike............\n",
+			want:       &Tag{Protocol: ProtocolISO14443A, Type: TypeMifareClassic1K, UID: "04A1B2C3", ATQA: "0004", SAK: "08"},
+			wantMemory: "motopi" + strings.Repeat("\x00", 10) + "bike" + strings.Repeat("\x00", 12),
+			wantCalls: []string{
+				"hf 15 info",
+				"hf 14a info",
+				"hf mf rdbl --blk 4 -k FFFFFFFFFFFF",
+				"hf mf rdbl --blk 5 -k FFFFFFFFFFFF",
+			},
+		},
+		{
+			name: "mifare classic with a foreign key keeps the UID",
+			transcript: noTag15693 +
+				"[usb] pm3 --> hf 14a info\n" +
+				"[+]  UID: 04 A1 B2 C3\n" +
+				"[+] ATQA: 00 04\n" +
+				"[+]  SAK: 08 [2]\n" +
+				"[usb] pm3 --> hf mf rdbl --blk 4 -k FFFFFFFFFFFF\n" +
+				"[-] Auth error\n",
+			want:      &Tag{Protocol: ProtocolISO14443A, Type: TypeMifareClassic1K, UID: "04A1B2C3", ATQA: "0004", SAK: "08"},
+			wantCalls: []string{"hf 15 info", "hf 14a info", "hf mf rdbl --blk 4 -k FFFFFFFFFFFF"},
+		},
+		{
+			name: "em410x",
+			transcript: noTag15693 + noTag14a +
+				"[usb] pm3 --> lf search\n" +
+				"[+] EM 410x ID 0F0368568B\n",
+			want:      &Tag{Protocol: ProtocolLF, Type: TypeEM410x, UID: "0F0368568B"},
+			wantCalls: []string{"hf 15 info", "hf 14a info", "lf search"},
+		},
+		{
+			name:       "empty field",
+			transcript: noTag15693 + noTag14a + noTagLF,
+			wantCalls:  []string{"hf 15 info", "hf 14a info", "lf search"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := ParseTranscript(strings.NewReader(tt.transcript))
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader := NewTagReader(client, Config{})
+
+			tag, err := reader.Read(context.Background())
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if calls := client.Calls(); !slices.Equal(calls, tt.wantCalls) {
+				t.Errorf("calls = %q, want %q", calls, tt.wantCalls)
+			}
+			if tt.want == nil {
+				if tag != nil {
+					t.Fatalf("Read() = %+v, want no tag", tag)
+				}
+				return
+			}
+			if tag == nil {
+				t.Fatal("Read() found no tag")
+			}
+			if tag.Protocol != tt.want.Protocol || tag.Type != tt.want.Type || tag.UID != tt.want.UID ||
+				tag.ATQA != tt.want.ATQA || tag.SAK != tt.want.SAK {
+				t.Errorf("Read() = %+v, want %+v", tag, tt.want)
+			}
+			if memory := tag.Memory(); !bytes.Equal(memory, []byte(tt.wantMemory)) {
+				t.Errorf("Memory() = %q, want %q", memory, tt.wantMemory)
+			}
+		})
+	}
+}
+
+// stubClient answers commands with fixed outputs and errors, and with an
+// empty field for any other command
+type stubClient map[string]stubReply
+
+type stubReply struct {
+	output string
+	err    error
+}
+
+func (c stubClient) Run(ctx context.Context, command string) (Result, error) {
+	reply, ok := c[command]
+	if !ok {
+		return Result{Command: command, Output: "[-] No tag found.\n"}, nil
+	}
+	return Result{Command: command, Output: reply.output}, reply.err
+}
+
+func (c stubClient) Close() error   { return nil }
+func (c stubClient) String() string { return "stub" }
+
+func TestTagReaderReadErrors(t *testing.T) {
+	// A proxmark3 process exiting non-zero, as the exec client reports it
+	exitErr := fmt.Errorf("failed to run PM3 command: %w", exec.Command("sh", "-c", "exit 1").Run())
+	tag15693 := "[+]            UID: E0 04 01 50 12 34 56 78\n"
+
+	tests := []struct {
+		name    string
+		client  stubClient
+		wantTag bool
+		wantErr error
+	}{
+		{
+			name:    "session disconnected while probing",
+			client:  stubClient{"hf 15 info": {"", ErrDisconnected}},
+			wantErr: ErrDisconnected,
+		},
+		{
+			name:    "session lost on the last probe",
+			client:  stubClient{"lf search": {"", ErrDisconnected}},
+			wantErr: ErrDisconnected,
+		},
+		{
+			name: "command timeout reading memory",
+			client: stubClient{
+				"hf 15 info":                    {tag15693, nil},
+				"hf 15 rdmulti -* -b 3 --cnt 6": {"", ErrCommandTimeout},
+			},
+			wantErr: ErrCommandTimeout,
+		},
+		{
+			name: "mifare block read disconnected",
+			client: stubClient{
+				"hf 14a info":                        {"[+]  UID: 04 A1 B2 C3\n[+]  SAK: 08 [2]\n", nil},
+				"hf mf rdbl --blk 4 -k FFFFFFFFFFFF": {"", ErrDisconnected},
+			},
+			wantErr: ErrDisconnected,
+		},
+		{
+			name: "exec exit without a tag",
+			client: stubClient{
+				"hf 15 info":  {noTag15693, exitErr},
+				"hf 14a info": {noTag14a, exitErr},
+				"lf search":   {noTagLF, exitErr},
+			},
+		},
+		{
+			name:    "exec exit with a tag",
+			client:  stubClient{"hf 15 info": {tag15693, exitErr}, "hf 15 rdmulti -* -b 3 --cnt 6": {"[!] read failed\n", exitErr}},
+			wantTag: true,
+		},
+		{
+			name:    "exec client lost the device",
+			client:  stubClient{"hf 15 info": {"[!!] Communicating with Proxmark3 device failed\n", exitErr}},
+			wantErr: ErrDisconnected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag, err := NewTagReader(tt.client, Config{}).Read(context.Background())
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil) != (err == nil) {
+				t.Fatalf("Read() error = %v, want %v", err, tt.wantErr)
+			}
+			if (tag != nil) != tt.wantTag {
+				t.Errorf("Read() = %+v, want a tag %v", tag, tt.wantTag)
+			}
+		})
+	}
+}
+
+func TestTagReaderWritePayload(t *testing.T) {
+	tests := []struct {
+		name       string
+		tag        Tag
+		data       string
+		transcript string
+		wantCalls  []string
+		wantErr    bool
+	}{
+		{
+			name: "iso15693 pads the last block",
+			tag:  Tag{Type: TypeISO15693},
+			data: "motopi",
+			transcript: "[usb] pm3 --> hf 15 wrbl -* -b 3 -d 6D6F746F\n[+] Write OK\n" +
+				"[usb] pm3 --> hf 15 wrbl -* -b 4 -d 70690000\n[+] Write OK\n",
+			wantCalls: []string{"hf 15 wrbl -* -b 3 -d 6D6F746F", "hf 15 wrbl -* -b 4 -d 70690000"},
+		},
+		{
+			name:       "mifare classic with key",
+			tag:        Tag{Type: TypeMifareClassic1K},
+			data:       "moto",
+			transcript: "[usb] pm3 --> hf mf wrbl --blk 4 -k FFFFFFFFFFFF -d 6D6F746F000000000000000000000000\n[+] Write ( ok )\n",
+			wantCalls:  []string{"hf mf wrbl --blk 4 -k FFFFFFFFFFFF -d 6D6F746F000000000000000000000000"},
+		},
+		{
+			name:       "failed write",
+			tag:        Tag{Type: TypeISO15693},
+			data:       "moto",
+			transcript: "[usb] pm3 --> hf 15 wrbl -* -b 3 -d 6D6F746F\n[!] Write failed\n",
+			wantCalls:  []string{"hf 15 wrbl -* -b 3 -d 6D6F746F"},
+			wantErr:    true,
+		},
+		{
+			name:    "payload too large",
+			tag:     Tag{Type: TypeISO15693},
+			data:    strings.Repeat("x", 25),
+			wantErr: true,
+		},
+		{
+			name:    "read-only type",
+			tag:     Tag{Type: TypeEM410x},
+			data:    "moto",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := ParseTranscript(strings.NewReader(tt.transcript))
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader := NewTagReader(client, Config{})
+			if err := reader.WritePayload(context.Background(), &tt.tag, []byte(tt.data)); (err != nil) != tt.wantErr {
+				t.Fatalf("WritePayload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls := client.Calls(); !slices.Equal(calls, tt.wantCalls) {
+				t.Errorf("calls = %q, want %q", calls, tt.wantCalls)
+			}
+		})
+	}
+}
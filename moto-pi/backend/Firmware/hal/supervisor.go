@@ -0,0 +1,337 @@
+package hal
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Supervisor states
+const (
+	StateStarting = "starting" // first Init in progress
+	StateHealthy  = "healthy"
+	StateDegraded = "degraded" // failed, restarting with backoff
+	StateFailed   = "failed"   // failed MaxFailures times in a row, still retrying at MaxBackoff
+)
+
+// Defaults for the restart backoff
+const (
+	DefaultMinBackoff    = time.Second
+	DefaultMaxBackoff    = time.Minute
+	DefaultCheckInterval = time.Second
+	DefaultMaxFailures   = 5
+)
+
+// Monitored is implemented by devices whose background work can stop after
+// Init. Err returns the error that stopped it, nil while it is working.
+type Monitored interface {
+	Err() error
+}
+
+// BackoffConfig controls how supervised devices are restarted
+type BackoffConfig struct {
+	MinBackoff    string `json:"minBackoff"`    // delay before the first restart, e.g. "1s"
+	MaxBackoff    string `json:"maxBackoff"`    // cap of the doubling delay, e.g. "1m"
+	CheckInterval string `json:"checkInterval"` // how often a healthy device is checked
+	MaxFailures   int    `json:"maxFailures"`   // consecutive failures before a device counts as failed
+}
+
+// backoff is the parsed BackoffConfig
+type backoff struct {
+	min, max    time.Duration
+	check       time.Duration
+	maxFailures int
+}
+
+// parse reads the durations of cfg, using the defaults for empty fields
+func (cfg BackoffConfig) parse() (backoff, error) {
+	b := backoff{
+		min:         DefaultMinBackoff,
+		max:         DefaultMaxBackoff,
+		check:       DefaultCheckInterval,
+		maxFailures: DefaultMaxFailures,
+	}
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"minBackoff", cfg.MinBackoff, &b.min},
+		{"maxBackoff", cfg.MaxBackoff, &b.max},
+		{"checkInterval", cfg.CheckInterval, &b.check},
+	} {
+		if f.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f.value)
+		if err != nil || d <= 0 {
+			return b, fmt.Errorf("hal: invalid %s %q", f.name, f.value)
+		}
+		*f.dst = d
+	}
+	if cfg.MaxFailures > 0 {
+		b.maxFailures = cfg.MaxFailures
+	}
+	b.max = max(b.max, b.min)
+	return b, nil
+}
+
+// delay returns the jittered wait before restart attempt n (from 1): the
+// backoff doubles from min up to max and a random half of it is added
+func (b backoff) delay(n int) time.Duration {
+	d := b.min
+	for i := 1; i < n && d < b.max; i++ {
+		d *= 2
+	}
+	d = min(d, b.max)
+	return d/2 + rand.N(d/2+1)
+}
+
+// Supervisor runs a device and restarts it with exponential backoff when
+// Init fails or, for a Monitored device, its background work stops
+type Supervisor struct {
+	name string
+	dev  Device
+	cfg  backoff
+
+	mu          sync.Mutex
+	state       string
+	failures    int // total
+	restarts    int // successful restarts
+	consecutive int
+	healthyAt   time.Time // last successful start
+	lastErr     error
+	lastFailure time.Time
+	nextRetry   time.Time
+	cancel      context.CancelFunc // stops run
+	done        chan struct{}
+}
+
+// Ensure Supervisor implements ContextDevice
+var _ ContextDevice = (*Supervisor)(nil)
+
+// NewSupervisor wraps dev, which is not started until Init
+func NewSupervisor(name string, dev Device, cfg BackoffConfig) (*Supervisor, error) {
+	b, err := cfg.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Supervisor{name: name, dev: dev, cfg: b, state: StateStopped}, nil
+}
+
+// Init starts the device and keeps it running
+func (s *Supervisor) Init() error {
+	return s.InitContext(context.Background())
+}
+
+// InitContext starts the device within ctx and keeps it running. An error
+// from the first start is returned, the device is still retried in the
+// background until Close.
+func (s *Supervisor) InitContext(ctx context.Context) error {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.mu.Unlock()
+		return nil
+	}
+	s.state = StateStarting
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	done := s.done
+	s.mu.Unlock()
+
+	err := InitContext(ctx, s.dev)
+	if err != nil {
+		s.fail(err)
+	} else {
+		s.healthy()
+	}
+	go s.run(runCtx, done)
+	return err
+}
+
+// Close stops supervising and closes the device
+func (s *Supervisor) Close() error {
+	return s.CloseContext(context.Background())
+}
+
+// CloseContext stops supervising and closes the device within ctx
+func (s *Supervisor) CloseContext(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	// A restart in progress is cancelled with run
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	// Past the deadline the device is still closed, ctx only bounds the wait
+	err := CloseContext(ctx, s.dev)
+	s.mu.Lock()
+	s.state = StateStopped
+	s.mu.Unlock()
+	return err
+}
+
+// Info returns the supervisor state followed by the device's own status
+func (s *Supervisor) Info() string {
+	s.mu.Lock()
+	state, failures, lastErr, nextRetry := s.state, s.failures, s.lastErr, s.nextRetry
+	s.mu.Unlock()
+	info := s.dev.Info()
+	switch state {
+	case StateDegraded, StateFailed:
+		return fmt.Sprintf("%s (%d failures, retry in %s: %v): %s", state, failures,
+			time.Until(nextRetry).Round(time.Second), lastErr, info)
+	}
+	return fmt.Sprintf("%s: %s", state, info)
+}
+
+// Health returns the device's report, with the supervisor state taking
+// precedence while the device is not running
+func (s *Supervisor) Health() Health {
+	h := s.dev.Health()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch s.state {
+	case StateStarting:
+		h.State = HealthStarting
+	case StateDegraded:
+		h.State = HealthDegraded
+	case StateFailed:
+		h.State = HealthFailed
+	case StateStopped:
+		h.State = HealthOffline
+	}
+	if s.state != StateHealthy && s.lastErr != nil {
+		h.Error = s.lastErr.Error()
+	}
+	counters := map[string]uint64{"failures": uint64(s.failures), "restarts": uint64(s.restarts)}
+	for k, v := range h.Counters {
+		counters[k] = v
+	}
+	h.Counters = counters
+	return h
+}
+
+// Device returns the supervised device
+func (s *Supervisor) Device() Device {
+	return s.dev
+}
+
+// Status returns a snapshot of the supervisor state
+func (s *Supervisor) Status() DeviceStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := DeviceStatus{
+		Name:  s.name,
+		Type:  deviceType(s.dev),
+		State: s.state,
+	}
+	if s.lastErr != nil {
+		lastFailure := s.lastFailure
+		st.LastFailure = &lastFailure
+	}
+	if s.state == StateDegraded || s.state == StateFailed {
+		nextRetry := s.nextRetry
+		st.NextRetry = &nextRetry
+	}
+	return st
+}
+
+// run checks a healthy device and restarts a failed one until ctx is done
+func (s *Supervisor) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	for {
+		s.mu.Lock()
+		wait := s.cfg.check
+		retrying := s.state == StateDegraded || s.state == StateFailed
+		if retrying {
+			wait = time.Until(s.nextRetry)
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		if retrying {
+			s.restart(ctx)
+			continue
+		}
+		if m, ok := s.dev.(Monitored); ok {
+			if err := m.Err(); err != nil {
+				s.fail(err)
+				continue
+			}
+		}
+		s.stable()
+	}
+}
+
+// restart closes and re-initialises the device, giving up when ctx is done
+func (s *Supervisor) restart(ctx context.Context) {
+	if err := CloseContext(ctx, s.dev); err != nil && ctx.Err() == nil {
+		fmt.Printf("HAL device %s: close before restart: %v\n", s.name, err)
+	}
+	if err := InitContext(ctx, s.dev); err != nil {
+		if ctx.Err() != nil {
+			// Supervision stopped, Close takes over
+			return
+		}
+		s.fail(err)
+		return
+	}
+	fmt.Printf("HAL device %s restarted\n", s.name)
+	s.mu.Lock()
+	s.restarts++
+	s.mu.Unlock()
+	s.healthy()
+}
+
+// healthy records a successful start. The failure streak is kept until the
+// device proves stable, so one failing right after Init still backs off.
+func (s *Supervisor) healthy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = StateHealthy
+	s.healthyAt = time.Now()
+}
+
+// stable ends the failure streak once the device has run without error for
+// a full check interval
+func (s *Supervisor) stable() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == StateHealthy && time.Since(s.healthyAt) >= s.cfg.check {
+		s.consecutive = 0
+	}
+}
+
+// fail records err and schedules the next restart
+func (s *Supervisor) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures++
+	s.consecutive++
+	s.lastErr = err
+	s.lastFailure = time.Now()
+	delay := s.cfg.delay(s.consecutive)
+	s.nextRetry = s.lastFailure.Add(delay)
+	s.state = StateDegraded
+	if s.consecutive >= s.cfg.maxFailures {
+		s.state = StateFailed
+	}
+	fmt.Printf("HAL device %s %s: %v, retrying in %s\n", s.name, s.state, err, delay.Round(time.Millisecond))
+}
@@ -0,0 +1,111 @@
+package hal
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMonitored is a device whose background work stops right after each of
+// its first failFor starts
+type fakeMonitored struct {
+	failFor int
+
+	mu    sync.Mutex
+	inits int
+	err   error
+}
+
+func (d *fakeMonitored) Init() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inits++
+	d.err = nil
+	if d.inits <= d.failFor {
+		d.err = errors.New("stopped")
+	}
+	return nil
+}
+
+func (d *fakeMonitored) Close() error   { return nil }
+func (d *fakeMonitored) Info() string   { return "fake" }
+func (d *fakeMonitored) Health() Health { return Health{State: HealthOK} }
+
+func (d *fakeMonitored) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+// newTestSupervisor starts dev with fast backoff and a limit of 3 failures
+func newTestSupervisor(t *testing.T, dev Device) *Supervisor {
+	t.Helper()
+	s, err := NewSupervisor("fake", dev, BackoffConfig{
+		MinBackoff:    "1ms",
+		MaxBackoff:    "4ms",
+		CheckInterval: "10ms",
+		MaxFailures:   3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// waitState polls s until it reaches state
+func waitState(t *testing.T, s *Supervisor, state string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Status().State != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %s, want %s", s.Status().State, state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSupervisorFailsAfterInit(t *testing.T) {
+	dev := &fakeMonitored{failFor: 1 << 30}
+	s := newTestSupervisor(t, dev)
+
+	// Each restart succeeds but the device stops again before it proves stable
+	waitState(t, s, StateFailed)
+	s.mu.Lock()
+	consecutive := s.consecutive
+	s.mu.Unlock()
+	if consecutive < 3 {
+		t.Errorf("consecutive failures = %d, want at least 3", consecutive)
+	}
+}
+
+func TestSupervisorRecovers(t *testing.T) {
+	dev := &fakeMonitored{failFor: 2}
+	s := newTestSupervisor(t, dev)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		dev.mu.Lock()
+		inits := dev.inits
+		dev.mu.Unlock()
+		if inits > dev.failFor {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d starts, want %d", inits, dev.failFor+1)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Stable for more than a check interval ends the failure streak
+	time.Sleep(50 * time.Millisecond)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != StateHealthy || s.consecutive != 0 || s.restarts != 2 {
+		t.Errorf("state = %s, consecutive = %d, restarts = %d, want healthy, 0, 2", s.state, s.consecutive, s.restarts)
+	}
+}
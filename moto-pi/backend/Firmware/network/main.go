@@ -1,45 +1,50 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/B64-Cryptzo/moto-pi-network/monitor"
 	"github.com/B64-Cryptzo/moto-pi-network/scan"
 )
 
+// cleanupTimeout bounds how long resetting the interfaces may take on exit
+const cleanupTimeout = 5 * time.Second
+
 func cleanup() {
-	err := monitor.ResetAllInterfacesToManaged()
+	// Not tied to the signal context, which is already done on exit
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	err := monitor.ResetAllInterfacesToManaged(ctx)
 	if err != nil {
 		log.Printf("Failed to reset interface on cleanup: %v", err)
 	}
 }
 
 func main() {
-
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		cleanup()
-		os.Exit(0)
-	}()
+	// A signal cancels the running commands, cleanup then runs as main returns
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	defer cleanup()
 
-	err := monitor.ResetAllInterfacesToManaged()
+	err := monitor.ResetAllInterfacesToManaged(ctx)
 	if err != nil {
-		log.Fatalf("Failed to reset interfaces: %v", err)
+		log.Printf("Failed to reset interfaces: %v", err)
+		return
 	}
 
 	scanner := &scan.RealScanner{Interface: "wlan1"}
 
-	aps, err := scanner.ScanNetworks()
+	aps, err := scanner.ScanNetworks(ctx)
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
+		return
 	}
 
 	log.Printf("Found %d Access Points", len(aps))
@@ -2,6 +2,7 @@ package monitor
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os/exec"
@@ -10,20 +11,21 @@ import (
 )
 
 // ResetAllInterfacesToManaged sets all wireless interfaces out of monitor mode into managed mode.
-func ResetAllInterfacesToManaged() error {
-	ifaces, err := getWirelessInterfaces()
+// The commands are killed once ctx is done.
+func ResetAllInterfacesToManaged(ctx context.Context) error {
+	ifaces, err := getWirelessInterfaces(ctx)
 	if err != nil {
 		return err
 	}
 
 	for _, iface := range ifaces {
-		mode, err := getInterfaceMode(iface)
+		mode, err := getInterfaceMode(ctx, iface)
 		if err != nil {
 			return fmt.Errorf("failed to get mode for %s: %w", iface, err)
 		}
 
 		if mode == "Monitor" {
-			if err := setInterfaceModeManaged(iface); err != nil {
+			if err := setInterfaceModeManaged(ctx, iface); err != nil {
 				return fmt.Errorf("failed to reset %s to managed mode: %w", iface, err)
 			}
 			fmt.Printf("Set interface %s to managed mode\n", iface)
@@ -33,8 +35,8 @@ func ResetAllInterfacesToManaged() error {
 	return nil
 }
 
-func getWirelessInterfaces() ([]string, error) {
-	cmd := exec.Command("iw", "dev")
+func getWirelessInterfaces(ctx context.Context) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "iw", "dev")
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, err
@@ -55,8 +57,8 @@ func getWirelessInterfaces() ([]string, error) {
 	return ifaces, nil
 }
 
-func getInterfaceMode(iface string) (string, error) {
-	cmd := exec.Command("iwconfig", iface)
+func getInterfaceMode(ctx context.Context, iface string) (string, error) {
+	cmd := exec.CommandContext(ctx, "iwconfig", iface)
 	out, err := cmd.Output()
 	if err != nil {
 		return "", err
@@ -70,24 +72,24 @@ func getInterfaceMode(iface string) (string, error) {
 	return "Managed", nil
 }
 
-func setInterfaceModeManaged(iface string) error {
-	//
+func setInterfaceModeManaged(ctx context.Context, iface string) error {
 	for i := 0; i < 3; i++ {
-		err := exec.Command("ip", "link", "set", iface, "down").Run()
-		if err != nil {
-			time.Sleep(200 * time.Millisecond)
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(200 * time.Millisecond):
+			}
+		}
+		if err := exec.CommandContext(ctx, "ip", "link", "set", iface, "down").Run(); err != nil {
 			continue
 		}
-		err = exec.Command("iw", iface, "set", "type", "managed").Run()
-		if err != nil {
-			time.Sleep(200 * time.Millisecond)
+		if err := exec.CommandContext(ctx, "iw", iface, "set", "type", "managed").Run(); err != nil {
 			continue
 		}
-		err = exec.Command("ip", "link", "set", iface, "up").Run()
-		if err == nil {
+		if err := exec.CommandContext(ctx, "ip", "link", "set", iface, "up").Run(); err == nil {
 			return nil
 		}
-		time.Sleep(200 * time.Millisecond)
 	}
 	return errors.New("failed to set interface to managed mode after retries")
 }
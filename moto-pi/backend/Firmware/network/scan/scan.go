@@ -3,6 +3,7 @@ package scan
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os/exec"
@@ -19,15 +20,20 @@ type AccessPoint struct {
 	MAC            string // BSSID (MAC address)
 }
 
-// NetworkInterface defines the interface for scanning networks.
+// NetworkInterface defines the interface for scanning networks. A scan gives
+// up once ctx is done.
 type NetworkInterface interface {
-	ScanNetworks() ([]AccessPoint, error)
+	ScanNetworks(ctx context.Context) ([]AccessPoint, error)
 }
 
 // StubScanner implements NetworkInterface and returns mock data.
 type StubScanner struct{}
 
-func (s *StubScanner) ScanNetworks() ([]AccessPoint, error) {
+func (s *StubScanner) ScanNetworks(ctx context.Context) ([]AccessPoint, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	aps := []AccessPoint{
 		{SSID: "HomeWiFi", SignalStrength: -40, Encryption: "WPA2", MAC: "00:11:22:33:44:55"},
 		{SSID: "CafeNet", SignalStrength: -70, Encryption: "Open", MAC: "66:77:88:99:AA:BB"},
@@ -45,8 +51,8 @@ type RealScanner struct {
 }
 
 // ensureMonitorMode switches the interface to monitor mode if it’s not already.
-func (r *RealScanner) ensureMonitorMode() error {
-	mode, err := r.getInterfaceMode()
+func (r *RealScanner) ensureMonitorMode(ctx context.Context) error {
+	mode, err := r.getInterfaceMode(ctx)
 	if err != nil {
 		return err
 	}
@@ -63,7 +69,7 @@ func (r *RealScanner) ensureMonitorMode() error {
 	}
 
 	for _, cmdArgs := range cmds {
-		cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
+		cmd := exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...)
 		if out, err := cmd.CombinedOutput(); err != nil {
 			return fmt.Errorf("failed to run %v: %v - output: %s", cmdArgs, err, string(out))
 		}
@@ -73,8 +79,8 @@ func (r *RealScanner) ensureMonitorMode() error {
 }
 
 // getInterfaceMode returns the current mode of the wireless interface.
-func (r *RealScanner) getInterfaceMode() (string, error) {
-	cmd := exec.Command("iwconfig", r.Interface)
+func (r *RealScanner) getInterfaceMode(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, "iwconfig", r.Interface)
 	out, err := cmd.Output()
 	if err != nil {
 		return "", err
@@ -92,12 +98,13 @@ func (r *RealScanner) getInterfaceMode() (string, error) {
 	return "Managed", nil
 }
 
-func (r *RealScanner) ScanNetworks() ([]AccessPoint, error) {
-	if err := r.ensureMonitorMode(); err != nil {
+// ScanNetworks runs `iw <iface> scan`, killing it when ctx is done.
+func (r *RealScanner) ScanNetworks(ctx context.Context) ([]AccessPoint, error) {
+	if err := r.ensureMonitorMode(ctx); err != nil {
 		return nil, err
 	}
 
-	cmd := exec.Command("iw", r.Interface, "scan")
+	cmd := exec.CommandContext(ctx, "iw", r.Interface, "scan")
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, err
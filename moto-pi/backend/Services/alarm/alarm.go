@@ -0,0 +1,338 @@
+package alarm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gps"
+	"github.com/B64-Cryptzo/MotoPi/backend/Services/journal"
+)
+
+// Trigger reasons
+const (
+	ReasonMoved = "moved"             // position drifted beyond the anchor radius
+	ReasonSpeed = "speed_no_ignition" // moving while the ignition is off
+)
+
+// historySize is the number of triggers kept in the history file
+const historySize = 200
+
+// anchorMaxAge is the oldest fix Arm parks at, with an older one the alarm
+// waits for the next fix
+const anchorMaxAge = 10 * time.Second
+
+// Config controls the theft/motion alarm
+type Config struct {
+	RadiusMeters  float64 `json:"radiusMeters"`  // allowed drift from the parked position
+	MaxSpeedKph   float64 `json:"maxSpeedKph"`   // speed that triggers while the ignition is off
+	SirenDuration string  `json:"sirenDuration"` // how long the siren sounds per trigger, e.g. "30s"
+	SirenPin      string  `json:"sirenPin"`      // GPIO pin map output driving the siren, none when empty
+	MaxHDOP       float64 `json:"maxHdop"`       // fixes with a worse HDOP are ignored, 0 disables
+	HistoryFile   string  `json:"historyFile"`   // JSON file holding past triggers
+}
+
+// FixSource provides a stream of GPS fixes, satisfied by *gps.GPS
+type FixSource interface {
+	Subscribe(ctx context.Context) <-chan gps.GPSData
+}
+
+// IgnitionSense reports the ignition state of the bike
+type IgnitionSense interface {
+	IgnitionOn() (bool, error)
+}
+
+// Trigger records one alarm activation and the fix that caused it
+type Trigger struct {
+	Time           time.Time `json:"time"`
+	Reason         string    `json:"reason"`
+	Latitude       float64   `json:"lat"`
+	Longitude      float64   `json:"lng"`
+	SpeedKph       float64   `json:"speedKph"`
+	DistanceMeters float64   `json:"distanceMeters"` // drift from the parked position
+}
+
+// Position is a parked location
+type Position struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lng"`
+}
+
+// Status is a snapshot of the alarm state
+type Status struct {
+	Armed    bool       `json:"armed"`
+	Alarming bool       `json:"alarming"`
+	ArmedAt  *time.Time `json:"armedAt"`
+	Anchor   *Position  `json:"anchor"`
+	Last     *Trigger   `json:"lastTrigger"`
+}
+
+// Alarm watches the GPS while armed and sounds the siren on movement
+type Alarm struct {
+	cfg      Config
+	siren    time.Duration
+	source   FixSource
+	actuator hal.Actuator  // optional siren/relay
+	ignition IgnitionSense // optional, unknown is treated as off
+
+	mu        sync.RWMutex
+	armed     bool
+	armedAt   time.Time
+	anchor    *gps.GPSData
+	lastFix   *gps.GPSData
+	lastFixAt time.Time // wall clock when lastFix arrived
+	quietAt   time.Time // end of the current siren activation
+	history   []Trigger
+
+	// io orders the journal, siren and history writes. It is taken before mu
+	// is released so the writes happen in the order of the state changes.
+	io sync.Mutex
+}
+
+// New creates an alarm. actuator and ignition may be nil.
+func New(cfg Config, source FixSource, actuator hal.Actuator, ignition IgnitionSense) (*Alarm, error) {
+	siren := 30 * time.Second
+	if cfg.SirenDuration != "" {
+		d, err := time.ParseDuration(cfg.SirenDuration)
+		if err != nil {
+			return nil, fmt.Errorf("alarm: invalid siren duration: %w", err)
+		}
+		siren = d
+	}
+
+	a := &Alarm{
+		cfg:      cfg,
+		siren:    siren,
+		source:   source,
+		actuator: actuator,
+		ignition: ignition,
+	}
+	if err := a.loadHistory(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Run watches fixes until ctx is done
+func (a *Alarm) Run(ctx context.Context) error {
+	fixes := a.source.Subscribe(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case fix, ok := <-fixes:
+			if !ok {
+				return ctx.Err()
+			}
+			a.evaluate(fix)
+		}
+	}
+}
+
+// Arm parks the alarm at the latest good fix, or the next one if none is
+// recent
+func (a *Alarm) Arm() error {
+	a.mu.Lock()
+	if a.armed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.armed = true
+	a.armedAt = time.Now().UTC()
+	a.anchor = nil
+	if a.lastFix != nil && time.Since(a.lastFixAt) <= anchorMaxAge {
+		a.anchor = a.lastFix
+	}
+	a.io.Lock()
+	a.mu.Unlock()
+	defer a.io.Unlock()
+
+	journal.Log("[ALARM_ARMED]")
+	return nil
+}
+
+// Disarm stops watching and silences the siren
+func (a *Alarm) Disarm() error {
+	a.mu.Lock()
+	if !a.armed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.armed = false
+	a.anchor = nil
+	a.quietAt = time.Time{}
+	a.io.Lock()
+	a.mu.Unlock()
+	defer a.io.Unlock()
+
+	journal.Log("[ALARM_DISARMED]")
+	if a.actuator != nil {
+		return a.actuator.Command("off")
+	}
+	return nil
+}
+
+// Armed reports whether the alarm is armed
+func (a *Alarm) Armed() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.armed
+}
+
+// Status returns the current alarm state
+func (a *Alarm) Status() Status {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	st := Status{
+		Armed:    a.armed,
+		Alarming: a.armed && time.Now().Before(a.quietAt),
+	}
+	if a.anchor != nil {
+		st.Anchor = &Position{Latitude: a.anchor.Latitude, Longitude: a.anchor.Longitude}
+	}
+	if a.armed {
+		armedAt := a.armedAt
+		st.ArmedAt = &armedAt
+	}
+	if n := len(a.history); n > 0 {
+		last := a.history[n-1]
+		st.Last = &last
+	}
+	return st
+}
+
+// History returns past triggers, oldest first
+func (a *Alarm) History() []Trigger {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return append([]Trigger{}, a.history...)
+}
+
+// evaluate checks a fix against the parked position and ignition state
+func (a *Alarm) evaluate(fix gps.GPSData) {
+	if !fix.GoodFix(a.cfg.MaxHDOP) {
+		return
+	}
+
+	ignitionOn := false
+	if a.ignition != nil {
+		if on, err := a.ignition.IgnitionOn(); err == nil {
+			ignitionOn = on
+		}
+	}
+
+	a.mu.Lock()
+	t, ok := a.checkLocked(fix, ignitionOn)
+	if !ok {
+		a.mu.Unlock()
+		return
+	}
+	history := a.recordLocked(t)
+	a.io.Lock()
+	a.mu.Unlock()
+	defer a.io.Unlock()
+
+	journal.Log(fmt.Sprintf("[ALARM_TRIGGERED] reason=%s lat=%.6f lng=%.6f speed=%.1fkph drift=%.0fm",
+		t.Reason, t.Latitude, t.Longitude, t.SpeedKph, t.DistanceMeters))
+
+	if a.actuator != nil {
+		if err := a.actuator.Command("pulse", a.siren); err != nil {
+			fmt.Println("Alarm siren error:", err)
+		}
+	}
+	if err := a.saveHistory(history); err != nil {
+		fmt.Println("Alarm history error:", err)
+	}
+}
+
+// checkLocked records fix and returns the trigger it causes, if any
+func (a *Alarm) checkLocked(fix gps.GPSData, ignitionOn bool) (Trigger, bool) {
+	a.lastFix = &fix
+	a.lastFixAt = time.Now()
+	if !a.armed {
+		return Trigger{}, false
+	}
+	if a.anchor == nil {
+		a.anchor = &fix
+		return Trigger{}, false
+	}
+
+	drift := gps.DistanceMeters(a.anchor.Latitude, a.anchor.Longitude, fix.Latitude, fix.Longitude)
+	reason := ""
+	switch {
+	case a.cfg.RadiusMeters > 0 && drift > a.cfg.RadiusMeters:
+		reason = ReasonMoved
+	case !ignitionOn && a.cfg.MaxSpeedKph > 0 && fix.SpeedKph > a.cfg.MaxSpeedKph:
+		reason = ReasonSpeed
+	}
+	if reason == "" || time.Now().Before(a.quietAt) {
+		return Trigger{}, false
+	}
+
+	t := Trigger{
+		Time:           fix.Timestamp,
+		Reason:         reason,
+		Latitude:       fix.Latitude,
+		Longitude:      fix.Longitude,
+		SpeedKph:       fix.SpeedKph,
+		DistanceMeters: drift,
+	}
+	if t.Time.IsZero() {
+		t.Time = time.Now().UTC()
+	}
+	return t, true
+}
+
+// recordLocked starts the siren period and appends t to the history,
+// returning a copy of the history to save
+func (a *Alarm) recordLocked(t Trigger) []Trigger {
+	a.quietAt = time.Now().Add(a.siren)
+	a.history = append(a.history, t)
+	if len(a.history) > historySize {
+		a.history = a.history[len(a.history)-historySize:]
+	}
+	return append([]Trigger(nil), a.history...)
+}
+
+// loadHistory reads past triggers. A missing file yields an empty history.
+func (a *Alarm) loadHistory() error {
+	if a.cfg.HistoryFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(a.cfg.HistoryFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &a.history); err != nil {
+		return fmt.Errorf("alarm: parse %s: %w", a.cfg.HistoryFile, err)
+	}
+	return nil
+}
+
+// saveHistory writes past triggers via a temp file and rename
+func (a *Alarm) saveHistory(history []Trigger) error {
+	if a.cfg.HistoryFile == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(a.cfg.HistoryFile), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := a.cfg.HistoryFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.cfg.HistoryFile)
+}
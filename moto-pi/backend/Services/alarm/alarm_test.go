@@ -0,0 +1,238 @@
+package alarm
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gps"
+)
+
+// fakeSiren records the commands sent to it
+type fakeSiren struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+func (s *fakeSiren) Init() error        { return nil }
+func (s *fakeSiren) Close() error       { return nil }
+func (s *fakeSiren) Info() string       { return "fake siren" }
+func (s *fakeSiren) Health() hal.Health { return hal.Health{State: hal.HealthOK} }
+
+func (s *fakeSiren) Command(cmd string, args ...any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, fmt.Sprint(append([]any{cmd}, args...)...))
+	return nil
+}
+
+func (s *fakeSiren) sent() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.commands...)
+}
+
+// ignition is a fixed ignition state
+type ignition bool
+
+func (i ignition) IgnitionOn() (bool, error) { return bool(i), nil }
+
+var t0 = time.Date(2026, 5, 1, 22, 0, 0, 0, time.UTC)
+
+// fix is a good fix sec seconds after t0, metres north of the parking spot
+func fix(sec int, north, speedKph float64) gps.GPSData {
+	return gps.GPSData{
+		Timestamp:  t0.Add(time.Duration(sec) * time.Second),
+		Latitude:   48 + north/111195,
+		Longitude:  11,
+		SpeedKph:   speedKph,
+		ValidFix:   true,
+		FixType:    gps.Fix3D,
+		HDOP:       1,
+		Confidence: 1,
+	}
+}
+
+func newAlarm(t *testing.T, cfg Config, actuator hal.Actuator, ign IgnitionSense) *Alarm {
+	t.Helper()
+	if cfg.HistoryFile == "" {
+		cfg.HistoryFile = filepath.Join(t.TempDir(), "alarm.json")
+	}
+	a, err := New(cfg, nil, actuator, ign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// anchorNorth returns the metres north of the parking spot the alarm is anchored at
+func anchorNorth(a *Alarm) (float64, bool) {
+	anchor := a.Status().Anchor
+	if anchor == nil {
+		return 0, false
+	}
+	return (anchor.Latitude - 48) * 111195, true
+}
+
+func TestAlarmAnchor(t *testing.T) {
+	tests := []struct {
+		name       string
+		seen       bool          // a fix arrived before arming
+		age        time.Duration // how long ago it arrived
+		wantArmed  bool          // anchored when armed
+		wantAnchor float64       // metres north once the next fix at 50 m arrived
+	}{
+		{"no fix yet", false, 0, false, 50},
+		{"recent fix", true, 2 * time.Second, true, 0},
+		{"fix at the age limit", true, anchorMaxAge - time.Second, true, 0},
+		{"stale fix", true, anchorMaxAge + time.Second, false, 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAlarm(t, Config{}, nil, nil)
+			if tt.seen {
+				a.evaluate(fix(0, 0, 0))
+				a.mu.Lock()
+				a.lastFixAt = time.Now().Add(-tt.age)
+				a.mu.Unlock()
+			}
+			if err := a.Arm(); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := anchorNorth(a); ok != tt.wantArmed {
+				t.Errorf("anchored on Arm() = %v, want %v", ok, tt.wantArmed)
+			}
+
+			a.evaluate(fix(60, 50, 0))
+			if north, ok := anchorNorth(a); !ok || north < tt.wantAnchor-0.5 || north > tt.wantAnchor+0.5 {
+				t.Errorf("anchor = %.1f m north, %v, want %.0f m", north, ok, tt.wantAnchor)
+			}
+		})
+	}
+}
+
+func TestAlarmTriggers(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Config
+		ignition IgnitionSense
+		fixes    []gps.GPSData // the first anchors the alarm
+		want     []string
+	}{
+		{
+			name:  "drift within the radius",
+			cfg:   Config{RadiusMeters: 30},
+			fixes: []gps.GPSData{fix(0, 0, 0), fix(1, 10, 0), fix(2, 25, 0)},
+		},
+		{
+			name:  "moved beyond the radius",
+			cfg:   Config{RadiusMeters: 30},
+			fixes: []gps.GPSData{fix(0, 0, 0), fix(1, 10, 0), fix(2, 40, 0)},
+			want:  []string{ReasonMoved},
+		},
+		{
+			name:  "speed without ignition",
+			cfg:   Config{MaxSpeedKph: 5},
+			fixes: []gps.GPSData{fix(0, 0, 0), fix(1, 2, 4), fix(2, 5, 12)},
+			want:  []string{ReasonSpeed},
+		},
+		{
+			name:     "speed with ignition",
+			cfg:      Config{MaxSpeedKph: 5},
+			ignition: ignition(true),
+			fixes:    []gps.GPSData{fix(0, 0, 0), fix(1, 5, 12)},
+		},
+		{
+			name:     "drift triggers with ignition",
+			cfg:      Config{RadiusMeters: 30, MaxSpeedKph: 5},
+			ignition: ignition(true),
+			fixes:    []gps.GPSData{fix(0, 0, 0), fix(1, 40, 12)},
+			want:     []string{ReasonMoved},
+		},
+		{
+			name: "bad fixes are ignored",
+			cfg:  Config{RadiusMeters: 30, MaxHDOP: 5},
+			fixes: []gps.GPSData{
+				fix(0, 0, 0),
+				func() gps.GPSData { f := fix(1, 100, 0); f.HDOP = 9; return f }(),
+				func() gps.GPSData { f := fix(2, 100, 0); f.Estimated = true; return f }(),
+			},
+		},
+		{
+			name:  "one trigger per siren period",
+			cfg:   Config{RadiusMeters: 30},
+			fixes: []gps.GPSData{fix(0, 0, 0), fix(1, 40, 0), fix(2, 80, 0), fix(3, 120, 0)},
+			want:  []string{ReasonMoved},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			siren := &fakeSiren{}
+			a := newAlarm(t, tt.cfg, siren, tt.ignition)
+			if err := a.Arm(); err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range tt.fixes {
+				a.evaluate(f)
+			}
+
+			var reasons []string
+			for _, trig := range a.History() {
+				reasons = append(reasons, trig.Reason)
+			}
+			if !slices.Equal(reasons, tt.want) {
+				t.Errorf("triggers = %v, want %v", reasons, tt.want)
+			}
+			var wantSiren []string
+			for range tt.want {
+				wantSiren = append(wantSiren, "pulse30s")
+			}
+			if got := siren.sent(); !slices.Equal(got, wantSiren) {
+				t.Errorf("siren commands = %q, want %q", got, wantSiren)
+			}
+			if st := a.Status(); st.Alarming != (len(tt.want) > 0) {
+				t.Errorf("Status().Alarming = %v, want %v", st.Alarming, len(tt.want) > 0)
+			}
+		})
+	}
+}
+
+func TestAlarmDisarmed(t *testing.T) {
+	siren := &fakeSiren{}
+	a := newAlarm(t, Config{RadiusMeters: 30, SirenDuration: "1m"}, siren, nil)
+
+	// Nothing triggers before arming
+	a.evaluate(fix(0, 0, 0))
+	a.evaluate(fix(1, 100, 0))
+	if err := a.Arm(); err != nil {
+		t.Fatal(err)
+	}
+	a.evaluate(fix(2, 200, 0))
+	if err := a.Disarm(); err != nil {
+		t.Fatal(err)
+	}
+	a.evaluate(fix(3, 300, 0))
+
+	want := []string{"pulse1m0s", "off"}
+	if got := siren.sent(); !slices.Equal(got, want) {
+		t.Errorf("siren commands = %q, want %q", got, want)
+	}
+	if st := a.Status(); st.Armed || st.Alarming || st.Anchor != nil || st.Last == nil || st.Last.Reason != ReasonMoved {
+		t.Errorf("Status() = %+v, want disarmed with the last trigger", st)
+	}
+
+	// The history survives a restart
+	reloaded, err := New(a.cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := reloaded.History(); len(h) != 1 || h[0].DistanceMeters < 99 || !h[0].Time.Equal(t0.Add(2*time.Second)) {
+		t.Errorf("History() after reload = %+v, want the one trigger", h)
+	}
+}
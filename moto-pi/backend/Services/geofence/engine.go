@@ -0,0 +1,315 @@
+package geofence
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gps"
+)
+
+// ErrNotFound is returned for unknown fence IDs
+var ErrNotFound = errors.New("geofence not found")
+
+// Event types
+const (
+	EventEnter = "enter"
+	EventExit  = "exit"
+	EventDwell = "dwell"
+)
+
+// historySize is the number of recent events kept in memory
+const historySize = 100
+
+// DefaultConfirmFixes is used when Config.ConfirmFixes is 0
+const DefaultConfirmFixes = 3
+
+// Config controls the geofence engine
+type Config struct {
+	File         string  `json:"file"`         // JSON file holding the fence definitions
+	MaxHDOP      float64 `json:"maxHdop"`      // fixes with a worse HDOP are ignored, 0 disables
+	ConfirmFixes int     `json:"confirmFixes"` // consecutive fixes across a boundary before enter or exit, 3 when 0
+}
+
+// FixSource provides a stream of GPS fixes, satisfied by *gps.GPS
+type FixSource interface {
+	Subscribe(ctx context.Context) <-chan gps.GPSData
+}
+
+// Event is emitted when the bike crosses or lingers in a fence
+type Event struct {
+	Type      string    `json:"type"`
+	FenceID   string    `json:"fenceId"`
+	FenceName string    `json:"fenceName"`
+	Time      time.Time `json:"time"`
+	Latitude  float64   `json:"lat"`
+	Longitude float64   `json:"lng"`
+}
+
+// fenceState tracks the bike relative to one fence
+type fenceState struct {
+	inside  bool
+	since   time.Time
+	dwelled bool
+	crossed int       // consecutive fixes on the other side of the boundary
+	crossAt time.Time // time of the first of those fixes
+}
+
+// Engine evaluates GPS fixes against the configured fences
+type Engine struct {
+	cfg     Config
+	confirm int
+	source  FixSource
+
+	mu      sync.RWMutex
+	fences  []Fence
+	states  map[string]*fenceState
+	history []Event
+	subs    map[chan Event]struct{}
+}
+
+// NewEngine loads the fence definitions from cfg.File
+func NewEngine(cfg Config, source FixSource) (*Engine, error) {
+	if cfg.File == "" {
+		return nil, errors.New("geofence: no fence file configured")
+	}
+	if cfg.ConfirmFixes < 0 {
+		return nil, fmt.Errorf("geofence: invalid confirmFixes %d", cfg.ConfirmFixes)
+	}
+	fences, err := loadFences(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+	confirm := cfg.ConfirmFixes
+	if confirm == 0 {
+		confirm = DefaultConfirmFixes
+	}
+	return &Engine{
+		cfg:     cfg,
+		confirm: confirm,
+		source:  source,
+		fences:  fences,
+		states:  make(map[string]*fenceState),
+		subs:    make(map[chan Event]struct{}),
+	}, nil
+}
+
+// Run evaluates fixes until ctx is done
+func (e *Engine) Run(ctx context.Context) error {
+	fixes := e.source.Subscribe(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case fix, ok := <-fixes:
+			if !ok {
+				return ctx.Err()
+			}
+			e.evaluate(fix)
+		}
+	}
+}
+
+// evaluate updates every fence state with a fix and emits the resulting events
+func (e *Engine) evaluate(fix gps.GPSData) {
+	if !fix.GoodFix(e.cfg.MaxHDOP) {
+		return
+	}
+	now := fix.Timestamp
+	if now.IsZero() {
+		now = time.Now().UTC()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i := range e.fences {
+		f := &e.fences[i]
+		inside := f.Contains(fix.Latitude, fix.Longitude)
+		st, known := e.states[f.ID]
+		if !known {
+			// The first fix only establishes where the bike is
+			e.states[f.ID] = &fenceState{inside: inside, since: now}
+			continue
+		}
+
+		// A crossing only counts once enough fixes in a row agree, so a
+		// position jittering along the boundary does not flap
+		if inside == st.inside {
+			st.crossed = 0
+		} else {
+			if st.crossed == 0 {
+				st.crossAt = now
+			}
+			st.crossed++
+		}
+
+		event := ""
+		switch {
+		case st.crossed >= e.confirm && inside:
+			event = EventEnter
+			*st = fenceState{inside: true, since: st.crossAt}
+		case st.crossed >= e.confirm:
+			event = EventExit
+			*st = fenceState{inside: false, since: st.crossAt}
+		case inside && st.inside && !st.dwelled && f.DwellSeconds > 0 && now.Sub(st.since).Seconds() >= f.DwellSeconds:
+			event = EventDwell
+			st.dwelled = true
+		}
+		if event != "" {
+			e.emitLocked(Event{
+				Type:      event,
+				FenceID:   f.ID,
+				FenceName: f.Name,
+				Time:      now,
+				Latitude:  fix.Latitude,
+				Longitude: fix.Longitude,
+			})
+		}
+	}
+}
+
+// emitLocked records an event and hands it to subscribers without blocking
+func (e *Engine) emitLocked(ev Event) {
+	fmt.Printf("Geofence %s: %s (%s)\n", ev.Type, ev.FenceName, ev.FenceID)
+	e.history = append(e.history, ev)
+	if len(e.history) > historySize {
+		e.history = e.history[len(e.history)-historySize:]
+	}
+	for ch := range e.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving fence events until ctx is done
+func (e *Engine) Subscribe(ctx context.Context) <-chan Event {
+	ch := make(chan Event, 16)
+	e.mu.Lock()
+	e.subs[ch] = struct{}{}
+	e.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		e.mu.Lock()
+		delete(e.subs, ch)
+		close(ch)
+		e.mu.Unlock()
+	}()
+	return ch
+}
+
+// Events returns the most recent events, oldest first
+func (e *Engine) Events() []Event {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]Event{}, e.history...)
+}
+
+// List returns all fences
+func (e *Engine) List() []Fence {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]Fence{}, e.fences...)
+}
+
+// Get returns a fence by ID
+func (e *Engine) Get(id string) (Fence, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if i := e.indexLocked(id); i >= 0 {
+		return e.fences[i], nil
+	}
+	return Fence{}, ErrNotFound
+}
+
+// Create validates and stores a new fence, assigning its ID
+func (e *Engine) Create(f Fence) (Fence, error) {
+	if err := f.Validate(); err != nil {
+		return Fence{}, err
+	}
+	id, err := newID()
+	if err != nil {
+		return Fence{}, err
+	}
+	f.ID = id
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fences := append(append([]Fence{}, e.fences...), f)
+	if err := e.commitLocked(fences); err != nil {
+		return Fence{}, err
+	}
+	return f, nil
+}
+
+// Update replaces the fence with the given ID
+func (e *Engine) Update(id string, f Fence) (Fence, error) {
+	f.ID = id
+	if err := f.Validate(); err != nil {
+		return Fence{}, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	i := e.indexLocked(id)
+	if i < 0 {
+		return Fence{}, ErrNotFound
+	}
+	fences := append([]Fence{}, e.fences...)
+	fences[i] = f
+	if err := e.commitLocked(fences); err != nil {
+		return Fence{}, err
+	}
+	delete(e.states, id)
+	return f, nil
+}
+
+// Delete removes the fence with the given ID
+func (e *Engine) Delete(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	i := e.indexLocked(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	fences := append(append([]Fence{}, e.fences[:i]...), e.fences[i+1:]...)
+	if err := e.commitLocked(fences); err != nil {
+		return err
+	}
+	delete(e.states, id)
+	return nil
+}
+
+// commitLocked persists fences and makes them current
+func (e *Engine) commitLocked(fences []Fence) error {
+	if err := saveFences(e.cfg.File, fences); err != nil {
+		return fmt.Errorf("geofence: save: %w", err)
+	}
+	e.fences = fences
+	return nil
+}
+
+func (e *Engine) indexLocked(id string) int {
+	for i := range e.fences {
+		if e.fences[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// newID returns a random fence ID
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,224 @@
+package geofence
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gps"
+)
+
+// fakeSource hands the fixes sent on it to the one subscriber
+type fakeSource chan gps.GPSData
+
+func (s fakeSource) Subscribe(ctx context.Context) <-chan gps.GPSData { return s }
+
+var t0 = time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+
+// home is a 100 m circle, out is 500 m north of its center
+var home = Fence{Name: "home", Shape: ShapeCircle, Center: Coordinate{48, 11}, RadiusMeters: 100}
+
+const (
+	in  = 0.0
+	out = 500.0
+)
+
+// fix is a good fix sec seconds after t0, metres north of the home center
+func fix(sec int, north float64) gps.GPSData {
+	return gps.GPSData{
+		Timestamp:  t0.Add(time.Duration(sec) * time.Second),
+		Latitude:   48 + north/111195,
+		Longitude:  11,
+		ValidFix:   true,
+		FixType:    gps.Fix3D,
+		HDOP:       1,
+		Confidence: 1,
+	}
+}
+
+// newEngine creates an engine over a fresh fence file holding fences
+func newEngine(t *testing.T, cfg Config, source FixSource, fences ...Fence) *Engine {
+	t.Helper()
+	cfg.File = filepath.Join(t.TempDir(), "fences.json")
+	e, err := NewEngine(cfg, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fences {
+		if _, err := e.Create(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return e
+}
+
+// event is the part of an Event the tests compare
+type event struct {
+	Type string
+	Sec  int
+}
+
+func events(e *Engine) []event {
+	var got []event
+	for _, ev := range e.Events() {
+		got = append(got, event{ev.Type, int(ev.Time.Sub(t0) / time.Second)})
+	}
+	return got
+}
+
+func TestEngineConfirmFixes(t *testing.T) {
+	tests := []struct {
+		name    string
+		confirm int
+		path    []float64 // one fix per second, metres north of home
+		want    []event
+	}{
+		{
+			name:    "first fix only sets the state",
+			confirm: 1,
+			path:    []float64{in, in},
+		},
+		{
+			name:    "single fix confirms",
+			confirm: 1,
+			path:    []float64{out, in, out},
+			want:    []event{{EventEnter, 1}, {EventExit, 2}},
+		},
+		{
+			name:    "enter after three fixes",
+			confirm: 0,
+			path:    []float64{out, in, in, in, in},
+			want:    []event{{EventEnter, 3}},
+		},
+		{
+			name:    "exit after three fixes",
+			confirm: 3,
+			path:    []float64{in, out, out, out},
+			want:    []event{{EventExit, 3}},
+		},
+		{
+			name:    "jitter along the boundary does not flap",
+			confirm: 3,
+			path:    []float64{out, in, out, in, in, out, in, out},
+		},
+		{
+			name:    "a stray fix restarts the count",
+			confirm: 2,
+			path:    []float64{in, out, in, out, out},
+			want:    []event{{EventExit, 4}},
+		},
+		{
+			name:    "enter then exit",
+			confirm: 2,
+			path:    []float64{out, in, in, out, in, out, out},
+			want:    []event{{EventEnter, 2}, {EventExit, 6}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEngine(t, Config{ConfirmFixes: tt.confirm}, nil, home)
+			for sec, north := range tt.path {
+				e.evaluate(fix(sec, north))
+			}
+			if got := events(e); !slices.Equal(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineDwell(t *testing.T) {
+	fence := home
+	fence.DwellSeconds = 10
+	e := newEngine(t, Config{ConfirmFixes: 2}, nil, fence)
+
+	// Dwell time counts from the first fix of a confirmed crossing and is
+	// reported once per visit
+	for _, step := range []struct {
+		sec   int
+		north float64
+	}{{0, out}, {1, in}, {2, in}, {10, in}, {11, in}, {30, in}, {31, out}, {32, out}, {33, in}, {34, in}, {44, in}} {
+		e.evaluate(fix(step.sec, step.north))
+	}
+	want := []event{{EventEnter, 2}, {EventDwell, 11}, {EventExit, 32}, {EventEnter, 34}, {EventDwell, 44}}
+	if got := events(e); !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestEngineIgnoresBadFixes(t *testing.T) {
+	e := newEngine(t, Config{ConfirmFixes: 1, MaxHDOP: 5}, nil, home)
+
+	poor := fix(1, out)
+	poor.HDOP = 9
+	invalid := fix(2, out)
+	invalid.ValidFix = false
+	estimated := fix(3, out)
+	estimated.Estimated = true
+	for _, f := range []gps.GPSData{fix(0, in), poor, invalid, estimated} {
+		e.evaluate(f)
+	}
+	if got := events(e); len(got) != 0 {
+		t.Errorf("events = %v, want none from bad fixes", got)
+	}
+}
+
+func TestEngineRun(t *testing.T) {
+	source := make(fakeSource)
+	e := newEngine(t, Config{ConfirmFixes: 1}, source, home)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	evs := e.Subscribe(ctx)
+	go e.Run(ctx)
+
+	source <- fix(0, out)
+	source <- fix(1, in)
+	select {
+	case ev := <-evs:
+		if ev.Type != EventEnter || ev.FenceName != "home" || ev.FenceID == "" || !ev.Time.Equal(t0.Add(time.Second)) {
+			t.Errorf("event = %+v, want entering home at +1s", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+}
+
+func TestEngineFences(t *testing.T) {
+	e := newEngine(t, Config{}, nil, home)
+	fences := e.List()
+	if len(fences) != 1 {
+		t.Fatalf("List() = %+v, want one fence", fences)
+	}
+	id := fences[0].ID
+
+	if _, err := e.Create(Fence{Name: "bad", Shape: ShapeCircle}); err == nil {
+		t.Error("Create() accepted a circle without a radius")
+	}
+	moved := home
+	moved.Name = "work"
+	if _, err := e.Update(id, moved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Update("missing", moved); err != ErrNotFound {
+		t.Errorf("Update() of an unknown fence = %v, want ErrNotFound", err)
+	}
+
+	// Changes are persisted
+	reloaded, err := NewEngine(e.cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, err := reloaded.Get(id); err != nil || f.Name != "work" {
+		t.Errorf("Get() after reload = %+v, %v, want the updated fence", f, err)
+	}
+
+	if err := e.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Delete(id); err != ErrNotFound {
+		t.Errorf("second Delete() = %v, want ErrNotFound", err)
+	}
+}
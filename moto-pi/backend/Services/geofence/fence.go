@@ -0,0 +1,99 @@
+package geofence
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gps"
+)
+
+// Fence shapes
+const (
+	ShapeCircle  = "circle"
+	ShapePolygon = "polygon"
+)
+
+// ErrInvalid is wrapped by validation errors
+var ErrInvalid = errors.New("invalid geofence")
+
+// Coordinate is a WGS84 position
+type Coordinate struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lng"`
+}
+
+// Fence is a user-defined area. Circles use Center and RadiusMeters,
+// polygons use Points.
+type Fence struct {
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	Shape        string       `json:"shape"`
+	Center       Coordinate   `json:"center"`
+	RadiusMeters float64      `json:"radiusMeters"`
+	Points       []Coordinate `json:"points,omitempty"`
+	DwellSeconds float64      `json:"dwellSeconds"` // time inside before a dwell event, 0 disables
+}
+
+// Validate checks the fence geometry
+func (f *Fence) Validate() error {
+	if f.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalid)
+	}
+	switch f.Shape {
+	case ShapeCircle:
+		if f.RadiusMeters <= 0 {
+			return fmt.Errorf("%w: circle needs a positive radius", ErrInvalid)
+		}
+		if !validCoordinate(f.Center) {
+			return fmt.Errorf("%w: circle center out of range", ErrInvalid)
+		}
+	case ShapePolygon:
+		if len(f.Points) < 3 {
+			return fmt.Errorf("%w: polygon needs at least 3 points", ErrInvalid)
+		}
+		for _, p := range f.Points {
+			if !validCoordinate(p) {
+				return fmt.Errorf("%w: polygon point out of range", ErrInvalid)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown shape %q", ErrInvalid, f.Shape)
+	}
+	if f.DwellSeconds < 0 {
+		return fmt.Errorf("%w: dwell time cannot be negative", ErrInvalid)
+	}
+	return nil
+}
+
+// Contains reports whether the position lies inside the fence
+func (f *Fence) Contains(lat, lon float64) bool {
+	switch f.Shape {
+	case ShapeCircle:
+		return gps.DistanceMeters(f.Center.Latitude, f.Center.Longitude, lat, lon) <= f.RadiusMeters
+	case ShapePolygon:
+		return pointInPolygon(f.Points, lat, lon)
+	}
+	return false
+}
+
+// pointInPolygon is an even-odd ray cast, treating coordinates as planar,
+// which is accurate enough for fences a few kilometres across
+func pointInPolygon(poly []Coordinate, lat, lon float64) bool {
+	inside := false
+	j := len(poly) - 1
+	for i := range poly {
+		pi, pj := poly[i], poly[j]
+		if (pi.Latitude > lat) != (pj.Latitude > lat) {
+			cross := (pj.Longitude-pi.Longitude)*(lat-pi.Latitude)/(pj.Latitude-pi.Latitude) + pi.Longitude
+			if lon < cross {
+				inside = !inside
+			}
+		}
+		j = i
+	}
+	return inside
+}
+
+func validCoordinate(c Coordinate) bool {
+	return c.Latitude >= -90 && c.Latitude <= 90 && c.Longitude >= -180 && c.Longitude <= 180
+}
@@ -0,0 +1,41 @@
+package geofence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// loadFences reads the fence definitions file. A missing file yields no fences.
+func loadFences(path string) ([]Fence, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var fences []Fence
+	if err := json.Unmarshal(data, &fences); err != nil {
+		return nil, fmt.Errorf("geofence: parse %s: %w", path, err)
+	}
+	return fences, nil
+}
+
+// saveFences writes the fence definitions via a temp file and rename
+func saveFences(path string, fences []Fence) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(fences, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
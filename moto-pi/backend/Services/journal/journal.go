@@ -0,0 +1,17 @@
+package journal
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// Tag is the syslog identifier used for MotoPi events
+const Tag = "gimo-events"
+
+// Log writes a message to the system journal with the gimo-events tag
+func Log(msg string) {
+	cmd := exec.Command("logger", "-t", Tag, msg)
+	if err := cmd.Run(); err != nil {
+		fmt.Println("Failed to write to journal:", err)
+	}
+}
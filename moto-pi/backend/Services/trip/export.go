@@ -0,0 +1,123 @@
+package trip
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"time"
+)
+
+// Export formats
+const (
+	FormatGPX     = "gpx"
+	FormatGeoJSON = "geojson"
+)
+
+// ContentType returns the MIME type of an export format
+func ContentType(format string) string {
+	switch format {
+	case FormatGPX:
+		return "application/gpx+xml"
+	case FormatGeoJSON:
+		return "application/geo+json"
+	}
+	return "application/octet-stream"
+}
+
+type gpxDoc struct {
+	XMLName  xml.Name    `xml:"gpx"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	Version  string      `xml:"version,attr"`
+	Creator  string      `xml:"creator,attr"`
+	Metadata gpxMetadata `xml:"metadata"`
+	Track    gpxTrack    `xml:"trk"`
+}
+
+type gpxMetadata struct {
+	Name string `xml:"name"`
+	Time string `xml:"time"`
+}
+
+type gpxTrack struct {
+	Name    string     `xml:"name"`
+	Segment gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Ele  float64 `xml:"ele"`
+	Time string  `xml:"time"`
+}
+
+// GPX renders a trip as a GPX 1.1 document
+func GPX(t Trip, points []Point) ([]byte, error) {
+	doc := gpxDoc{
+		Xmlns:    "http://www.topografix.com/GPX/1/1",
+		Version:  "1.1",
+		Creator:  "MotoPi",
+		Metadata: gpxMetadata{Name: t.ID, Time: t.Start.UTC().Format(time.RFC3339)},
+		Track:    gpxTrack{Name: t.ID},
+	}
+	for _, p := range points {
+		doc.Track.Segment.Points = append(doc.Track.Segment.Points, gpxPoint{
+			Lat:  p.Latitude,
+			Lon:  p.Longitude,
+			Ele:  p.Altitude,
+			Time: p.Time.UTC().Format(time.RFC3339Nano),
+		})
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// GeoJSON renders a trip as a FeatureCollection holding one LineString
+// feature whose properties carry the trip summary
+func GeoJSON(t Trip, points []Point) ([]byte, error) {
+	coords := make([][3]float64, 0, len(points))
+	times := make([]time.Time, 0, len(points))
+	for _, p := range points {
+		// GeoJSON positions are longitude, latitude, elevation
+		coords = append(coords, [3]float64{p.Longitude, p.Latitude, p.Altitude})
+		times = append(times, p.Time)
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"type": "FeatureCollection",
+		"features": []interface{}{
+			map[string]interface{}{
+				"type": "Feature",
+				"geometry": map[string]interface{}{
+					"type":        "LineString",
+					"coordinates": coords,
+				},
+				"properties": map[string]interface{}{
+					"trip":  t,
+					"times": times,
+				},
+			},
+		},
+	})
+}
+
+// Export renders a trip in the requested format
+func (r *Recorder) Export(id, format string) ([]byte, error) {
+	t, points, err := r.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatGPX:
+		return GPX(t, points)
+	case FormatGeoJSON:
+		return GeoJSON(t, points)
+	}
+	return nil, ErrUnknownFormat
+}
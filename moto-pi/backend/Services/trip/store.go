@@ -0,0 +1,178 @@
+package trip
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// idPattern matches trip IDs, which are also used as file names
+var idPattern = regexp.MustCompile(`^\d{8}T\d{6}Z(-\d+)?$`)
+
+// store keeps each trip as <id>.points.jsonl, appended while riding, and
+// <id>.json, written atomically once the trip ends
+type store struct {
+	dir string
+}
+
+// openStore prepares dir and finalises trips interrupted by a power loss
+func openStore(dir string) (*store, error) {
+	if dir == "" {
+		return nil, errors.New("trip: no storage directory configured")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("trip: create storage directory: %w", err)
+	}
+
+	s := &store{dir: dir}
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *store) pointsPath(id string) string  { return filepath.Join(s.dir, id+".points.jsonl") }
+func (s *store) summaryPath(id string) string { return filepath.Join(s.dir, id+".json") }
+
+// recover rebuilds summaries for point files that never got one
+func (s *store) recover() error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.points.jsonl"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		id := strings.TrimSuffix(filepath.Base(f), ".points.jsonl")
+		if _, err := os.Stat(s.summaryPath(id)); err == nil || !idPattern.MatchString(id) {
+			continue
+		}
+
+		points, err := s.points(id)
+		if err != nil {
+			return err
+		}
+		t := Trip{ID: id}
+		for i := range points {
+			var prev *Point
+			if i > 0 {
+				prev = &points[i-1]
+			}
+			t.add(prev, points[i])
+		}
+		if err := s.saveSummary(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newID names a trip after its start time, with a numeric suffix when a trip
+// started in the same second, e.g. after the clock was reset
+func (s *store) newID(start time.Time) (string, error) {
+	base := start.UTC().Format("20060102T150405Z")
+	id := base
+	for n := 2; ; n++ {
+		_, err := os.Stat(s.pointsPath(id))
+		if errors.Is(err, os.ErrNotExist) {
+			_, err = os.Stat(s.summaryPath(id))
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			return id, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// appendPoint persists a point of an active trip
+func (s *store) appendPoint(id string, p Point) error {
+	f, err := os.OpenFile(s.pointsPath(id), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(p)
+}
+
+// saveSummary writes a trip summary via a temp file and rename
+func (s *store) saveSummary(t Trip) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.summaryPath(t.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.summaryPath(t.ID))
+}
+
+// summary loads one finished trip
+func (s *store) summary(id string) (Trip, error) {
+	if !idPattern.MatchString(id) {
+		return Trip{}, ErrNotFound
+	}
+	data, err := os.ReadFile(s.summaryPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return Trip{}, ErrNotFound
+	}
+	if err != nil {
+		return Trip{}, err
+	}
+	var t Trip
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Trip{}, fmt.Errorf("trip %s: %w", id, err)
+	}
+	return t, nil
+}
+
+// summaries loads every finished trip
+func (s *store) summaries() ([]Trip, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	trips := make([]Trip, 0, len(files))
+	for _, f := range files {
+		t, err := s.summary(strings.TrimSuffix(filepath.Base(f), ".json"))
+		if err != nil {
+			continue
+		}
+		trips = append(trips, t)
+	}
+	return trips, nil
+}
+
+// points loads the track of a trip
+func (s *store) points(id string) ([]Point, error) {
+	if !idPattern.MatchString(id) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(s.pointsPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var points []Point
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var p Point
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+			// A torn final line after a power loss is skipped
+			continue
+		}
+		points = append(points, p)
+	}
+	return points, scanner.Err()
+}
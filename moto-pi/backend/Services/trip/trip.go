@@ -0,0 +1,288 @@
+package trip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gps"
+)
+
+var (
+	// ErrNotFound is returned for unknown trip IDs
+	ErrNotFound = errors.New("trip not found")
+	// ErrUnknownFormat is returned for unsupported export formats
+	ErrUnknownFormat = errors.New("unknown export format")
+)
+
+// Config controls automatic trip detection and storage
+type Config struct {
+	Dir           string  `json:"dir"`           // directory holding recorded trips
+	StartSpeedKph float64 `json:"startSpeedKph"` // speed that starts a trip
+	IdleTimeout   string  `json:"idleTimeout"`   // time below StartSpeedKph that ends a trip, e.g. "5m"
+	MaxHDOP       float64 `json:"maxHdop"`       // fixes with a worse HDOP are ignored, 0 disables
+}
+
+// FixSource provides a stream of GPS fixes, satisfied by *gps.GPS
+type FixSource interface {
+	Subscribe(ctx context.Context) <-chan gps.GPSData
+}
+
+// Point is a single recorded track point
+type Point struct {
+	Time      time.Time `json:"time"`
+	Latitude  float64   `json:"lat"`
+	Longitude float64   `json:"lng"`
+	Altitude  float64   `json:"ele"`
+	SpeedKph  float64   `json:"speedKph"`
+	Course    float64   `json:"course"`
+}
+
+// Trip summarises one recorded ride
+type Trip struct {
+	ID              string    `json:"id"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	Active          bool      `json:"active"`
+	Points          int       `json:"points"`
+	DistanceMeters  float64   `json:"distanceMeters"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	MaxSpeedKph     float64   `json:"maxSpeedKph"`
+	AvgSpeedKph     float64   `json:"avgSpeedKph"`
+	ElevationGainM  float64   `json:"elevationGainMeters"`
+}
+
+// add extends the summary with a new point following prev
+func (t *Trip) add(prev *Point, p Point) {
+	if t.Points == 0 {
+		t.Start = p.Time
+	}
+	t.Points++
+	t.End = p.Time
+	t.DurationSeconds = t.End.Sub(t.Start).Seconds()
+	if p.SpeedKph > t.MaxSpeedKph {
+		t.MaxSpeedKph = p.SpeedKph
+	}
+	if prev != nil {
+		t.DistanceMeters += gps.DistanceMeters(prev.Latitude, prev.Longitude, p.Latitude, p.Longitude)
+		if climb := p.Altitude - prev.Altitude; climb > 0 {
+			t.ElevationGainM += climb
+		}
+	}
+	if t.DurationSeconds > 0 {
+		t.AvgSpeedKph = t.DistanceMeters / t.DurationSeconds * 3.6
+	}
+}
+
+// Recorder turns the GPS stream into trips. A trip starts when the speed
+// reaches StartSpeedKph and ends at its last moving point once the bike has
+// been slower than that for IdleTimeout.
+type Recorder struct {
+	cfg         Config
+	idleTimeout time.Duration
+	source      FixSource
+	store       *store
+
+	mu         sync.RWMutex
+	odometer   float64 // meters of all finished trips
+	current    *Trip
+	lastPoint  *Point
+	idle       []Point // points since the bike stopped, dropped if the trip ends
+	lastMoving time.Time
+	seenAt     time.Time // wall clock of the last fix, ends trips when fixes stop
+}
+
+// NewRecorder creates a recorder storing trips under cfg.Dir
+func NewRecorder(cfg Config, source FixSource) (*Recorder, error) {
+	idle := 5 * time.Minute
+	if cfg.IdleTimeout != "" {
+		d, err := time.ParseDuration(cfg.IdleTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("trip: invalid idle timeout: %w", err)
+		}
+		idle = d
+	}
+
+	st, err := openStore(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	trips, err := st.summaries()
+	if err != nil {
+		return nil, err
+	}
+	var odometer float64
+	for _, t := range trips {
+		odometer += t.DistanceMeters
+	}
+
+	return &Recorder{
+		cfg:         cfg,
+		idleTimeout: idle,
+		source:      source,
+		store:       st,
+		odometer:    odometer,
+	}, nil
+}
+
+// Run records trips until ctx is done. An active trip is closed on return.
+func (r *Recorder) Run(ctx context.Context) error {
+	fixes := r.source.Subscribe(ctx)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	defer r.finish()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case fix, ok := <-fixes:
+			if !ok {
+				return ctx.Err()
+			}
+			if err := r.handle(fix); err != nil {
+				fmt.Println("Trip recorder error:", err)
+			}
+		case <-ticker.C:
+			r.mu.RLock()
+			stale := r.current != nil && time.Since(r.seenAt) > r.idleTimeout
+			r.mu.RUnlock()
+			if stale {
+				r.finish()
+			}
+		}
+	}
+}
+
+// handle feeds one fix into the trip state machine
+func (r *Recorder) handle(fix gps.GPSData) error {
+	// Dead-reckoned fixes carry a trip through short fix loss, e.g. tunnels
+	if !fix.GoodFix(r.cfg.MaxHDOP) && !(fix.Estimated && fix.ValidFix) {
+		return nil
+	}
+
+	p := Point{
+		Time:      fix.Timestamp,
+		Latitude:  fix.Latitude,
+		Longitude: fix.Longitude,
+		Altitude:  fix.Altitude,
+		SpeedKph:  fix.SpeedKph,
+		Course:    fix.TrackAngle,
+	}
+	if p.Time.IsZero() {
+		p.Time = time.Now().UTC()
+	}
+	moving := p.SpeedKph >= r.cfg.StartSpeedKph
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seenAt = time.Now()
+
+	if r.current == nil {
+		if !moving {
+			return nil
+		}
+		id, err := r.store.newID(p.Time)
+		if err != nil {
+			return err
+		}
+		r.current = &Trip{ID: id, Active: true}
+		r.lastPoint = nil
+		r.idle = nil
+		r.lastMoving = p.Time
+	}
+
+	if !moving {
+		if p.Time.Sub(r.lastMoving) > r.idleTimeout {
+			return r.finishLocked()
+		}
+		// A stop only becomes part of the trip once the bike moves on, so a
+		// trip ends at its last moving point
+		r.idle = append(r.idle, p)
+		return nil
+	}
+
+	for _, q := range append(r.idle, p) {
+		if err := r.store.appendPoint(r.current.ID, q); err != nil {
+			return err
+		}
+		r.current.add(r.lastPoint, q)
+		r.lastPoint = &q
+	}
+	r.idle = nil
+	r.lastMoving = p.Time
+	return nil
+}
+
+// finish closes the active trip, if any
+func (r *Recorder) finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.finishLocked(); err != nil {
+		fmt.Println("Trip recorder error:", err)
+	}
+}
+
+func (r *Recorder) finishLocked() error {
+	if r.current == nil {
+		return nil
+	}
+	t := *r.current
+	t.Active = false
+	r.current = nil
+	r.lastPoint = nil
+	r.idle = nil
+	r.odometer += t.DistanceMeters
+	return r.store.saveSummary(t)
+}
+
+// OdometerMeters returns the distance of all recorded trips, including the
+// active one
+func (r *Recorder) OdometerMeters() float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.current == nil {
+		return r.odometer
+	}
+	return r.odometer + r.current.DistanceMeters
+}
+
+// Current returns the active trip, if one is being recorded
+func (r *Recorder) Current() (Trip, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.current == nil {
+		return Trip{}, false
+	}
+	return *r.current, true
+}
+
+// List returns all trips, newest first, including the active one
+func (r *Recorder) List() ([]Trip, error) {
+	trips, err := r.store.summaries()
+	if err != nil {
+		return nil, err
+	}
+	if cur, ok := r.Current(); ok {
+		trips = append(trips, cur)
+	}
+	sort.Slice(trips, func(i, j int) bool { return trips[i].Start.After(trips[j].Start) })
+	return trips, nil
+}
+
+// Get returns a trip and its track points
+func (r *Recorder) Get(id string) (Trip, []Point, error) {
+	if cur, ok := r.Current(); ok && cur.ID == id {
+		points, err := r.store.points(id)
+		return cur, points, err
+	}
+	t, err := r.store.summary(id)
+	if err != nil {
+		return Trip{}, nil, err
+	}
+	points, err := r.store.points(id)
+	return t, points, err
+}
@@ -0,0 +1,182 @@
+package trip
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gps"
+)
+
+// fakeSource hands the fixes sent on it to the one subscriber
+type fakeSource chan gps.GPSData
+
+func (s fakeSource) Subscribe(ctx context.Context) <-chan gps.GPSData { return s }
+
+var t0 = time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+
+// fix is a good fix sec seconds after t0, metres north of a fixed origin
+func fix(sec int, north, speedKph float64) gps.GPSData {
+	return gps.GPSData{
+		Timestamp:  t0.Add(time.Duration(sec) * time.Second),
+		Latitude:   48 + north/111195,
+		Longitude:  11,
+		SpeedKph:   speedKph,
+		ValidFix:   true,
+		FixType:    gps.Fix3D,
+		HDOP:       1,
+		Confidence: 1,
+	}
+}
+
+// record runs a recorder over fixes and returns it once Run has returned,
+// which closes the last trip
+func record(t *testing.T, dir string, fixes ...gps.GPSData) *Recorder {
+	t.Helper()
+	source := make(fakeSource)
+	r, err := NewRecorder(Config{Dir: dir, StartSpeedKph: 10, IdleTimeout: "30s", MaxHDOP: 5}, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(ctx)
+	}()
+	for _, f := range fixes {
+		source <- f
+	}
+	cancel()
+	<-done
+	return r
+}
+
+// trips lists the recorded trips oldest first
+func trips(t *testing.T, r *Recorder) []Trip {
+	t.Helper()
+	list, err := r.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	return list
+}
+
+func TestRecorderTripBoundaries(t *testing.T) {
+	tests := []struct {
+		name       string
+		fixes      []gps.GPSData
+		wantPoints []int     // points per trip
+		wantEnd    []int     // seconds after t0 each trip ends
+		wantMeters []float64 // distance per trip
+	}{
+		{
+			name:  "standing still records nothing",
+			fixes: []gps.GPSData{fix(0, 0, 0), fix(1, 0, 2), fix(60, 0, 0)},
+		},
+		{
+			name: "trip ends at the last moving point",
+			fixes: []gps.GPSData{
+				fix(0, 0, 0),
+				fix(1, 0, 36), fix(2, 10, 36), fix(3, 20, 36),
+				fix(4, 20, 0), fix(20, 20, 0), fix(40, 20, 0),
+			},
+			wantPoints: []int{3},
+			wantEnd:    []int{3},
+			wantMeters: []float64{20},
+		},
+		{
+			name: "a short stop stays part of the trip",
+			fixes: []gps.GPSData{
+				fix(0, 0, 36), fix(1, 10, 36),
+				fix(2, 10, 0), fix(20, 10, 0),
+				fix(21, 20, 36),
+				fix(22, 20, 0), fix(60, 20, 0),
+			},
+			wantPoints: []int{5},
+			wantEnd:    []int{21},
+			wantMeters: []float64{20},
+		},
+		{
+			name: "a long stop splits the ride",
+			fixes: []gps.GPSData{
+				fix(0, 0, 36), fix(1, 10, 36),
+				fix(2, 10, 0), fix(40, 10, 0),
+				fix(41, 10, 36), fix(42, 30, 72),
+			},
+			wantPoints: []int{2, 2},
+			wantEnd:    []int{1, 42},
+			wantMeters: []float64{10, 20},
+		},
+		{
+			name: "bad fixes are ignored, dead reckoning is kept",
+			fixes: []gps.GPSData{
+				fix(0, 0, 36),
+				func() gps.GPSData { f := fix(1, 500, 36); f.HDOP = 9; return f }(),
+				func() gps.GPSData { f := fix(2, 500, 36); f.ValidFix = false; return f }(),
+				func() gps.GPSData { f := fix(3, 10, 36); f.Estimated = true; return f }(),
+				fix(4, 20, 36),
+			},
+			wantPoints: []int{3},
+			wantEnd:    []int{4},
+			wantMeters: []float64{20},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := record(t, t.TempDir(), tt.fixes...)
+			got := trips(t, r)
+			if len(got) != len(tt.wantPoints) {
+				t.Fatalf("recorded %d trips, want %d: %+v", len(got), len(tt.wantPoints), got)
+			}
+			var total float64
+			for i, trip := range got {
+				if trip.Active || trip.Points != tt.wantPoints[i] ||
+					!trip.End.Equal(t0.Add(time.Duration(tt.wantEnd[i])*time.Second)) ||
+					math.Abs(trip.DistanceMeters-tt.wantMeters[i]) > 0.1 {
+					t.Errorf("trip %d = %+v, want %d points ending at +%ds over %.0f m",
+						i, trip, tt.wantPoints[i], tt.wantEnd[i], tt.wantMeters[i])
+				}
+				_, points, err := r.Get(trip.ID)
+				if err != nil || len(points) != trip.Points {
+					t.Errorf("Get(%s) = %d points, %v, want %d", trip.ID, len(points), err, trip.Points)
+				}
+				total += trip.DistanceMeters
+			}
+			if odo := r.OdometerMeters(); math.Abs(odo-total) > 1e-6 {
+				t.Errorf("OdometerMeters() = %v, want %v", odo, total)
+			}
+		})
+	}
+}
+
+func TestRecorderUniqueIDs(t *testing.T) {
+	dir := t.TempDir()
+
+	// The clock was reset between rides, both start in the same second
+	ride := []gps.GPSData{fix(0, 0, 36), fix(1, 10, 36)}
+	record(t, dir, ride...)
+	r := record(t, dir, ride...)
+
+	got := trips(t, r)
+	if len(got) != 2 {
+		t.Fatalf("recorded %d trips, want 2", len(got))
+	}
+	ids := []string{got[0].ID, got[1].ID}
+	sort.Strings(ids)
+	if want := []string{"20260501T090000Z", "20260501T090000Z-2"}; !slices.Equal(ids, want) {
+		t.Errorf("trip IDs = %q, want %q", ids, want)
+	}
+
+	// The odometer is restored from the stored trips
+	if odo := r.OdometerMeters(); math.Abs(odo-20) > 0.1 {
+		t.Errorf("OdometerMeters() after restart = %v, want 20", odo)
+	}
+}
@@ -1 +1,91 @@
 package Types
+
+import (
+	"github.com/B64-Cryptzo/MotoPi/backend/API"
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/can"
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gpio"
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gps"
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/rfid"
+	"github.com/B64-Cryptzo/MotoPi/backend/Services/alarm"
+	"github.com/B64-Cryptzo/MotoPi/backend/Services/geofence"
+	"github.com/B64-Cryptzo/MotoPi/backend/Services/trip"
+)
+
+// Config is the backend configuration loaded at startup
+type Config struct {
+	Listen    string               `json:"listen"`
+	API       API.Config           `json:"api"`
+	HAL       hal.Config           `json:"hal"`
+	GPS       gps.SourceConfig     `json:"gps"`
+	GPSFilter gps.FilterConfig     `json:"gpsFilter"`
+	Trips     trip.Config          `json:"trips"`
+	Geofences geofence.Config      `json:"geofences"`
+	Alarm     alarm.Config         `json:"alarm"`
+	GPIO      gpio.Config          `json:"gpio"`
+	Unlock    gpio.MomentaryConfig `json:"unlock"` // switch sequence run for an authorized tag
+	RFID      rfid.Config          `json:"rfid"`
+	CAN       can.Config           `json:"can"`      // engine data, off unless an interface or a replay is set
+	PowerOff  []string             `json:"powerOff"` // command run after the shutdown button, e.g. ["systemctl", "poweroff"]
+}
+
+// DefaultConfig returns the configuration used when no config file is present
+func DefaultConfig() Config {
+	return Config{
+		Listen: ":8080",
+		API: API.Config{
+			AllowedOrigins: []string{"http://10.10.10.1"},
+			TokenFile:      "data/api-token",
+		},
+		GPS: gps.SourceConfig{
+			Type:     gps.SourceSerial,
+			Port:     "/dev/ttyAMA0",
+			BaudRate: 115200,
+		},
+		GPSFilter: gps.FilterConfig{
+			MaxPredict: "10s",
+		},
+		Trips: trip.Config{
+			Dir:           "data/trips",
+			StartSpeedKph: 10,
+			IdleTimeout:   "5m",
+			MaxHDOP:       5,
+		},
+		Geofences: geofence.Config{
+			File:         "data/geofences.json",
+			MaxHDOP:      5,
+			ConfirmFixes: geofence.DefaultConfirmFixes,
+		},
+		Alarm: alarm.Config{
+			RadiusMeters:  30,
+			MaxSpeedKph:   8,
+			SirenDuration: "30s",
+			MaxHDOP:       5,
+			HistoryFile:   "data/alarm-history.json",
+		},
+		GPIO: gpio.Config{
+			Pins: []gpio.PinConfig{
+				{Name: "unlock", BCM: 21, Direction: gpio.DirectionOut},
+				{Name: "lock", BCM: 26, Direction: gpio.DirectionOut, DefaultOn: true},
+			},
+		},
+		Unlock: gpio.MomentaryConfig{
+			Pulse:    "unlock",
+			Hold:     "lock",
+			Duration: "3s",
+		},
+		RFID: rfid.Config{
+			AuthFile:       "data/rfid-auth.json",
+			SecretFile:     "data/rfid-secret.key",
+			Client:         rfid.ClientSession,
+			Port:           rfid.PM3Port,
+			Protocols:      append([]string{}, rfid.DefaultProtocols...),
+			MifareKey:      rfid.DefaultMifareKey,
+			CommandTimeout: "10s",
+			EventsFile:     "data/rfid-events.jsonl",
+			MaxEvents:      rfid.DefaultMaxEvents,
+			Cooldown:       "5s",
+			RemoveAfter:    "1s",
+		},
+	}
+}
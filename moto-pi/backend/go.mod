@@ -6,11 +6,12 @@ require (
 	github.com/adrianmo/go-nmea v1.10.0
 	github.com/julienschmidt/httprouter v1.3.0
 	go.bug.st/serial v1.6.4
+	golang.org/x/sys v0.19.0
 	periph.io/x/conn/v3 v3.7.2
 	periph.io/x/host/v3 v3.8.5
 )
 
 require (
 	github.com/creack/goselect v0.1.2 // indirect
-	golang.org/x/sys v0.19.0 // indirect
+	github.com/jonboulle/clockwork v0.4.0 // indirect
 )
@@ -1,54 +1,311 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/B64-Cryptzo/MotoPi/backend/API"
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal"
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/can"
+	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gpio"
 	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/gps"
 	"github.com/B64-Cryptzo/MotoPi/backend/Firmware/hal/rfid"
+	"github.com/B64-Cryptzo/MotoPi/backend/Services/alarm"
+	"github.com/B64-Cryptzo/MotoPi/backend/Services/geofence"
+	"github.com/B64-Cryptzo/MotoPi/backend/Services/journal"
+	"github.com/B64-Cryptzo/MotoPi/backend/Services/trip"
+	"github.com/B64-Cryptzo/MotoPi/backend/Types"
 	"github.com/julienschmidt/httprouter"
 )
 
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Set CORS headers for all requests
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+// shutdownTimeout bounds how long open requests, and then the HAL devices,
+// may delay a shutdown
+const shutdownTimeout = 5 * time.Second
 
-		// Handle preflight requests
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
+// watchInputs journals changes of the bike's switches and calls shutdown once
+// the shutdown button has been held for hold
+func watchInputs(ctx context.Context, pins *gpio.Controller, hold time.Duration, shutdown func()) {
+	events := pins.Subscribe(ctx)
+	var held <-chan time.Time
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if ev.Role == "" {
+				continue
+			}
+			journal.Log(fmt.Sprintf("[GPIO_INPUT] %s active=%t", ev.Role, ev.Active))
+			if ev.Role == gpio.RoleShutdown {
+				held = nil
+				if ev.Active {
+					held = time.After(hold)
+				}
+			}
+		case <-held:
+			journal.Log("[SHUTDOWN_BUTTON]")
+			shutdown()
 			return
 		}
+	}
+}
 
-		// Call the next handler
-		next.ServeHTTP(w, r)
-	})
+// runPowerOff runs the configured power off command
+func runPowerOff(command []string) {
+	if len(command) == 0 {
+		return
+	}
+	log.Println("Powering off:", command)
+	if err := exec.Command(command[0], command[1:]...).Run(); err != nil {
+		log.Println("Power off failed:", err)
+	}
+}
+
+// provisionTag writes an HMAC credential for rider to the tag in the field
+func provisionTag(ctx context.Context, cfg rfid.Config, auth *rfid.AuthStore, secret []byte, rider string) error {
+	pm3, err := rfid.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer pm3.Close()
+
+	cred, err := rfid.ProvisionTag(ctx, rfid.NewTagReader(pm3, cfg), auth, secret, rider)
+	if err != nil {
+		return err
+	}
+	log.Printf("Provisioned %s tag %s for %s (credential %s, serial %d)", cred.Protocol, cred.UID, cred.Name, cred.ID, cred.Serial)
+	return nil
 }
 
 func main() {
+	configPath := flag.String("config", "config.json", "path to the backend config file")
+	provision := flag.String("rfid-provision", "", "write an HMAC credential for this rider to the tag in the field and exit")
+	flag.Parse()
+
+	cfg, err := Types.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// SIGINT and SIGTERM stop the backend like the shutdown button, without powering off
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Deferred first so it runs once every device has been closed
+	var powerOff, failed atomic.Bool
+	defer func() {
+		if powerOff.Load() {
+			runPowerOff(cfg.PowerOff)
+		}
+		if failed.Load() {
+			os.Exit(1)
+		}
+	}()
 
-	scanner := &rfid.RFIDScanner{}
-	if err := scanner.Init(); err != nil {
-		panic(err)
+	auth, err := rfid.LoadAuthStore(cfg.RFID.AuthFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	secret, err := rfid.LoadSecret(cfg.RFID.SecretFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	auth.SetSecret(secret)
+	auth.AllowLegacySignatures(cfg.RFID.LegacySignatures)
+
+	if *provision != "" {
+		if err := provisionTag(ctx, cfg.RFID, auth, secret, *provision); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	source, err := gps.NewSource(cfg.GPS)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	gps := gps.NewGPSWithSource(source)
+	if err := gps.SetFilter(cfg.GPSFilter); err != nil {
+		log.Fatal(err)
 	}
 
-	gps := gps.NewGPS("/dev/ttyAMA0", 115200)
-	if err := gps.Init(); err != nil {
-		panic(err)
+	events, err := rfid.OpenAccessLog(cfg.RFID.EventsFile, cfg.RFID.MaxEvents)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	defer gps.Close()
-	defer scanner.Close()
+	pins, err := gpio.NewController(cfg.GPIO)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	scanner, err := rfid.NewRFIDScanner(cfg.RFID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	scanner.Auth = auth
+	scanner.Events = events
+	scanner.Position = gps
+	if cfg.Unlock.Pulse != "" {
+		scanner.OnUnlock = func(rfid.Credential) {
+			if err := pins.Momentary(cfg.Unlock); err != nil {
+				log.Println("Unlock failed:", err)
+			}
+		}
+	}
+
+	// Everything that can fail on bad configuration is built before the
+	// devices start, so no exit skips their shutdown
+	apiToken, err := API.LoadToken(cfg.API.TokenFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	trips, err := trip.NewRecorder(cfg.Trips, gps)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fences, err := geofence.NewEngine(cfg.Geofences, gps)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var siren hal.Actuator
+	if cfg.Alarm.SirenPin != "" {
+		relay, err := pins.Relay(cfg.Alarm.SirenPin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		siren = relay
+	}
+
+	var ignition alarm.IgnitionSense
+	var killSwitch, sideStand API.SwitchSense
+	if in, err := pins.Input(cfg.GPIO.Roles.Ignition); err == nil {
+		ignition = gpio.Ignition{Input: in}
+	}
+	if in, err := pins.Input(cfg.GPIO.Roles.KillSwitch); err == nil {
+		killSwitch = in
+	}
+	if in, err := pins.Input(cfg.GPIO.Roles.SideStand); err == nil {
+		sideStand = in
+	}
+
+	theftAlarm, err := alarm.New(cfg.Alarm, gps, siren, ignition)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Devices start in this order and stop in reverse, so outputs are
+	// returned to their defaults last
+	devices, err := hal.NewRegistry(cfg.HAL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, d := range []struct {
+		name string
+		dev  hal.Device
+	}{
+		{"gpio", pins},
+		{"gps", gps},
+		{"rfid", scanner},
+	} {
+		if err := devices.Register(d.name, d.dev); err != nil {
+			log.Fatal(err)
+		}
+	}
+	var engine hal.Sensor
+	if cfg.CAN.Enabled() {
+		sensor, err := can.NewSensor(cfg.CAN)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := devices.Register("can", sensor); err != nil {
+			log.Fatal(err)
+		}
+		engine = sensor
+	}
+
+	// The Proxmark client is built last, once no log.Fatal can skip its Close
+	pm3, err := rfid.NewClient(cfg.RFID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer pm3.Close()
+	scanner.Reader = rfid.NewTagReader(pm3, cfg.RFID)
+
+	if err := devices.InitContext(ctx); err != nil {
+		log.Println("Warning: HAL devices unavailable:", err)
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := devices.CloseContext(closeCtx); err != nil {
+			log.Println("Warning: HAL devices did not close cleanly:", err)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go trips.Run(ctx)
+	go fences.Run(ctx)
+	go theftAlarm.Run(ctx)
 
 	router := httprouter.New()
 
-	_ = API.NewHALInterfaceHandler(&API.LiveHALService{RFIDScanner: scanner, GPS: gps}, router)
+	_ = API.NewHALInterfaceHandler(&API.LiveHALService{Registry: devices}, router, apiToken)
 	_ = API.NewNetworkInterfaceHandler(&API.StubNetworkService{}, router)
-	_ = API.NewMotorcycleInterfaceHandler(&API.StubMotorcycleService{}, router)
+	_ = API.NewMotorcycleInterfaceHandler(&API.LiveMotorcycleService{
+		GPS:        gps,
+		Trips:      trips,
+		Alarm:      theftAlarm,
+		Ignition:   ignition,
+		KillSwitch: killSwitch,
+		SideStand:  sideStand,
+		Engine:     engine,
+	}, router)
+	_ = API.NewGeofenceInterfaceHandler(&API.LiveGeofenceService{Engine: fences}, router)
 
-	log.Println("Starting backend on :8080")
-	log.Fatal(http.ListenAndServe(":8080", corsMiddleware(router)))
+	server := &http.Server{
+		Addr:    cfg.Listen,
+		Handler: API.CORS(cfg.API.AllowedOrigins, router),
+		// Requests end with ctx, so event streams do not hold up Shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go watchInputs(ctx, pins, pins.ShutdownHold(), func() {
+		log.Println("Shutdown button held, stopping backend")
+		powerOff.Store(true)
+		cancel()
+	})
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Println("Warning: HTTP server did not stop cleanly:", err)
+		}
+	}()
+
+	log.Println("Starting backend on", cfg.Listen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		// Not log.Fatal, the devices still have to be closed
+		log.Println("HTTP server failed:", err)
+		failed.Store(true)
+	}
+	log.Println("Stopping backend")
 }
@@ -1,8 +1,23 @@
-import { useState } from "react";
+import { useEffect, useState } from "react";
 import "./MotorcyclePage.css";
 
+const STATUS_URL = "http://10.10.10.1:8080/v1/api/motorcycle/status";
+
 export default function MotorcyclePage() {
   const [modalData, setModalData] = useState(null);
+  const [telemetry, setTelemetry] = useState(null);
+
+  useEffect(() => {
+    const poll = () =>
+      fetch(STATUS_URL)
+        .then((res) => res.json())
+        .then(setTelemetry)
+        .catch(() => setTelemetry(null));
+
+    poll();
+    const timer = setInterval(poll, 2000);
+    return () => clearInterval(timer);
+  }, []);
 
   const triggerAction = (action, url) => {
     const modalState = { action, loading: true, success: null, error: null };
@@ -30,6 +45,7 @@ export default function MotorcyclePage() {
   };
 
   const closeModal = () => setModalData(null);
+  const engine = telemetry?.engine;
 
   return (
     <div className="motorcycle-page">
@@ -39,6 +55,79 @@ export default function MotorcyclePage() {
 
       <h1 className="page-title">Motorcycle Control</h1>
 
+      <div className="telemetry-grid">
+        <div className="telemetry-item">
+          <span className="telemetry-label">Speed</span>
+          <span className="telemetry-value">
+            {telemetry ? `${telemetry.speedKph.toFixed(0)} km/h` : "--"}
+          </span>
+        </div>
+        <div className="telemetry-item">
+          <span className="telemetry-label">Heading</span>
+          <span className="telemetry-value">
+            {telemetry ? `${telemetry.headingDeg.toFixed(0)}°` : "--"}
+          </span>
+        </div>
+        <div className="telemetry-item">
+          <span className="telemetry-label">Position</span>
+          <span className="telemetry-value">
+            {telemetry?.position
+              ? `${telemetry.position.lat.toFixed(5)}, ${telemetry.position.lng.toFixed(5)}`
+              : "No fix"}
+          </span>
+        </div>
+        <div className="telemetry-item">
+          <span className="telemetry-label">Odometer</span>
+          <span className="telemetry-value">
+            {telemetry ? `${telemetry.odometerKm.toFixed(1)} km` : "--"}
+          </span>
+        </div>
+        <div className="telemetry-item">
+          <span className="telemetry-label">Trip</span>
+          <span className="telemetry-value">
+            {telemetry?.currentTrip
+              ? `${(telemetry.currentTrip.distanceMeters / 1000).toFixed(1)} km`
+              : "Parked"}
+          </span>
+        </div>
+        <div className="telemetry-item">
+          <span className="telemetry-label">Ignition</span>
+          <span className="telemetry-value">
+            {telemetry?.ignition == null ? "--" : telemetry.ignition ? "On" : "Off"}
+          </span>
+        </div>
+        <div className="telemetry-item">
+          <span className="telemetry-label">RPM</span>
+          <span className="telemetry-value">
+            {engine?.rpm == null ? "--" : engine.rpm.toFixed(0)}
+          </span>
+        </div>
+        <div className="telemetry-item">
+          <span className="telemetry-label">Gear</span>
+          <span className="telemetry-value">
+            {engine?.gear == null ? "--" : engine.gear === 0 ? "N" : engine.gear}
+          </span>
+        </div>
+        <div className="telemetry-item">
+          <span className="telemetry-label">Coolant</span>
+          <span className="telemetry-value">
+            {engine?.coolantTempC == null ? "--" : `${engine.coolantTempC.toFixed(0)} °C`}
+          </span>
+        </div>
+        <div className="telemetry-item">
+          <span className="telemetry-label">Battery</span>
+          <span className="telemetry-value">
+            {engine?.batteryV == null ? "--" : `${engine.batteryV.toFixed(1)} V`}
+          </span>
+        </div>
+        <div className="telemetry-item">
+          <span className="telemetry-label">Faults</span>
+          <span className="telemetry-value">
+            {engine == null ? "--" : engine.dtcs.length ? engine.dtcs.join(", ") : "None"}
+          </span>
+        </div>
+      </div>
+
       <div className="action-grid">
         <div
           className="action-card action-reboot"
@@ -2,6 +2,19 @@ import { useState } from "react";
 import StatusCard from "../../components/StatusCard";
 import "./StatusPage.css";
 
+// formatStatus renders a status value, which may be a string, number or object
+const formatStatus = (status) => {
+  if (status === null || status === undefined) return "N/A";
+  if (typeof status === "object") return JSON.stringify(status);
+  return String(status).toUpperCase();
+};
+
+// statusValue picks the health state of a HAL device report, other values pass through
+const statusValue = (status) => status?.health?.state ?? status;
+
+// isDown reports whether a status value means the component is not working
+const isDown = (value) => value === "offline" || value === "failed";
+
 export default function StatusPage() {
   const [modalData, setModalData] = useState(null);
 
@@ -42,8 +55,8 @@ export default function StatusPage() {
                 {Object.entries(modalData.statusMap).map(([device, status]) => (
                   <li key={device} className="status-detail-item">
                     <span className="device-name">{device}</span>
-                    <span className={status !== "offline" ? "status-online" : "status-offline"}>
-                      {status.toUpperCase()}
+                    <span className={isDown(statusValue(status)) ? "status-offline" : "status-online"}>
+                      {formatStatus(statusValue(status))}
                     </span>
                   </li>
                 ))}